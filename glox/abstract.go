@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Abstract methods are declared with 'abstract' and have no body. They can only be
+// declared in an abstract class, which can't be instantiated. A concrete subclass has
+// to implement all the abstract methods it inherits, either itself or through a
+// trait: the resolver warns when it can tell that one hasn't been, and instantiating
+// the class fails.
+
+// checkAbstract checks that an abstract method is declared in an abstract class, and
+// isn't an initializer
+func (r *Resolver) checkAbstract(class *ClassStmt, method *FunctionStmt) error {
+	if method.functionName.lexeme == "init" {
+		r.runtime.parseError(method.functionName, "init can't be abstract.")
+		return fmt.Errorf("init can't be abstract")
+	}
+	if !class.isAbstract {
+		r.runtime.parseError(method.functionName, fmt.Sprintf(
+			"Abstract method '%s' can only be declared in an abstract class.", method.functionName.lexeme))
+		return fmt.Errorf("abstract method %s declared in class %s, which isn't abstract",
+			method.functionName.lexeme, class.className.lexeme)
+	}
+	return nil
+}
+
+// checkAbstractImplemented reports a lint warning for each abstract method that a
+// concrete class inherits and doesn't implement. Only superclasses and traits that
+// have been resolved are checked, since they can otherwise only be known at runtime.
+func (r *Resolver) checkAbstractImplemented(class *ClassStmt) {
+	if class.isAbstract {
+		return
+	}
+
+	// Walk the class hierarchy from the root down, so that each method's most-derived
+	// declaration determines whether it's abstract
+	hierarchy := []*ClassStmt{class}
+	for c := class; c.superclass != nil; {
+		superclass, ok := r.classes[c.superclass.variable.lexeme]
+		if !ok || slices.Contains(hierarchy, superclass) {
+			break
+		}
+		hierarchy = append(hierarchy, superclass)
+		c = superclass
+	}
+	slices.Reverse(hierarchy)
+
+	abstractIn := make(map[string]*ClassStmt) // abstract methods, and the class declaring them
+	for _, c := range hierarchy {
+		for _, traitExpr := range c.traits {
+			if trait, ok := r.traits[traitExpr.variable.lexeme]; ok {
+				for _, method := range trait.methods {
+					delete(abstractIn, methodKey(method))
+				}
+			}
+		}
+		for _, method := range c.methods {
+			if method.isAbstract {
+				abstractIn[methodKey(method)] = c
+			} else {
+				delete(abstractIn, methodKey(method))
+			}
+		}
+	}
+
+	missing := make([]string, 0, len(abstractIn))
+	for key := range abstractIn {
+		missing = append(missing, key)
+	}
+	slices.Sort(missing)
+	for _, key := range missing {
+		r.linter.report(lintUnimplementedAbstract, class.className,
+			fmt.Sprintf("Class '%s' doesn't implement abstract method '%s' of '%s'",
+				class.className.lexeme, key, abstractIn[key].className.lexeme))
+	}
+}
+
+// unimplementedAbstractMethods returns the names of the abstract methods that the
+// class inherits or declares, and that aren't implemented
+func (lc *LoxClass) unimplementedAbstractMethods() []string {
+	missing := make([]string, 0)
+	for class := lc; class != nil; class = class.superclass {
+		for name, method := range class.methods {
+			if method.declaration.isAbstract && lc.findMethod(name).declaration.isAbstract &&
+				!slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+		for name, setter := range class.setters {
+			if setter.declaration.isAbstract && lc.findSetter(name).declaration.isAbstract &&
+				!slices.Contains(missing, methodName(setter.declaration)) {
+				missing = append(missing, methodName(setter.declaration))
+			}
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// checkInstantiable returns an error if the class is abstract, or has abstract
+// methods that aren't implemented
+func (lc *LoxClass) checkInstantiable() error {
+	if lc.declaration == nil {
+		return nil
+	}
+	missing := lc.unimplementedAbstractMethods()
+	switch {
+	case len(missing) > 0 && lc.declaration.isAbstract:
+		return RuntimeError{lc.declaration.className, fmt.Sprintf(
+			"Can't instantiate abstract class %s with unimplemented abstract methods: %s.",
+			lc.name, strings.Join(missing, ", "))}
+	case len(missing) > 0:
+		return RuntimeError{lc.declaration.className, fmt.Sprintf(
+			"Can't instantiate class %s with unimplemented abstract methods: %s.", lc.name, strings.Join(missing, ", "))}
+	case lc.declaration.isAbstract:
+		return RuntimeError{lc.declaration.className, fmt.Sprintf("Can't instantiate abstract class %s.", lc.name)}
+	}
+	return nil
+}
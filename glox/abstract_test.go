@@ -0,0 +1,192 @@
+package main
+
+import "testing"
+
+// ============================================================================
+// ABSTRACT CLASS TESTS
+// ============================================================================
+
+func TestAbstractClasses(t *testing.T) {
+	t.Run("Concrete subclass implements abstract methods", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract area();
+    abstract name;
+    describe() {
+        print this.name;
+        print this.area();
+    }
+}
+class Square < Shape {
+    init(side) {
+        this.side = side;
+    }
+    area() {
+        return this.side * this.side;
+    }
+    name {
+        return "square";
+    }
+}
+Square(3).describe();
+`
+		expected := []string{"square", "9"}
+		runProgramAndCheckOutput(t, program, expected, "Concrete subclass implements abstract methods")
+	})
+
+	t.Run("Abstract subclass of an abstract class", func(t *testing.T) {
+		program := `
+abstract class Animal {
+    abstract sound();
+}
+abstract class Pet < Animal {
+    abstract name();
+}
+class Dog < Pet {
+    sound() {
+        return "woof";
+    }
+    name() {
+        return "Rex";
+    }
+}
+var dog = Dog();
+print dog.name() + " says " + dog.sound();
+`
+		expected := []string{"Rex says woof"}
+		runProgramAndCheckOutput(t, program, expected, "Abstract subclass of an abstract class")
+	})
+
+	t.Run("Abstract method implemented by a trait", func(t *testing.T) {
+		program := `
+abstract class Greeter {
+    abstract greet();
+}
+trait Polite {
+    greet() {
+        return "Good day";
+    }
+}
+class Butler < Greeter with Polite {}
+print Butler().greet();
+`
+		expected := []string{"Good day"}
+		runProgramAndCheckOutput(t, program, expected, "Abstract method implemented by a trait")
+	})
+
+	t.Run("Abstract setter", func(t *testing.T) {
+		program := `
+abstract class Store {
+    abstract set value(v);
+}
+class Box < Store {
+    set value(v) {
+        this.contents = v;
+    }
+}
+var box = Box();
+box.value = "gift";
+print box.contents;
+`
+		expected := []string{"gift"}
+		runProgramAndCheckOutput(t, program, expected, "Abstract setter")
+	})
+
+	t.Run("Abstract can be used as a name", func(t *testing.T) {
+		program := `
+var abstract = "yes";
+class Art {
+    abstract() {
+        return abstract;
+    }
+}
+print Art().abstract();
+`
+		expected := []string{"yes"}
+		runProgramAndCheckOutput(t, program, expected, "Abstract can be used as a name")
+	})
+}
+
+func TestAbstractClassErrors(t *testing.T) {
+	t.Run("Instantiating an abstract class", func(t *testing.T) {
+		program := `
+abstract class Base {}
+Base();
+`
+		runProgramAndExpectError(t, program, "Can't instantiate abstract class Base.", "Instantiating an abstract class")
+	})
+
+	t.Run("Instantiating an abstract class lists its abstract methods", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract perimeter();
+    abstract area();
+}
+Shape();
+`
+		runProgramAndExpectError(t, program, "Can't instantiate abstract class Shape with unimplemented abstract methods: area, perimeter.", "Instantiating an abstract class lists its abstract methods")
+	})
+
+	t.Run("Instantiating a class that doesn't implement abstract methods", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract area();
+    abstract set size(s);
+}
+class Blob < Shape {}
+Blob();
+`
+		runProgramAndExpectError(t, program, "Can't instantiate class Blob with unimplemented abstract methods: area, size=.", "Instantiating a class that doesn't implement abstract methods")
+	})
+
+	t.Run("Calling an abstract method through super", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract area();
+}
+class Square < Shape {
+    area() {
+        return super.area();
+    }
+}
+Square().area();
+`
+		runProgramAndExpectError(t, program, "Can't call abstract method 'area'.", "Calling an abstract method through super")
+	})
+
+	t.Run("Abstract method in a concrete class", func(t *testing.T) {
+		program := `
+class Shape {
+    abstract area();
+}
+`
+		runProgramAndExpectError(t, program, "Abstract method 'area' can only be declared in an abstract class.", "Abstract method in a concrete class")
+	})
+
+	t.Run("Abstract method with a body", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract area() {}
+}
+`
+		runProgramAndExpectError(t, program, "Expect ';' after abstract method declaration.", "Abstract method with a body")
+	})
+
+	t.Run("Abstract init", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    abstract init();
+}
+`
+		runProgramAndExpectError(t, program, "init can't be abstract.", "Abstract init")
+	})
+
+	t.Run("Abstract class method", func(t *testing.T) {
+		program := `
+abstract class Shape {
+    class abstract create();
+}
+`
+		runProgramAndExpectError(t, program, "Class methods can't be abstract.", "Abstract class method")
+	})
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// astNode is a generic representation of a node in the AST, used to print the tree
+// produced by the parser. Field values are one of: Token, []Token, *astNode,
+// []*astNode, astLiteral, bool or astDepth.
+type astNode struct {
+	kind   string
+	fields []astField
+}
+
+type astField struct {
+	name  string
+	value any
+}
+
+// astLiteral wraps the value of a literal expression, so it can be told apart from
+// other field values
+type astLiteral struct {
+	value any
+}
+
+// astDepth is the number of scopes between a variable reference and the scope in
+// which the variable is declared, as computed by the resolver. A depth of -1 means
+// the variable is global.
+type astDepth int
+
+const astDepthGlobal astDepth = -1
+
+// AstPrinter converts the Stmt/Expr tree produced by the Parser into astNodes. If it
+// has an interpreter, the scope depths computed by the resolver are included for
+// variable references.
+type AstPrinter struct {
+	interpreter *Interpreter
+	node        *astNode // most recently built statement node
+}
+
+func NewAstPrinter(interpreter *Interpreter) *AstPrinter {
+	return &AstPrinter{interpreter: interpreter}
+}
+
+func (a *AstPrinter) stmtNodes(statements []Stmt) []*astNode {
+	nodes := make([]*astNode, 0, len(statements))
+	for _, stmt := range statements {
+		nodes = append(nodes, a.stmtNode(stmt))
+	}
+	return nodes
+}
+
+func (a *AstPrinter) stmtNode(stmt Stmt) *astNode {
+	if stmt == nil {
+		return nil
+	}
+	_ = stmt.Accept(a)
+	return a.node
+}
+
+func (a *AstPrinter) exprNode(expr Expr) *astNode {
+	if expr == nil {
+		return nil
+	}
+	node, _ := expr.Accept(a)
+	return node.(*astNode)
+}
+
+func (a *AstPrinter) exprNodes(exprs []Expr) []*astNode {
+	nodes := make([]*astNode, 0, len(exprs))
+	for _, expr := range exprs {
+		nodes = append(nodes, a.exprNode(expr))
+	}
+	return nodes
+}
+
+// depth returns the resolved scope depth of a variable reference, or nil if the
+// printer isn't showing depths
+func (a *AstPrinter) depth(expr Expr) []astField {
+	if a.interpreter == nil {
+		return nil
+	}
+	if distance, ok := a.interpreter.locals[expr]; ok {
+		return []astField{{"depth", astDepth(distance)}}
+	}
+	return []astField{{"depth", astDepthGlobal}}
+}
+
+func (a *AstPrinter) setNode(kind string, fields ...astField) error {
+	a.node = &astNode{kind, fields}
+	return nil
+}
+
+func newAstNode(kind string, fields ...astField) (any, error) {
+	return &astNode{kind, fields}, nil
+}
+
+func (a *AstPrinter) VisitExpressionStmt(stmt *ExpressionStmt) error {
+	return a.setNode("expression", astField{"expression", a.exprNode(stmt.expression)})
+}
+
+func (a *AstPrinter) VisitFunctionStmt(stmt *FunctionStmt) error {
+	return a.setNode("function",
+		astField{"name", stmt.functionName},
+		astField{"getter", stmt.isGetter},
+		astField{"setter", stmt.isSetter},
+		astField{"abstract", stmt.isAbstract},
+		astField{"inner", stmt.usesInner},
+		astField{"params", stmt.params},
+		astField{"body", a.stmtNodes(stmt.body)})
+}
+
+func (a *AstPrinter) VisitClassStmt(stmt *ClassStmt) error {
+	var superclass *astNode
+	if stmt.superclass != nil {
+		superclass = a.exprNode(stmt.superclass)
+	}
+	methods := make([]*astNode, 0, len(stmt.methods))
+	for _, method := range stmt.methods {
+		methods = append(methods, a.stmtNode(method))
+	}
+	fields := []astField{
+		{"name", stmt.className},
+		{"abstract", stmt.isAbstract},
+		{"sealed", stmt.sealed},
+		{"superclass", superclass},
+	}
+	// Only classes that use traits list them, to keep the tree small
+	if len(stmt.traits) > 0 {
+		traits := make([]*astNode, 0, len(stmt.traits))
+		for _, trait := range stmt.traits {
+			traits = append(traits, a.exprNode(trait))
+		}
+		fields = append(fields, astField{"traits", traits})
+	}
+	// Only classes that declare fields list them, to keep the tree small
+	if len(stmt.fields) > 0 {
+		declaredFields := make([]*astNode, 0, len(stmt.fields))
+		for _, field := range stmt.fields {
+			declaredFields = append(declaredFields, a.stmtNode(field))
+		}
+		fields = append(fields, astField{"fields", declaredFields})
+	}
+	fields = append(fields, astField{"methods", methods})
+	// Only classes that have class methods list them, to keep the tree small
+	if len(stmt.classMethods) > 0 {
+		classMethods := make([]*astNode, 0, len(stmt.classMethods))
+		for _, method := range stmt.classMethods {
+			classMethods = append(classMethods, a.stmtNode(method))
+		}
+		fields = append(fields, astField{"classMethods", classMethods})
+	}
+	return a.setNode("class", fields...)
+}
+
+func (a *AstPrinter) VisitTraitStmt(stmt *TraitStmt) error {
+	methods := make([]*astNode, 0, len(stmt.methods))
+	for _, method := range stmt.methods {
+		methods = append(methods, a.stmtNode(method))
+	}
+	return a.setNode("trait",
+		astField{"name", stmt.traitName},
+		astField{"methods", methods})
+}
+
+func (a *AstPrinter) VisitIfStmt(stmt *IfStmt) error {
+	condition := a.exprNode(stmt.condition)
+	thenBranch := a.stmtNode(stmt.thenBranch)
+	elseBranch := a.stmtNode(stmt.elseBranch)
+	return a.setNode("if",
+		astField{"condition", condition},
+		astField{"then", thenBranch},
+		astField{"else", elseBranch})
+}
+
+func (a *AstPrinter) VisitPrintStmt(stmt *PrintStmt) error {
+	return a.setNode("print", astField{"expression", a.exprNode(stmt.expression)})
+}
+
+func (a *AstPrinter) VisitWhileStmt(stmt *WhileStmt) error {
+	condition := a.exprNode(stmt.condition)
+	body := a.stmtNode(stmt.body)
+	return a.setNode("while", astField{"condition", condition}, astField{"body", body})
+}
+
+func (a *AstPrinter) VisitReturnStmt(stmt *ReturnStmt) error {
+	return a.setNode("return", astField{"keyword", stmt.keyword}, astField{"value", a.exprNode(stmt.returnValue)})
+}
+
+func (a *AstPrinter) VisitBlockStmt(stmt *BlockStmt) error {
+	return a.setNode("block", astField{"statements", a.stmtNodes(stmt.statements)})
+}
+
+func (a *AstPrinter) VisitVarStmt(stmt *VarStmt) error {
+	return a.setNode("var", astField{"name", stmt.variable}, astField{"initializer", a.exprNode(stmt.initializer)})
+}
+
+func (a *AstPrinter) VisitAssignExpr(expr *AssignExpr) (any, error) {
+	fields := []astField{{"name", expr.variable}, {"value", a.exprNode(expr.value)}}
+	return newAstNode("assign", append(fields, a.depth(expr)...)...)
+}
+
+func (a *AstPrinter) VisitCallExpr(expr *CallExpr) (any, error) {
+	return newAstNode("call",
+		astField{"callee", a.exprNode(expr.Callee)},
+		astField{"paren", expr.Paren},
+		astField{"arguments", a.exprNodes(expr.Arguments)})
+}
+
+func (a *AstPrinter) VisitPropGetExpr(expr *PropGetExpr) (any, error) {
+	return newAstNode("get", astField{"object", a.exprNode(expr.object)}, astField{"name", expr.propName})
+}
+
+func (a *AstPrinter) VisitPropSetExpr(expr *PropSetExpr) (any, error) {
+	return newAstNode("set",
+		astField{"object", a.exprNode(expr.object)},
+		astField{"name", expr.propName},
+		astField{"value", a.exprNode(expr.propValue)})
+}
+
+func (a *AstPrinter) VisitBinaryExpr(expr *BinaryExpr) (any, error) {
+	return newAstNode("binary",
+		astField{"operator", expr.Operator},
+		astField{"left", a.exprNode(expr.Left)},
+		astField{"right", a.exprNode(expr.Right)})
+}
+
+func (a *AstPrinter) VisitGroupingExpr(expr *GroupingExpr) (any, error) {
+	return newAstNode("grouping", astField{"expression", a.exprNode(expr.Expression)})
+}
+
+func (a *AstPrinter) VisitLiteralExpr(expr *LiteralExpr) (any, error) {
+	return newAstNode("literal", astField{"value", astLiteral{expr.Value}})
+}
+
+func (a *AstPrinter) VisitLogicalExpr(expr *LogicalExpr) (any, error) {
+	return newAstNode("logical",
+		astField{"operator", expr.Operator},
+		astField{"left", a.exprNode(expr.Left)},
+		astField{"right", a.exprNode(expr.Right)})
+}
+
+func (a *AstPrinter) VisitUnaryExpr(expr *UnaryExpr) (any, error) {
+	return newAstNode("unary", astField{"operator", expr.Operator}, astField{"right", a.exprNode(expr.Right)})
+}
+
+func (a *AstPrinter) VisitVariableExpr(expr *VariableExpr) (any, error) {
+	return newAstNode("variable", append([]astField{{"name", expr.variable}}, a.depth(expr)...)...)
+}
+
+func (a *AstPrinter) VisitThisExpr(expr *ThisExpr) (any, error) {
+	return newAstNode("this", append([]astField{{"keyword", expr.keyword}}, a.depth(expr)...)...)
+}
+
+func (a *AstPrinter) VisitSuperExpr(expr *SuperExpr) (any, error) {
+	fields := []astField{{"keyword", expr.keyword}, {"method", expr.method}}
+	return newAstNode("super", append(fields, a.depth(expr)...)...)
+}
+
+func (a *AstPrinter) VisitInnerExpr(expr *InnerExpr) (any, error) {
+	arguments := make([]*astNode, 0, len(expr.arguments))
+	for _, arg := range expr.arguments {
+		arguments = append(arguments, a.exprNode(arg))
+	}
+	return newAstNode("inner", astField{"keyword", expr.keyword}, astField{"arguments", arguments})
+}
+
+func (a *AstPrinter) VisitIndexExpr(expr *IndexExpr) (any, error) {
+	return newAstNode("index",
+		astField{"bracket", expr.bracket},
+		astField{"object", a.exprNode(expr.object)},
+		astField{"index", a.exprNode(expr.index)})
+}
+
+// ----------------------------------------------------------------------------
+// S-expression output
+// ----------------------------------------------------------------------------
+
+// sexpr renders a list of nodes as S-expressions. Tokens are printed with their
+// positions as lexeme@line:column, and nodes are split across lines, with each child
+// on its own line, if they don't fit on a single line eg
+//
+//	(var total@1:5
+//	  (binary +@1:24
+//	    (call )@1:22
+//	      (variable compute@1:13)
+//	      (arguments (literal 1)))
+//	    (literal 2)))
+func sexpr(nodes []*astNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		sb.WriteString(node.sexpr(0))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (n *astNode) sexpr(indent int) string {
+	if n == nil {
+		return "nil"
+	}
+
+	// Scalar fields go on the same line as the node kind, child nodes on separate lines
+	header := []string{n.kind}
+	children := make([]string, 0)
+	for _, field := range n.fields {
+		switch value := field.value.(type) {
+		case Token:
+			header = append(header, sexprToken(value))
+		case []Token:
+			if value == nil { // eg parameter list of a getter
+				continue
+			}
+			tokens := []string{field.name}
+			for _, token := range value {
+				tokens = append(tokens, sexprToken(token))
+			}
+			header = append(header, "("+strings.Join(tokens, " ")+")")
+		case astLiteral:
+			header = append(header, sexprLiteral(value.value))
+		case bool:
+			if value {
+				header = append(header, field.name)
+			}
+		case astDepth:
+			if value == astDepthGlobal {
+				header = append(header, "depth=global")
+			} else {
+				header = append(header, fmt.Sprintf("depth=%d", value))
+			}
+		case *astNode:
+			if value != nil {
+				children = append(children, value.sexpr(indent+1))
+			}
+		case []*astNode:
+			items := make([]string, 0, len(value))
+			for _, child := range value {
+				items = append(items, child.sexpr(indent+2))
+			}
+			children = append(children, sexprList(field.name, items, indent+1))
+		}
+	}
+
+	return sexprList(strings.Join(header, " "), children, indent)
+}
+
+// sexprList renders a parenthesized list, keeping it on a single line if all its
+// items fit, or putting each item on its own line otherwise
+func sexprList(header string, items []string, indent int) string {
+	text := "(" + header
+	if len(items) == 0 {
+		return text + ")"
+	}
+
+	oneLine := text + " " + strings.Join(items, " ") + ")"
+	if !strings.Contains(oneLine, "\n") && len(oneLine) <= 60 {
+		return oneLine
+	}
+	for _, item := range items {
+		text += "\n" + strings.Repeat("  ", indent+1) + item
+	}
+	return text + ")"
+}
+
+func sexprToken(token Token) string {
+	return fmt.Sprintf("%s@%d:%d", token.lexeme, token.startLine, token.column)
+}
+
+func sexprLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// JSON output
+// ----------------------------------------------------------------------------
+
+// jsonValue converts an astNode into values that encoding/json can marshal, with
+// the node kind always appearing as the "type" property
+func (n *astNode) jsonValue() any {
+	if n == nil {
+		return nil
+	}
+
+	object := orderedJSONObject{{"type", n.kind}}
+	for _, field := range n.fields {
+		var value any
+		switch v := field.value.(type) {
+		case Token:
+			value = jsonToken(v)
+		case []Token:
+			if v != nil {
+				tokens := make([]any, 0, len(v))
+				for _, token := range v {
+					tokens = append(tokens, jsonToken(token))
+				}
+				value = tokens
+			}
+		case astLiteral:
+			value = v.value
+		case astDepth:
+			if v == astDepthGlobal {
+				value = "global"
+			} else {
+				value = int(v)
+			}
+		case *astNode:
+			value = v.jsonValue()
+		case []*astNode:
+			nodes := make([]any, 0, len(v))
+			for _, child := range v {
+				nodes = append(nodes, child.jsonValue())
+			}
+			value = nodes
+		default:
+			value = v
+		}
+		object = append(object, orderedJSONField{field.name, value})
+	}
+	return object
+}
+
+func jsonToken(token Token) any {
+	return orderedJSONObject{
+		{"lexeme", token.lexeme},
+		{"line", token.startLine},
+		{"column", token.column},
+	}
+}
+
+// orderedJSONObject is a JSON object whose properties are output in the order they
+// were added, rather than sorted as encoding/json does for maps
+type orderedJSONObject []orderedJSONField
+
+type orderedJSONField struct {
+	name  string
+	value any
+}
+
+func (o orderedJSONObject) MarshalJSON() ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, field := range o {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		name, _ := marshalJSON(field.name, "")
+		value, err := marshalJSON(field.value, "")
+		if err != nil {
+			return nil, err
+		}
+		sb.Write(name)
+		sb.WriteString(":")
+		sb.Write(value)
+	}
+	sb.WriteString("}")
+	return []byte(sb.String()), nil
+}
+
+// marshalJSON is like json.MarshalIndent, but doesn't escape '<', '>' and '&', which
+// are common in operators
+func marshalJSON(value any, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func astJSON(nodes []*astNode) (string, error) {
+	values := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, node.jsonValue())
+	}
+	data, err := marshalJSON(values, "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// ----------------------------------------------------------------------------
+// 'glox ast' command
+// ----------------------------------------------------------------------------
+
+// parseSource scans and parses a complete program, optionally running the resolver
+// over it with the supplied lint rules. Any errors found are returned as diagnostics.
+func parseSource(source string, resolve bool, lintConfig *LintConfig) ([]Stmt, *Interpreter, []diagnostic, bool) {
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, source)
+	tokens := scanner.scanTokens()
+
+	parser := NewParser(collector, tokens)
+	statements, err := parser.parse()
+	if err != nil || collector.hadError() {
+		return nil, nil, collector.diagnostics, false
+	}
+
+	interpreter := NewInterpreter(collector)
+	if resolve {
+		resolver := NewResolver(collector, interpreter)
+		resolver.linter = NewLinter(collector, lintConfig, scanner.lintDirectives, parser)
+		if err := resolver.resolveStmts(statements); err != nil || collector.hadError() {
+			return nil, nil, collector.diagnostics, false
+		}
+	}
+	return statements, interpreter, collector.diagnostics, true
+}
+
+// astCommand implements 'glox ast', which prints the syntax tree of a program
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	format := flags.String("format", "sexpr", "output format: sexpr or json")
+	depths := flags.Bool("depths", false, "include the scope depths computed by the resolver")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox ast [--format=sexpr|json] [--depths] file.lox")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 || (*format != "sexpr" && *format != "json") {
+		flags.Usage()
+		return 64
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox ast: %v\n", err)
+		return 66
+	}
+
+	statements, interpreter, diagnostics, ok := parseSource(string(data), *depths, disabledLintConfig())
+	if !ok {
+		for _, diag := range diagnostics {
+			fmt.Fprintln(os.Stderr, diag)
+		}
+		return 65
+	}
+
+	printer := NewAstPrinter(nil)
+	if *depths {
+		printer.interpreter = interpreter
+	}
+	nodes := printer.stmtNodes(statements)
+
+	if *format == "json" {
+		output, err := astJSON(nodes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox ast: %v\n", err)
+			return 70
+		}
+		fmt.Print(output)
+	} else {
+		fmt.Print(sexpr(nodes))
+	}
+	return 0
+}
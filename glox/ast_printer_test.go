@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// AST PRINTER TESTS
+// ============================================================================
+
+func parseForAstTest(t *testing.T, source string, resolve bool) []*astNode {
+	t.Helper()
+	statements, interpreter, diagnostics, ok := parseSource(source, resolve, disabledLintConfig())
+	if !ok {
+		t.Fatalf("Unexpected errors: %v", diagnostics)
+	}
+	printer := NewAstPrinter(nil)
+	if resolve {
+		printer.interpreter = interpreter
+	}
+	return printer.stmtNodes(statements)
+}
+
+func TestAstSexpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			"Precedence",
+			"print 1 + 2 * 3;",
+			`(print
+  (binary +@1:9
+    (literal 1)
+    (binary *@1:13 (literal 2) (literal 3))))
+`,
+		},
+		{
+			"Assignment is right associative",
+			"a = b = 1;",
+			"(expression (assign a@1:1 (assign b@1:5 (literal 1))))\n",
+		},
+		{
+			"Literals",
+			`print "hi"; print nil; print true;`,
+			"(print (literal \"hi\"))\n(print (literal nil))\n(print (literal true))\n",
+		},
+		{
+			"For loop desugaring",
+			"for (var i = 0; i < 1; i = i + 1) print i;",
+			`(block
+  (statements
+    (var i@1:10 (literal 0))
+    (while
+      (binary <@1:19 (variable i@1:17) (literal 1))
+      (block
+        (statements
+          (print (variable i@1:41))
+          (expression
+            (assign i@1:24
+              (binary +@1:30 (variable i@1:28) (literal 1)))))))))
+`,
+		},
+		{
+			"Getter and property access",
+			"class A { x { return this.y; } }",
+			`(class A@1:7
+  (methods
+    (function x@1:11 getter
+      (body (return return@1:15 (get y@1:27 (this this@1:22)))))))
+`,
+		},
+		{
+			"Declared fields",
+			"sealed class A { var x = 1; var y; }",
+			`(class A@1:14 sealed
+  (fields (var x@1:22 (literal 1)) (var y@1:33))
+  (methods))
+`,
+		},
+		{
+			"Trait",
+			"trait T { m() {} } class A with T {}",
+			`(trait T@1:7 (methods (function m@1:11 (params) (body))))
+(class A@1:26 (traits (variable T@1:33)) (methods))
+`,
+		},
+		{
+			"Abstract class",
+			"abstract class A { abstract x(); }",
+			`(class A@1:16 abstract
+  (methods (function x@1:29 abstract (params) (body))))
+`,
+		},
+		{
+			"Index",
+			"v[1];",
+			`(expression (index ]@1:4 (variable v@1:1) (literal 1)))
+`,
+		},
+		{
+			"Inner",
+			"class A { m() { inner(1); } }",
+			`(class A@1:7
+  (methods
+    (function m@1:11 inner (params)
+      (body
+        (expression (inner inner@1:17 (arguments (literal 1))))))))
+`,
+		},
+		{
+			"Setter",
+			"class A { set x(v) {} }",
+			`(class A@1:7
+  (methods (function x@1:15 setter (params v@1:17) (body))))
+`,
+		},
+		{
+			"Class methods",
+			"class A { m() {} class make() {} }",
+			`(class A@1:7
+  (methods (function m@1:11 (params) (body)))
+  (classMethods (function make@1:24 (params) (body))))
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := sexpr(parseForAstTest(t, test.source, false))
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestAstScopeDepths(t *testing.T) {
+	source := `
+var g = 1;
+fun f(a) {
+  var b = a;
+  { print a + b + g; }
+}
+`
+	output := sexpr(parseForAstTest(t, source, true))
+	for _, expected := range []string{
+		"(variable a@4:11 depth=0)",
+		"(variable a@5:11 depth=1)",
+		"(variable b@5:15 depth=1)",
+		"(variable g@5:19 depth=global)",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %s, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestAstJSON(t *testing.T) {
+	output, err := astJSON(parseForAstTest(t, "var x = -1;", true))
+	assertNoError(t, err, "Marshal JSON")
+
+	// Properties must appear in a fixed order, with the node type first
+	if !strings.HasPrefix(strings.TrimSpace(output), "[\n  {\n    \"type\": \"var\",\n    \"name\": {") {
+		t.Errorf("Unexpected property order:\n%s", output)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("Output isn't valid JSON: %v", err)
+	}
+	assertEqual(t, 1, len(decoded), "Statement count")
+	name := decoded[0]["name"].(map[string]any)
+	assertEqual(t, "x", name["lexeme"], "Name lexeme")
+	assertEqual(t, float64(1), name["line"], "Name line")
+	assertEqual(t, float64(5), name["column"], "Name column")
+
+	initializer := decoded[0]["initializer"].(map[string]any)
+	assertEqual(t, "unary", initializer["type"], "Initializer type")
+	right := initializer["right"].(map[string]any)
+	assertEqual(t, float64(1), right["value"], "Literal value")
+}
+
+func TestAstJSONOperators(t *testing.T) {
+	output, err := astJSON(parseForAstTest(t, "print 1 <= 2 and 3 > 4;", true))
+	assertNoError(t, err, "Marshal JSON")
+	assertContains(t, output, `"lexeme": "<="`)
+	assertContains(t, output, `"lexeme": ">"`)
+}
+
+func TestAstCommand(t *testing.T) {
+	t.Run("Prints tree", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.lox")
+		writeTestFile(t, path, "{ var a = 1; print a; }")
+		stdout, code := captureCommandOutput(t, func() int { return astCommand([]string{"--depths", path}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertContains(t, stdout, "(print (variable a@1:20 depth=0))")
+	})
+
+	t.Run("Parse errors fail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.lox")
+		writeTestFile(t, path, "print ;")
+		_, code := captureCommandOutput(t, func() int { return astCommand([]string{path}) })
+		assertEqual(t, 65, code, "Exit code")
+	})
+
+	t.Run("Unknown format is rejected", func(t *testing.T) {
+		_, code := captureCommandOutput(t, func() int { return astCommand([]string{"--format=xml", "x.lox"}) })
+		assertEqual(t, 64, code, "Exit code")
+	})
+}
@@ -14,4 +14,20 @@ func (c clockFn) arity() int {
 
 func (c clockFn) call(i *Interpreter, arguments []any) (any, error) {
 	return float64(time.Now().UnixMilli()), nil 
-}
\ No newline at end of file
+}
+
+// args() returns the command-line arguments passed to the script, as a list object
+// (see newLoxList)
+type argsFn struct {}
+
+func (a argsFn) arity() int {
+	return 0
+}
+
+func (a argsFn) call(i *Interpreter, arguments []any) (any, error) {
+	values := make([]any, 0, len(i.scriptArgs))
+	for _, arg := range i.scriptArgs {
+		values = append(values, arg)
+	}
+	return newLoxList(i, "Args", values), nil
+}
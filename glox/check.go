@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checkSource parses and resolves a script without running it, returning the errors
+// and lint warnings found
+func checkSource(source string, config *ProjectConfig) []diagnostic {
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, source)
+	tokens := scanner.scanTokens()
+	parser := NewParser(collector, tokens)
+	statements, _ := parser.parse()
+	if !collector.hadError() {
+		resolver := NewResolver(collector, NewInterpreter(collector))
+		resolver.linter = NewLinter(collector, config.lint, scanner.lintDirectives, parser)
+		resolver.resolveStmts(statements)
+	}
+	return collector.diagnostics
+}
+
+// checkCommand implements 'glox check', which reports the errors and lint warnings in
+// the supplied files and directories without running them
+func checkCommand(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox check path...")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	files, err := collectLoxFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox check: %v\n", err)
+		return 66
+	}
+	return checkFiles(os.Stderr, files)
+}
+
+// checkFiles checks each file, printing what it finds, and returns the exit code
+func checkFiles(out io.Writer, files []string) int {
+	exitCode := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox check: %v\n", err)
+			return 66
+		}
+		config, err := loadProjectConfig(filepath.Dir(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox check: %v\n", err)
+			return 64
+		}
+
+		for _, diag := range checkSource(string(data), config) {
+			fmt.Fprintf(out, "%s:%v\n", path, diag)
+			if diag.kind != diagnosticWarning {
+				exitCode = 65
+			}
+		}
+	}
+	return exitCode
+}
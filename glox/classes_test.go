@@ -199,22 +199,13 @@ print copy.x;
 }
 
 func TestClassErrors(t *testing.T) {
-	t.Run("Get property on non-instance", func(t *testing.T) {
+	t.Run("Get undefined property on class", func(t *testing.T) {
 		program := `
 class Foo {}
 var notInstance = Foo;
 print notInstance.field;
 `
-		runProgramAndExpectError(t, program, "Only instances have properties", "Get property on non-instance")
-	})
-
-	t.Run("Set property on non-instance", func(t *testing.T) {
-		program := `
-class Foo {}
-var notInstance = Foo;
-notInstance.field = "value";
-`
-		runProgramAndExpectError(t, program, "Only instances have fields", "Set property on non-instance")
+		runProgramAndExpectError(t, program, "undefined property name field", "Get undefined property on class")
 	})
 
 	t.Run("Get property on nil", func(t *testing.T) {
@@ -537,6 +528,44 @@ method();
 		expected := []string{"counting"}
 		runProgramAndCheckOutput(t, program, expected, "Method returned from function")
 	})
+
+	t.Run("Bound method equality", func(t *testing.T) {
+		program := `
+class Foo {
+    a() {}
+    b() {}
+    class make() {}
+}
+var foo = Foo();
+var other = Foo();
+print foo.a == foo.a;
+print foo.a != foo.a;
+print foo.a == foo.b;
+print foo.a == other.a;
+print Foo.make == Foo.make;
+print foo.a == 1;
+`
+		expected := []string{"true", "false", "false", "false", "true", "false"}
+		runProgramAndCheckOutput(t, program, expected, "Bound method equality")
+	})
+
+	t.Run("Bound method keeps its receiver", func(t *testing.T) {
+		program := `
+class Foo {
+    init(name) { this.name = name; }
+    show() { print this.name; }
+}
+var show = Foo("first").show;
+var second = Foo("second");
+second.show = show;
+second.show();
+print show;
+fun plain() {}
+print plain;
+`
+		expected := []string{"first", "<fn show>", "<fn plain>"}
+		runProgramAndCheckOutput(t, program, expected, "Bound method keeps its receiver")
+	})
 }
 
 func TestMethodErrors(t *testing.T) {
@@ -575,7 +604,7 @@ foo.method(1, 2);
 		runProgramAndExpectError(t, program, "Expected 1 arguments but got 2", "Call method with too many arguments")
 	})
 
-	t.Run("Call method on non-instance", func(t *testing.T) {
+	t.Run("Call instance method on class", func(t *testing.T) {
 		program := `
 class Foo {
     method() {}
@@ -583,7 +612,7 @@ class Foo {
 var notInstance = Foo;
 notInstance.method();
 `
-		runProgramAndExpectError(t, program, "Only instances have properties", "Call method on non-instance")
+		runProgramAndExpectError(t, program, "undefined property name method", "Call instance method on class")
 	})
 
 	t.Run("Duplicate method names", func(t *testing.T) {
@@ -1352,7 +1381,7 @@ print rect.area;
 		runProgramAndCheckOutput(t, program, expected, "Getter with computed value")
 	})
 
-	t.Run("Field shadows getter", func(t *testing.T) {
+	t.Run("Getter without a setter is read-only", func(t *testing.T) {
 		program := `
 class Foo {
     value {
@@ -1361,10 +1390,8 @@ class Foo {
 }
 var foo = Foo();
 foo.value = "field";
-print foo.value;
 `
-		expected := []string{"field"}
-		runProgramAndCheckOutput(t, program, expected, "Field shadows getter")
+		runProgramAndExpectError(t, program, "Can't assign to read-only property 'value'.", "Getter without a setter is read-only")
 	})
 
 	t.Run("Getter when field not set", func(t *testing.T) {
@@ -1459,3 +1486,667 @@ class Foo {
 		runProgramAndExpectError(t, program, "init function must have parameter list", "Init as getter")
 	})
 }
+
+func TestClassMethods(t *testing.T) {
+	t.Run("Call class method", func(t *testing.T) {
+		program := `
+class Math {
+    class square(n) {
+        return n * n;
+    }
+}
+print Math.square(3);
+`
+		expected := []string{"9"}
+		runProgramAndCheckOutput(t, program, expected, "Call class method")
+	})
+
+	t.Run("This in class method is the class", func(t *testing.T) {
+		program := `
+class Point {
+    init(x, y) {
+        this.x = x;
+        this.y = y;
+    }
+    class origin() {
+        return this(0, 0);
+    }
+    class name {
+        return "Point";
+    }
+}
+var p = Point.origin();
+print p.x + p.y;
+print Point.name;
+`
+		expected := []string{"0", "Point"}
+		runProgramAndCheckOutput(t, program, expected, "This in class method is the class")
+	})
+
+	t.Run("Class and instance methods with the same name", func(t *testing.T) {
+		program := `
+class Foo {
+    describe() {
+        return "instance";
+    }
+    class describe() {
+        return "class";
+    }
+}
+print Foo.describe();
+print Foo().describe();
+`
+		expected := []string{"class", "instance"}
+		runProgramAndCheckOutput(t, program, expected, "Class and instance methods with the same name")
+	})
+
+	t.Run("Static fields", func(t *testing.T) {
+		program := `
+class Counter {
+    init() {
+        Counter.count = Counter.count + 1;
+    }
+    class reset() {
+        this.count = 0;
+    }
+}
+Counter.reset();
+Counter();
+Counter();
+print Counter.count;
+`
+		expected := []string{"2"}
+		runProgramAndCheckOutput(t, program, expected, "Static fields")
+	})
+
+	t.Run("Class methods and static fields are inherited", func(t *testing.T) {
+		program := `
+class Shape {
+    class create() {
+        return this();
+    }
+    class kind() {
+        return "shape";
+    }
+    area() {
+        return 0;
+    }
+}
+Shape.sides = 0;
+class Square < Shape {
+    class kind() {
+        return "square, a " + super.kind();
+    }
+    area() {
+        return 4;
+    }
+}
+print Square.create().area();
+print Square.kind();
+print Square.sides;
+Square.sides = 4;
+print Square.sides;
+print Shape.sides;
+`
+		expected := []string{"4", "square, a shape", "0", "4", "0"}
+		runProgramAndCheckOutput(t, program, expected, "Class methods and static fields are inherited")
+	})
+
+	t.Run("Class method as value", func(t *testing.T) {
+		program := `
+class Math {
+    class double(n) {
+        return n * 2;
+    }
+}
+var double = Math.double;
+print double(21);
+`
+		expected := []string{"42"}
+		runProgramAndCheckOutput(t, program, expected, "Class method as value")
+	})
+
+	t.Run("Instance can't call class method", func(t *testing.T) {
+		program := `
+class Math {
+    class square(n) {
+        return n * n;
+    }
+}
+Math().square(2);
+`
+		runProgramAndExpectError(t, program, "undefined property name square", "Instance can't call class method")
+	})
+
+	t.Run("Duplicate class method names", func(t *testing.T) {
+		program := `
+class Foo {
+    class bar() {}
+    class bar() {}
+}
+`
+		runProgramAndExpectError(t, program, "class method with this name already exists", "Duplicate class method names")
+	})
+}
+
+func TestSetterFunctions(t *testing.T) {
+	t.Run("Setter is called on assignment", func(t *testing.T) {
+		program := `
+class Temperature {
+    init() {
+        this.celsius = 0;
+    }
+    fahrenheit {
+        return this.celsius * 9 / 5 + 32;
+    }
+    set fahrenheit(value) {
+        this.celsius = (value - 32) * 5 / 9;
+    }
+}
+var t = Temperature();
+t.fahrenheit = 212;
+print t.celsius;
+print t.fahrenheit;
+`
+		expected := []string{"100", "212"}
+		runProgramAndCheckOutput(t, program, expected, "Setter is called on assignment")
+	})
+
+	t.Run("Assignment evaluates to the assigned value", func(t *testing.T) {
+		program := `
+class Foo {
+    set value(v) {
+        return 42;
+    }
+}
+var foo = Foo();
+print foo.value = "assigned";
+`
+		expected := []string{"assigned"}
+		runProgramAndCheckOutput(t, program, expected, "Assignment evaluates to the assigned value")
+	})
+
+	t.Run("Setter can set its own field", func(t *testing.T) {
+		program := `
+class Person {
+    set name(value) {
+        this.name = "Dr " + value;
+    }
+}
+var p = Person();
+p.name = "Who";
+print p.name;
+p.name = "No";
+print p.name;
+`
+		expected := []string{"Dr Who", "Dr No"}
+		runProgramAndCheckOutput(t, program, expected, "Setter can set its own field")
+	})
+
+	t.Run("Setters are inherited", func(t *testing.T) {
+		program := `
+class Base {
+    set size(value) {
+        this.area = value * value;
+    }
+}
+class Square < Base {}
+var s = Square();
+s.size = 3;
+print s.area;
+`
+		expected := []string{"9"}
+		runProgramAndCheckOutput(t, program, expected, "Setters are inherited")
+	})
+
+	t.Run("Class setter", func(t *testing.T) {
+		program := `
+class Config {
+    class set level(value) {
+        this.verbose = value > 1;
+    }
+}
+Config.level = 2;
+print Config.verbose;
+`
+		expected := []string{"true"}
+		runProgramAndCheckOutput(t, program, expected, "Class setter")
+	})
+
+	t.Run("Methods can be called set", func(t *testing.T) {
+		program := `
+class Map {
+    set(key, value) {
+        this.last = key + "=" + value;
+    }
+}
+class Flags {
+    set {
+        return "getter";
+    }
+}
+var m = Map();
+m.set("a", "b");
+print m.last;
+print Flags().set;
+`
+		expected := []string{"a=b", "getter"}
+		runProgramAndCheckOutput(t, program, expected, "Methods can be called set")
+	})
+}
+
+func TestSetterErrors(t *testing.T) {
+	t.Run("Setter with no parameters", func(t *testing.T) {
+		program := `
+class Foo {
+    set value() {}
+}
+`
+		runProgramAndExpectError(t, program, "A setter must have exactly one parameter.", "Setter with no parameters")
+	})
+
+	t.Run("Setter with two parameters", func(t *testing.T) {
+		program := `
+class Foo {
+    set value(a, b) {}
+}
+`
+		runProgramAndExpectError(t, program, "A setter must have exactly one parameter.", "Setter with two parameters")
+	})
+
+	t.Run("Setter without parameter list", func(t *testing.T) {
+		program := `
+class Foo {
+    set value {}
+}
+`
+		runProgramAndExpectError(t, program, "Expect '(' after setter name.", "Setter without parameter list")
+	})
+
+	t.Run("Duplicate setters", func(t *testing.T) {
+		program := `
+class Foo {
+    set value(v) {}
+    set value(v) {}
+}
+`
+		runProgramAndExpectError(t, program, "method with this name already exists", "Duplicate setters")
+	})
+
+	t.Run("Error in setter", func(t *testing.T) {
+		program := `
+class Foo {
+    set value(v) {
+        this.n = -v;
+    }
+}
+Foo().value = "x";
+`
+		runProgramAndExpectError(t, program, "must be a number", "Error in setter")
+	})
+}
+
+func TestPrivateMembers(t *testing.T) {
+	t.Run("Private fields and methods inside the class", func(t *testing.T) {
+		program := `
+class Counter {
+    init() {
+        this.#count = 0;
+    }
+    #bump() {
+        this.#count = this.#count + 1;
+    }
+    increment() {
+        this.#bump();
+        return this.#count;
+    }
+}
+var c = Counter();
+c.increment();
+print c.increment();
+`
+		expected := []string{"2"}
+		runProgramAndCheckOutput(t, program, expected, "Private fields and methods inside the class")
+	})
+
+	t.Run("Private class members", func(t *testing.T) {
+		program := `
+class Ids {
+    class #next {
+        return 7;
+    }
+    class reveal() {
+        return this.#next;
+    }
+}
+print Ids.reveal();
+`
+		expected := []string{"7"}
+		runProgramAndCheckOutput(t, program, expected, "Private class members")
+	})
+
+	t.Run("Subclass has its own private members", func(t *testing.T) {
+		program := `
+class Base {
+    init() {
+        this.#id = "base";
+    }
+    baseId() {
+        return this.#id;
+    }
+}
+class Derived < Base {
+    init() {
+        super.init();
+        this.#id = "derived";
+    }
+    derivedId() {
+        return this.#id;
+    }
+}
+var d = Derived();
+print d.baseId();
+print d.derivedId();
+`
+		expected := []string{"base", "derived"}
+		runProgramAndCheckOutput(t, program, expected, "Subclass has its own private members")
+	})
+
+	t.Run("Private and public members with the same name", func(t *testing.T) {
+		program := `
+class Foo {
+    init() {
+        this.#value = "private";
+        this.value = "public";
+    }
+    secret() {
+        return this.#value;
+    }
+}
+var foo = Foo();
+print foo.value;
+print foo.secret();
+`
+		expected := []string{"public", "private"}
+		runProgramAndCheckOutput(t, program, expected, "Private and public members with the same name")
+	})
+}
+
+func TestPrivateMemberErrors(t *testing.T) {
+	t.Run("Access outside of a class", func(t *testing.T) {
+		program := `
+class Foo {}
+print Foo().#secret;
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#secret' outside of a class.", "Access outside of a class")
+	})
+
+	t.Run("Assignment outside of a class", func(t *testing.T) {
+		program := `
+class Foo {}
+Foo().#secret = 1;
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#secret' outside of a class.", "Assignment outside of a class")
+	})
+
+	t.Run("Access through another object", func(t *testing.T) {
+		program := `
+class Foo {
+    same(other) {
+        return other.#secret;
+    }
+}
+`
+		runProgramAndExpectError(t, program, "Private member '#secret' can only be accessed through 'this'.", "Access through another object")
+	})
+
+	t.Run("Access from another class", func(t *testing.T) {
+		program := `
+class Foo {
+    init() {
+        this.#secret = 1;
+    }
+}
+class Bar {
+    peek() {
+        return this.#secret;
+    }
+}
+var peek = Bar().peek;
+var foo = Foo();
+foo.peek = peek;
+foo.peek();
+`
+		runProgramAndExpectError(t, program, "Undefined private member '#secret'.", "Access from another class")
+	})
+
+	t.Run("Access from a subclass", func(t *testing.T) {
+		program := `
+class Base {
+    init() {
+        this.#secret = 1;
+    }
+}
+class Derived < Base {
+    peek() {
+        return this.#secret;
+    }
+}
+Derived().peek();
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#secret' of class Base from subclass Derived.", "Access from a subclass")
+	})
+
+	t.Run("Private methods aren't inherited", func(t *testing.T) {
+		program := `
+class Base {
+    #helper() {}
+}
+class Derived < Base {
+    run() {
+        this.#helper();
+    }
+}
+Derived().run();
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#helper' of class Base from subclass Derived.", "Private methods aren't inherited")
+	})
+
+	t.Run("Access through super", func(t *testing.T) {
+		program := `
+class Base {
+    #helper() {}
+}
+class Derived < Base {
+    run() {
+        super.#helper();
+    }
+}
+`
+		runProgramAndExpectError(t, program, "Private member '#helper' can't be accessed through 'super'.", "Access through super")
+	})
+
+	t.Run("Private variable name", func(t *testing.T) {
+		program := `var #secret = 1;`
+		runProgramAndExpectError(t, program, "Private names can only be used for class members.", "Private variable name")
+	})
+}
+
+func TestDeclaredFields(t *testing.T) {
+	t.Run("Fields are initialized before init", func(t *testing.T) {
+		program := `
+class Counter {
+    var count = 10;
+    var label;
+    init() {
+        print this.count;
+        print this.label;
+        this.count = this.count + 1;
+    }
+}
+print Counter().count;
+`
+		expected := []string{"10", "<nil>", "11"}
+		runProgramAndCheckOutput(t, program, expected, "Fields are initialized before init")
+	})
+
+	t.Run("Each instance gets its own initial value", func(t *testing.T) {
+		program := `
+var made = 0;
+class Ticket {
+    var number = made = made + 1;
+}
+print Ticket().number;
+print Ticket().number;
+`
+		expected := []string{"1", "2"}
+		runProgramAndCheckOutput(t, program, expected, "Each instance gets its own initial value")
+	})
+
+	t.Run("Initializers can use this", func(t *testing.T) {
+		program := `
+class Rect {
+    var width = 2;
+    var height = 3;
+    var area = this.width * this.height;
+}
+print Rect().area;
+`
+		expected := []string{"6"}
+		runProgramAndCheckOutput(t, program, expected, "Initializers can use this")
+	})
+
+	t.Run("Superclass fields are initialized first", func(t *testing.T) {
+		program := `
+class Base {
+    var name = "base";
+}
+class Derived < Base {
+    var description = "derived from " + this.name;
+}
+print Derived().description;
+`
+		expected := []string{"derived from base"}
+		runProgramAndCheckOutput(t, program, expected, "Superclass fields are initialized first")
+	})
+
+	t.Run("Private declared fields", func(t *testing.T) {
+		program := `
+class Account {
+    var #balance = 100;
+    balance {
+        return this.#balance;
+    }
+}
+print Account().balance;
+`
+		expected := []string{"100"}
+		runProgramAndCheckOutput(t, program, expected, "Private declared fields")
+	})
+
+	t.Run("Sealed class allows declared fields", func(t *testing.T) {
+		program := `
+sealed class Point {
+    var x = 0;
+    var y = 0;
+    init(x, y) {
+        this.x = x;
+        this.y = y;
+    }
+}
+class Point3 < Point {
+    var z = 0;
+}
+var p = Point3(1, 2);
+p.z = 3;
+print p.x + p.y + p.z;
+`
+		expected := []string{"6"}
+		runProgramAndCheckOutput(t, program, expected, "Sealed class allows declared fields")
+	})
+
+	t.Run("Sealed can be used as a name", func(t *testing.T) {
+		program := `
+var sealed = "yes";
+print sealed;
+`
+		expected := []string{"yes"}
+		runProgramAndCheckOutput(t, program, expected, "Sealed can be used as a name")
+	})
+}
+
+func TestDeclaredFieldErrors(t *testing.T) {
+	t.Run("Undeclared field on sealed class", func(t *testing.T) {
+		program := `
+sealed class Person {
+    var name;
+}
+var p = Person();
+p.nmae = "Bob";
+`
+		runProgramAndExpectError(t, program, "Can't assign undeclared field 'nmae' on instance of sealed class Person.", "Undeclared field on sealed class")
+	})
+
+	t.Run("Undeclared field in init of sealed class", func(t *testing.T) {
+		program := `
+sealed class Person {
+    init(name) {
+        this.name = name;
+    }
+}
+Person("Bob");
+`
+		runProgramAndExpectError(t, program, "Can't assign undeclared field 'name' on instance of sealed class Person.", "Undeclared field in init of sealed class")
+	})
+
+	t.Run("Subclass of sealed class is sealed", func(t *testing.T) {
+		program := `
+sealed class Base {}
+class Derived < Base {}
+Derived().extra = 1;
+`
+		runProgramAndExpectError(t, program, "Can't assign undeclared field 'extra' on instance of sealed class Derived.", "Subclass of sealed class is sealed")
+	})
+
+	t.Run("Undeclared private field on sealed class", func(t *testing.T) {
+		program := `
+sealed class Foo {
+    init() {
+        this.#secret = 1;
+    }
+}
+Foo();
+`
+		runProgramAndExpectError(t, program, "Can't assign undeclared field '#secret' on instance of sealed class Foo.", "Undeclared private field on sealed class")
+	})
+
+	t.Run("Duplicate fields", func(t *testing.T) {
+		program := `
+class Foo {
+    var x;
+    var x = 1;
+}
+`
+		runProgramAndExpectError(t, program, "field with this name already exists", "Duplicate fields")
+	})
+
+	t.Run("Field with the same name as a method", func(t *testing.T) {
+		program := `
+class Foo {
+    var size = 1;
+    size() {}
+}
+`
+		runProgramAndExpectError(t, program, "A field can't have the same name as a method.", "Field with the same name as a method")
+	})
+
+	t.Run("Error in field initializer", func(t *testing.T) {
+		program := `
+class Foo {
+    var x = -"one";
+}
+Foo();
+`
+		runProgramAndExpectError(t, program, "must be a number", "Error in field initializer")
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Name of the per-project configuration file. glox looks for it in the directory
+// of the script being run, and then in each of that directory's parents.
+const projectConfigFileName = "glox.json"
+
+// projectConfigFile mirrors the structure of the JSON config file eg
+//
+//	{
+//	    "lint": {
+//	        "unused-parameter": "off",
+//	        "shadowing": "warning"
+//	    }
+//	}
+type projectConfigFile struct {
+	Lint map[string]string `json:"lint"`
+}
+
+// ProjectConfig holds the settings read from a project config file
+type ProjectConfig struct {
+	lint *LintConfig
+}
+
+func NewProjectConfig() *ProjectConfig {
+	return &ProjectConfig{lint: NewLintConfig()}
+}
+
+// findProjectConfig walks up from the supplied directory looking for a project config
+// file, returning its path, or "" if there isn't one
+func findProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, projectConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir { // reached root of filesystem
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig reads the project config file applicable to the supplied
+// directory. If there's no config file, the default configuration is returned.
+func loadProjectConfig(dir string) (*ProjectConfig, error) {
+	config := NewProjectConfig()
+
+	path := findProjectConfig(dir)
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file projectConfigFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for rule, severity := range file.Lint {
+		if err = config.lint.setSeverity(rule, severity); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return config, nil
+}
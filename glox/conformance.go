@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The conformance runner runs Lox scripts annotated with comments giving their
+// expected output and errors, in the format used by the test suite for Crafting
+// Interpreters:
+//
+//	print 1 + 2; // expect: 3
+//	var a = "x" - 1; // expect runtime error: Operands must be numbers.
+//	print this; // Error at 'this': Can't use 'this' outside of a class.
+//	// [line 3] Error at end: Expect '}' after block.
+//
+// Each script is run and its output, errors and exit code are compared with the
+// expectations.
+
+var (
+	conformanceExpectOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	conformanceExpectError        = regexp.MustCompile(`// (Error.*)`)
+	conformanceExpectErrorLine    = regexp.MustCompile(`// \[((java|c) )?line (\d+)\] (Error.*)`)
+	conformanceExpectRuntimeError = regexp.MustCompile(`// expect runtime error: (.+)`)
+	conformanceNonTest            = regexp.MustCompile(`// nontest`)
+)
+
+// Limits that stop a script that recurses or loops forever from taking the runner
+// down with it
+const (
+	conformanceMaxDepth = 10000
+	conformanceTimeout  = 10 * time.Second
+)
+
+// conformanceExpectations are the results a script's comments say it should produce
+type conformanceExpectations struct {
+	output           []conformanceOutput
+	errors           []string // compile errors, as "[line N] Error at 'x': message"
+	runtimeError     string
+	runtimeErrorLine int
+	exitCode         int
+}
+
+// conformanceOutput is a line that a script is expected to print, and the line of
+// the script that expects it
+type conformanceOutput struct {
+	line int
+	text string
+}
+
+// conformanceResult is what a script actually produced
+type conformanceResult struct {
+	output           []string
+	errors           []string
+	runtimeError     string
+	runtimeErrorLine int
+	exitCode         int
+}
+
+// parseConformanceExpectations reads the expectations from a script's comments. It
+// returns nil if the script is marked as not being a test.
+func parseConformanceExpectations(source string) *conformanceExpectations {
+	expected := &conformanceExpectations{}
+	for n, text := range strings.Split(source, "\n") {
+		line := n + 1
+		if conformanceNonTest.MatchString(text) {
+			return nil
+		}
+
+		if match := conformanceExpectOutput.FindStringSubmatch(text); match != nil {
+			expected.output = append(expected.output, conformanceOutput{line, match[1]})
+		} else if match := conformanceExpectError.FindStringSubmatch(text); match != nil {
+			expected.errors = append(expected.errors, fmt.Sprintf("[line %d] %s", line, match[1]))
+			expected.exitCode = 65
+		} else if match := conformanceExpectErrorLine.FindStringSubmatch(text); match != nil {
+			// Errors that only the clox implementation reports don't apply to glox
+			if match[2] != "c" {
+				expected.errors = append(expected.errors, fmt.Sprintf("[line %s] %s", match[3], match[4]))
+				expected.exitCode = 65
+			}
+		} else if match := conformanceExpectRuntimeError.FindStringSubmatch(text); match != nil {
+			expected.runtimeError = match[1]
+			expected.runtimeErrorLine = line
+			expected.exitCode = 70
+		}
+	}
+	return expected
+}
+
+// conformanceLimits is an execution hook that aborts a script that recurses too
+// deeply or runs for too long
+type conformanceLimits struct {
+	interpreter *Interpreter
+	stmtLines   map[Stmt]int
+	deadline    time.Time
+}
+
+func (c *conformanceLimits) beforeStatement(stmt Stmt) error {
+	token := Token{token_type: EOF, line: c.stmtLines[stmt]}
+	if len(c.interpreter.frames) > conformanceMaxDepth {
+		return RuntimeError{token, "Stack overflow."}
+	}
+	if time.Now().After(c.deadline) {
+		return RuntimeError{token, fmt.Sprintf("Timed out after %v.", conformanceTimeout)}
+	}
+	return nil
+}
+
+// runConformanceScript runs a script, collecting its output and errors
+func runConformanceScript(source string, config *ProjectConfig) conformanceResult {
+	var result conformanceResult
+
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, source)
+	tokens := scanner.scanTokens()
+	parser := NewParser(collector, tokens)
+	statements, _ := parser.parse()
+
+	runtime := NewDiagnosticCollector()
+	interpreter := NewInterpreter(runtime)
+	if !collector.hadError() {
+		resolver := NewResolver(collector, interpreter)
+		resolver.linter = NewLinter(collector, config.lint, scanner.lintDirectives, parser)
+		resolver.resolveStmts(statements)
+	}
+	if collector.hadError() {
+		for _, diag := range collector.diagnostics {
+			if diag.kind != diagnosticWarning {
+				result.errors = append(result.errors, conformanceErrorText(diag))
+			}
+		}
+		result.exitCode = 65
+		return result
+	}
+
+	var output strings.Builder
+	interpreter.stdout = &output
+	interpreter.hook = &conformanceLimits{interpreter, parser.stmtLines, time.Now().Add(conformanceTimeout)}
+	interpreter.interpret(statements)
+
+	if text := strings.TrimSuffix(output.String(), "\n"); text != "" {
+		result.output = strings.Split(text, "\n")
+	}
+	if len(runtime.diagnostics) > 0 {
+		result.runtimeError = runtime.diagnostics[0].message
+		result.runtimeErrorLine = runtime.diagnostics[0].line
+		result.exitCode = 70
+	}
+	return result
+}
+
+// conformanceErrorText formats a compile error the way the Crafting Interpreters test
+// suite expects
+func conformanceErrorText(diag diagnostic) string {
+	switch {
+	case diag.token == nil:
+		return fmt.Sprintf("[line %d] Error: %s", diag.line, diag.message)
+	case diag.token.token_type == EOF:
+		return fmt.Sprintf("[line %d] Error at end: %s", diag.line, diag.message)
+	default:
+		return fmt.Sprintf("[line %d] Error at '%s': %s", diag.line, diag.token.lexeme, diag.message)
+	}
+}
+
+// compare returns a description of each way the actual results differ from the
+// expected ones
+func (e *conformanceExpectations) compare(actual conformanceResult) []string {
+	var failures []string
+
+	for i, expected := range e.output {
+		if i >= len(actual.output) {
+			failures = append(failures, fmt.Sprintf("Missing expected output '%s' on line %d.", expected.text, expected.line))
+		} else if actual.output[i] != expected.text {
+			failures = append(failures, fmt.Sprintf("Expected output '%s' on line %d and got '%s'.",
+				expected.text, expected.line, actual.output[i]))
+		}
+	}
+	for _, extra := range actual.output[min(len(e.output), len(actual.output)):] {
+		failures = append(failures, fmt.Sprintf("Got output '%s' when none was expected.", extra))
+	}
+
+	actualErrors := make(map[string]bool)
+	for _, err := range actual.errors {
+		actualErrors[err] = true
+	}
+	expectedErrors := make(map[string]bool)
+	for _, err := range e.errors {
+		expectedErrors[err] = true
+		if !actualErrors[err] {
+			failures = append(failures, "Missing expected error: "+err)
+		}
+	}
+	for _, err := range actual.errors {
+		if !expectedErrors[err] {
+			failures = append(failures, "Unexpected error: "+err)
+		}
+	}
+
+	switch {
+	case e.runtimeError != "" && actual.runtimeError == "":
+		failures = append(failures, fmt.Sprintf("Expected runtime error '%s' and got none.", e.runtimeError))
+	case e.runtimeError != "" && actual.runtimeError != e.runtimeError:
+		failures = append(failures, fmt.Sprintf("Expected runtime error '%s' and got '%s'.", e.runtimeError, actual.runtimeError))
+	case e.runtimeError != "" && actual.runtimeErrorLine != e.runtimeErrorLine:
+		failures = append(failures, fmt.Sprintf("Expected runtime error on line %d but was on line %d.",
+			e.runtimeErrorLine, actual.runtimeErrorLine))
+	case e.runtimeError == "" && actual.runtimeError != "":
+		failures = append(failures, fmt.Sprintf("Unexpected runtime error on line %d: %s",
+			actual.runtimeErrorLine, actual.runtimeError))
+	}
+
+	if actual.exitCode != e.exitCode {
+		failures = append(failures, fmt.Sprintf("Expected exit code %d and got %d.", e.exitCode, actual.exitCode))
+	}
+	return failures
+}
+
+// conformanceCommand implements 'glox conformance', which runs the annotated scripts
+// in the supplied files and directories
+func conformanceCommand(args []string) int {
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "list every script, not just the ones that fail")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox conformance [-v] path...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	files, err := collectLoxFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox conformance: %v\n", err)
+		return 66
+	}
+
+	passed, failed, skipped := 0, 0, 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox conformance: %v\n", err)
+			return 66
+		}
+		expected := parseConformanceExpectations(string(data))
+		if expected == nil {
+			skipped++
+			continue
+		}
+		config, err := loadProjectConfig(filepath.Dir(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox conformance: %v\n", err)
+			return 64
+		}
+
+		failures := expected.compare(runConformanceScript(string(data), config))
+		if len(failures) > 0 {
+			failed++
+			fmt.Printf("FAIL %s\n", path)
+			for _, failure := range failures {
+				fmt.Printf("     %s\n", failure)
+			}
+		} else {
+			passed++
+			if *verbose {
+				fmt.Printf("PASS %s\n", path)
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("Passed %d of %d scripts", passed, passed+failed)
+	if skipped > 0 {
+		summary += fmt.Sprintf(" (%d skipped)", skipped)
+	}
+	fmt.Println(summary + ".")
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// CONFORMANCE TESTS
+// ============================================================================
+
+// TestConformance runs each of the annotated scripts in testdata/conformance
+func TestConformance(t *testing.T) {
+	files, err := collectLoxFiles([]string{filepath.Join("testdata", "conformance")})
+	assertNoError(t, err, "Find scripts")
+
+	for _, path := range files {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			assertNoError(t, err, "Read script")
+			expected := parseConformanceExpectations(string(data))
+			if expected == nil {
+				t.Skip("not a conformance script")
+			}
+			for _, failure := range expected.compare(runConformanceScript(string(data), NewProjectConfig())) {
+				t.Error(failure)
+			}
+		})
+	}
+}
+
+func TestConformanceExpectations(t *testing.T) {
+	source := `print 1; // expect: 1
+print ""; // expect:
+var x = a; // Error at 'a': Undefined.
+// [line 7] Error at end: Expect '}'.
+// [java line 8] Error: Java only.
+// [c line 9] Error: C only.
+f(); // expect runtime error: Boom.
+`
+	expected := parseConformanceExpectations(source)
+	assertEqual(t, 2, len(expected.output), "Output lines")
+	assertEqual(t, conformanceOutput{2, ""}, expected.output[1], "Empty output")
+	assertEqual(t, "[line 3] Error at 'a': Undefined.", expected.errors[0], "Error on comment line")
+	assertEqual(t, "[line 7] Error at end: Expect '}'.", expected.errors[1], "Error with line")
+	assertEqual(t, "[line 8] Error: Java only.", expected.errors[2], "Java error")
+	assertEqual(t, 3, len(expected.errors), "Errors")
+	assertEqual(t, "Boom.", expected.runtimeError, "Runtime error")
+	assertEqual(t, 7, expected.runtimeErrorLine, "Runtime error line")
+
+	if parseConformanceExpectations("// nontest\nprint 1;\n") != nil {
+		t.Error("Expected script marked nontest to be skipped")
+	}
+}
+
+func TestConformanceFailures(t *testing.T) {
+	check := func(source string, expectedFailures ...string) {
+		t.Helper()
+		failures := parseConformanceExpectations(source).compare(runConformanceScript(source, NewProjectConfig()))
+		assertEqual(t, strings.Join(expectedFailures, "\n"), strings.Join(failures, "\n"), "Failures")
+	}
+
+	check("print 1; // expect: 2\nprint 3;\n",
+		"Expected output '2' on line 1 and got '1'.",
+		"Got output '3' when none was expected.")
+	check("print 1; // expect: 1\n// expect: 2\n",
+		"Missing expected output '2' on line 2.")
+	check("print;\n// [line 2] Error at ';': Expected expression\n",
+		"Missing expected error: [line 2] Error at ';': Expected expression",
+		"Unexpected error: [line 1] Error at ';': Expected expression")
+	check("print -nil; // expect runtime error: Wrong.\n",
+		"Expected runtime error 'Wrong.' and got 'operand to operator - must be a number'.")
+	check("print 1;\nprint -nil;\n// expect runtime error: operand to operator - must be a number\n",
+		"Got output '1' when none was expected.",
+		"Expected runtime error on line 3 but was on line 2.")
+	check("print -nil;\n",
+		"Unexpected runtime error on line 1: operand to operator - must be a number",
+		"Expected exit code 0 and got 70.")
+	check("fun f() { f(); }\nf(); // expect runtime error: Stack overflow.\n",
+		"Expected runtime error on line 2 but was on line 1.")
+}
+
+func TestConformanceCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "pass.lox"), "print 1; // expect: 1\n")
+	writeTestFile(t, filepath.Join(dir, "fail.lox"), "print 1; // expect: 2\n")
+	writeTestFile(t, filepath.Join(dir, "skip.lox"), "// nontest\n")
+
+	output, code := captureCommandOutput(t, func() int { return conformanceCommand([]string{"-v", dir}) })
+	assertEqual(t, 1, code, "Exit code")
+	expected := "FAIL " + filepath.Join(dir, "fail.lox") + "\n" +
+		"     Expected output '2' on line 1 and got '1'.\n" +
+		"PASS " + filepath.Join(dir, "pass.lox") + "\n" +
+		"Passed 1 of 2 scripts (1 skipped).\n"
+	assertEqual(t, expected, output, "Output")
+
+	_, code = captureCommandOutput(t, func() int { return conformanceCommand(nil) })
+	assertEqual(t, 64, code, "No paths")
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Coverage records which statements, branches and functions of one or more scripts
+// are executed. Each script has a fileCoverage, which is the execution hook for the
+// interpreter that runs it.
+type Coverage struct {
+	files []*fileCoverage
+}
+
+// fileCoverage records coverage for a single script. The statements that can be
+// covered are the ones in stmtLines, which must be populated with every statement
+// parsed from the script. The same script can be run by several interpreters at
+// once, eg by the test runner.
+type fileCoverage struct {
+	lock      sync.Mutex
+	path      string
+	source    []string
+	stmtLines map[Stmt]int
+	stmtHits  map[Stmt]int
+	branches  map[any]*[2]int // how often each branch of each if/logical was taken
+	calls     map[*FunctionStmt]int
+}
+
+// branchPoint is an if statement or logical expression, and how often each of its two
+// branches were taken. See branchObserver for what the branches are.
+type branchPoint struct {
+	node    any
+	line    int
+	column  int
+	taken   [2]int
+	reached bool
+}
+
+// coveredFunction is a function or method, and how often it was called
+type coveredFunction struct {
+	name  string
+	line  int
+	calls int
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// addFile starts recording coverage for a script, returning the hook that records
+// it. Adding the same script again, eg to run it in a fresh interpreter, returns the
+// same hook.
+func (c *Coverage) addFile(path string, source string, stmtLines map[Stmt]int) *fileCoverage {
+	for _, file := range c.files {
+		if file.path == path {
+			for stmt, line := range stmtLines {
+				file.stmtLines[stmt] = line
+			}
+			return file
+		}
+	}
+
+	file := &fileCoverage{
+		path:      path,
+		source:    strings.Split(source, "\n"),
+		stmtLines: stmtLines,
+		stmtHits:  make(map[Stmt]int),
+		branches:  make(map[any]*[2]int),
+		calls:     make(map[*FunctionStmt]int),
+	}
+	c.files = append(c.files, file)
+	return file
+}
+
+func (f *fileCoverage) beforeStatement(stmt Stmt) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stmtHits[stmt]++
+	return nil
+}
+
+func (f *fileCoverage) enteredCall(frame *callFrame, arguments []any) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[frame.function.declaration]++
+}
+
+func (f *fileCoverage) returnedFromCall(frame *callFrame, result any, err error) {}
+
+func (f *fileCoverage) tookBranch(node any, branch int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	taken, ok := f.branches[node]
+	if !ok {
+		taken = &[2]int{}
+		f.branches[node] = taken
+	}
+	taken[branch]++
+}
+
+// lineHits returns the number of times each line containing a statement was
+// executed. Where a line has several statements, the most-executed one counts.
+func (f *fileCoverage) lineHits() map[int]int {
+	hits := make(map[int]int)
+	for stmt, line := range f.stmtLines {
+		if _, isBlock := stmt.(*BlockStmt); isBlock {
+			continue
+		}
+		hits[line] = max(hits[line], f.stmtHits[stmt])
+	}
+	return hits
+}
+
+// branchPoints returns every if statement and logical expression in the script, in
+// source order
+func (f *fileCoverage) branchPoints() []*branchPoint {
+	var points []*branchPoint
+	add := func(node any, line int, column int) {
+		point := &branchPoint{node: node, line: line, column: column}
+		if taken, ok := f.branches[node]; ok {
+			point.taken, point.reached = *taken, true
+		}
+		points = append(points, point)
+	}
+
+	var addLogicals func(expr Expr)
+	addLogicals = func(expr Expr) {
+		switch e := expr.(type) {
+		case *LogicalExpr:
+			add(e, e.Operator.line, e.Operator.column)
+			addLogicals(e.Left)
+			addLogicals(e.Right)
+		case *AssignExpr:
+			addLogicals(e.value)
+		case *BinaryExpr:
+			addLogicals(e.Left)
+			addLogicals(e.Right)
+		case *UnaryExpr:
+			addLogicals(e.Right)
+		case *GroupingExpr:
+			addLogicals(e.Expression)
+		case *CallExpr:
+			addLogicals(e.Callee)
+			for _, arg := range e.Arguments {
+				addLogicals(arg)
+			}
+		case *InnerExpr:
+			for _, arg := range e.arguments {
+				addLogicals(arg)
+			}
+		case *IndexExpr:
+			addLogicals(e.object)
+			addLogicals(e.index)
+		case *PropGetExpr:
+			addLogicals(e.object)
+		case *PropSetExpr:
+			addLogicals(e.object)
+			addLogicals(e.propValue)
+		}
+	}
+
+	for stmt, line := range f.stmtLines {
+		switch s := stmt.(type) {
+		case *IfStmt:
+			add(s, line, expressionColumn(s.condition))
+			addLogicals(s.condition)
+		case *ExpressionStmt:
+			addLogicals(s.expression)
+		case *PrintStmt:
+			addLogicals(s.expression)
+		case *WhileStmt:
+			addLogicals(s.condition)
+		case *ReturnStmt:
+			addLogicals(s.returnValue)
+		case *VarStmt:
+			addLogicals(s.initializer)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].line != points[j].line {
+			return points[i].line < points[j].line
+		}
+		return points[i].column < points[j].column
+	})
+	return points
+}
+
+// expressionColumn returns the column of the first token of an expression, if it has
+// one
+func expressionColumn(expr Expr) int {
+	switch e := expr.(type) {
+	case *VariableExpr:
+		return e.variable.column
+	case *AssignExpr:
+		return e.variable.column
+	case *ThisExpr:
+		return e.keyword.column
+	case *SuperExpr:
+		return e.keyword.column
+	case *InnerExpr:
+		return e.keyword.column
+	case *UnaryExpr:
+		return e.Operator.column
+	case *BinaryExpr:
+		return expressionColumn(e.Left)
+	case *LogicalExpr:
+		return expressionColumn(e.Left)
+	case *CallExpr:
+		return expressionColumn(e.Callee)
+	case *PropGetExpr:
+		return expressionColumn(e.object)
+	case *IndexExpr:
+		return expressionColumn(e.object)
+	case *PropSetExpr:
+		return expressionColumn(e.object)
+	case *GroupingExpr:
+		return expressionColumn(e.Expression)
+	}
+	return 0
+}
+
+// functions returns every function and method declared in the script, in source
+// order
+func (f *fileCoverage) functions() []*coveredFunction {
+	var functions []*coveredFunction
+	for stmt := range f.stmtLines {
+		switch s := stmt.(type) {
+		case *FunctionStmt:
+			functions = append(functions, &coveredFunction{s.functionName.lexeme, s.functionName.line, f.calls[s]})
+		case *ClassStmt:
+			for _, method := range slices.Concat(s.methods, s.classMethods) {
+				if method.isAbstract {
+					continue // no body to run
+				}
+				name := s.className.lexeme + "." + methodName(method)
+				functions = append(functions, &coveredFunction{name, method.functionName.line, f.calls[method]})
+			}
+		case *TraitStmt:
+			for _, method := range s.methods {
+				name := s.traitName.lexeme + "." + methodName(method)
+				functions = append(functions, &coveredFunction{name, method.functionName.line, f.calls[method]})
+			}
+		}
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].line != functions[j].line {
+			return functions[i].line < functions[j].line
+		}
+		return functions[i].name < functions[j].name
+	})
+	return functions
+}
+
+// coverageSummary is the number of lines, branches and functions that were covered,
+// out of the total
+type coverageSummary struct {
+	lines, linesHit         int
+	branches, branchesHit   int
+	functions, functionsHit int
+}
+
+func (f *fileCoverage) summary() coverageSummary {
+	var s coverageSummary
+	for _, hits := range f.lineHits() {
+		s.lines++
+		if hits > 0 {
+			s.linesHit++
+		}
+	}
+	for _, point := range f.branchPoints() {
+		for _, taken := range point.taken {
+			s.branches++
+			if taken > 0 {
+				s.branchesHit++
+			}
+		}
+	}
+	for _, function := range f.functions() {
+		s.functions++
+		if function.calls > 0 {
+			s.functionsHit++
+		}
+	}
+	return s
+}
+
+func (s *coverageSummary) add(other coverageSummary) {
+	s.lines += other.lines
+	s.linesHit += other.linesHit
+	s.branches += other.branches
+	s.branchesHit += other.branchesHit
+	s.functions += other.functions
+	s.functionsHit += other.functionsHit
+}
+
+func (s coverageSummary) String() string {
+	return fmt.Sprintf("%s of lines, %s of branches, %s of functions", coveragePercent(s.linesHit, s.lines),
+		coveragePercent(s.branchesHit, s.branches), coveragePercent(s.functionsHit, s.functions))
+}
+
+func coveragePercent(hit int, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
+
+// summary returns the combined coverage of all the scripts
+func (c *Coverage) summary() coverageSummary {
+	var s coverageSummary
+	for _, file := range c.files {
+		s.add(file.summary())
+	}
+	return s
+}
+
+// writeLCOV writes the coverage in the LCOV tracefile format read by genhtml and most
+// coverage tools
+func (c *Coverage) writeLCOV(w io.Writer) error {
+	var b strings.Builder
+	for _, file := range c.files {
+		b.WriteString("TN:\n")
+		fmt.Fprintf(&b, "SF:%s\n", file.path)
+
+		functions := file.functions()
+		hit := 0
+		for _, function := range functions {
+			fmt.Fprintf(&b, "FN:%d,%s\n", function.line, function.name)
+		}
+		for _, function := range functions {
+			fmt.Fprintf(&b, "FNDA:%d,%s\n", function.calls, function.name)
+			if function.calls > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(functions), hit)
+
+		points := file.branchPoints()
+		hit = 0
+		for block, point := range points {
+			for branch, taken := range point.taken {
+				if !point.reached {
+					fmt.Fprintf(&b, "BRDA:%d,%d,%d,-\n", point.line, block, branch)
+					continue
+				}
+				fmt.Fprintf(&b, "BRDA:%d,%d,%d,%d\n", point.line, block, branch, taken)
+				if taken > 0 {
+					hit++
+				}
+			}
+		}
+		fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", 2*len(points), hit)
+
+		lineHits := file.lineHits()
+		hit = 0
+		for _, line := range sortedLines(lineHits) {
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, lineHits[line])
+			if lineHits[line] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\n", len(lineHits), hit)
+		b.WriteString("end_of_record\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeReport writes each script's source annotated with how many times each line was
+// executed. Lines that were never executed are marked with #####, and branches that
+// were never taken are listed after the source.
+func (c *Coverage) writeReport(w io.Writer) error {
+	var b strings.Builder
+	for i, file := range c.files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", file.path, file.summary())
+
+		lineHits := file.lineHits()
+		for n, text := range file.source {
+			line := n + 1
+			if line == len(file.source) && text == "" {
+				break
+			}
+			count := ""
+			if hits, ok := lineHits[line]; ok {
+				count = fmt.Sprint(hits)
+				if hits == 0 {
+					count = "#####"
+				}
+			}
+			fmt.Fprintf(&b, "%6s %4d | %s\n", count, line, text)
+		}
+
+		var untaken []string
+		for _, point := range file.branchPoints() {
+			for branch, taken := range point.taken {
+				if taken == 0 {
+					untaken = append(untaken, fmt.Sprintf("  line %d: %s", point.line, describeBranch(point, branch)))
+				}
+			}
+		}
+		if len(untaken) > 0 {
+			b.WriteString("\nBranches not taken:\n")
+			b.WriteString(strings.Join(untaken, "\n") + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// describeBranch describes a branch for the coverage report
+func describeBranch(point *branchPoint, branch int) string {
+	if logical, ok := point.node.(*LogicalExpr); ok {
+		if branch == 0 {
+			return fmt.Sprintf("'%s' never short-circuited", logical.Operator.lexeme)
+		}
+		return fmt.Sprintf("right operand of '%s' never evaluated", logical.Operator.lexeme)
+	}
+	if branch == 0 {
+		return "if condition never true"
+	}
+	return "if condition never false"
+}
+
+func sortedLines(lines map[int]int) []int {
+	sorted := make([]int, 0, len(lines))
+	for line := range lines {
+		sorted = append(sorted, line)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// COVERAGE TESTS
+// ============================================================================
+
+const coverageTestProgram = `class A {
+  init(x) { this.x = x; }
+  big() { return this.x > 10 or this.x < -10; }
+  unused() { print "never"; }
+}
+fun check(n) {
+  if (n > 0) {
+    print "positive";
+  } else {
+    print "other";
+  }
+}
+check(1);
+check(2);
+print A(3).big();
+`
+
+// runWithCoverage runs a program, recording its coverage
+func runWithCoverage(t *testing.T, program string) *Coverage {
+	t.Helper()
+	lox := &GLox{stmtLines: make(map[Stmt]int)}
+	lox.interpreter = NewInterpreter(lox)
+	lox.interpreter.stdout = io.Discard
+
+	coverage := NewCoverage()
+	lox.interpreter.hook = coverage.addFile("test.lox", program, lox.stmtLines)
+	lox.run(program, false)
+	return coverage
+}
+
+func TestCoverage(t *testing.T) {
+	t.Run("LCOV", func(t *testing.T) {
+		var out strings.Builder
+		assertNoError(t, runWithCoverage(t, coverageTestProgram).writeLCOV(&out), "writeLCOV")
+		expected := `TN:
+SF:test.lox
+FN:2,A.init
+FN:3,A.big
+FN:4,A.unused
+FN:6,check
+FNDA:1,A.init
+FNDA:1,A.big
+FNDA:0,A.unused
+FNDA:2,check
+FNF:4
+FNH:3
+BRDA:3,0,0,0
+BRDA:3,0,1,1
+BRDA:7,1,0,2
+BRDA:7,1,1,0
+BRF:4
+BRH:2
+DA:1,1
+DA:2,1
+DA:3,1
+DA:4,0
+DA:6,1
+DA:7,2
+DA:8,2
+DA:10,0
+DA:13,1
+DA:14,1
+DA:15,1
+LF:11
+LH:9
+end_of_record
+`
+		assertEqual(t, expected, out.String(), "LCOV")
+	})
+
+	t.Run("Annotated report", func(t *testing.T) {
+		var out strings.Builder
+		assertNoError(t, runWithCoverage(t, coverageTestProgram).writeReport(&out), "writeReport")
+		report := out.String()
+		assertContains(t, report, "test.lox: 81.8% of lines, 50.0% of branches, 75.0% of functions\n")
+		assertContains(t, report, "     2    7 |   if (n > 0) {\n")
+		assertContains(t, report, "          9 |   } else {\n")
+		assertContains(t, report, " #####   10 |     print \"other\";\n")
+		assertContains(t, report, "Branches not taken:\n  line 3: 'or' never short-circuited\n  line 7: if condition never false\n")
+	})
+
+	t.Run("Branches that are never reached", func(t *testing.T) {
+		program := "fun f(a, b) {\n  if (a and b) print 1;\n}\nvar x = false or true;\n"
+		var out strings.Builder
+		coverage := runWithCoverage(t, program)
+		assertNoError(t, coverage.writeLCOV(&out), "writeLCOV")
+		assertContains(t, out.String(), "BRDA:2,0,0,-\nBRDA:2,0,1,-\nBRDA:2,1,0,-\nBRDA:2,1,1,-\nBRDA:4,2,0,0\nBRDA:4,2,1,1\n")
+		assertContains(t, out.String(), "BRF:6\nBRH:1\n")
+	})
+
+	t.Run("Class methods", func(t *testing.T) {
+		program := "class A {\n  class make() { return this(); }\n  class unused() {}\n}\nA.make();\n"
+		var out strings.Builder
+		assertNoError(t, runWithCoverage(t, program).writeLCOV(&out), "writeLCOV")
+		assertContains(t, out.String(), "FNDA:1,A.make\nFNDA:0,A.unused\n")
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		summary := runWithCoverage(t, "print 1;\nif (false) {\n  print 2;\n}\n").summary()
+		assertEqual(t, "66.7% of lines, 50.0% of branches, 100.0% of functions", summary.String(), "Summary")
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.lox")
+		writeTestFile(t, path, coverageTestProgram)
+		lcov := filepath.Join(dir, "lcov.info")
+		report := filepath.Join(dir, "coverage.txt")
+
+		stdout, code := captureCommandOutput(t, func() int {
+			return runCommand([]string{"--coverprofile=" + lcov, "--coverreport=" + report, path})
+		})
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, "positive\npositive\nfalse\n", stdout, "Program output")
+
+		data, err := os.ReadFile(lcov)
+		assertNoError(t, err, "Read LCOV")
+		assertContains(t, string(data), "SF:"+path+"\n")
+		data, err = os.ReadFile(report)
+		assertNoError(t, err, "Read report")
+		assertContains(t, string(data), " #####    4 |   unused() { print \"never\"; }\n")
+	})
+}
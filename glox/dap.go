@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ----------------------------------------------------------------------------
+// Protocol types. Only the subset of the Debug Adapter Protocol used by glox is
+// defined here.
+// ----------------------------------------------------------------------------
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapLaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+}
+
+type dapSetBreakpointsArguments struct {
+	Source      dapSource `json:"source"`
+	Breakpoints []struct {
+		Line      int    `json:"line"`
+		Condition string `json:"condition"`
+	} `json:"breakpoints"`
+}
+
+type dapBreakpoint struct {
+	ID       int    `json:"id,omitempty"`
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type dapStackFrame struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Source dapSource `json:"source"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// The thread ID used for the single thread of a Lox program
+const dapThreadID = 1
+
+// ----------------------------------------------------------------------------
+// Server
+// ----------------------------------------------------------------------------
+
+// DapServer implements a Debug Adapter Protocol server, which runs a single Lox
+// program under the control of a Debugger
+type DapServer struct {
+	reader    *bufio.Reader
+	writer    io.Writer
+	writeLock sync.Mutex // guards writer and seq, as events are sent by the program
+	seq       int
+
+	program     *debugProgram
+	source      dapSource
+	interpreter *Interpreter
+	debugger    *Debugger
+	started     bool
+	done        chan struct{} // closed when the program finishes
+
+	// variableRefs maps the variablesReference values handed out to the client to
+	// what they refer to: a debugScope or a *LoxInstance. They're only valid while the
+	// program is stopped.
+	variableRefs map[int]any
+}
+
+func NewDapServer(in io.Reader, out io.Writer) *DapServer {
+	return &DapServer{
+		reader:       bufio.NewReader(in),
+		writer:       out,
+		done:         make(chan struct{}),
+		variableRefs: make(map[int]any),
+	}
+}
+
+// run processes requests until the client disconnects, returning the process exit
+// code
+func (s *DapServer) run() int {
+	for {
+		body, err := readProtocolMessage(s.reader)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "glox dap: %v\n", err)
+			}
+			s.stopProgram()
+			return 1
+		}
+
+		var request dapRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			fmt.Fprintf(os.Stderr, "glox dap: invalid message: %v\n", err)
+			continue
+		}
+
+		if request.Command == "disconnect" {
+			s.stopProgram()
+			s.respond(request, nil, nil)
+			return 0
+		}
+		s.handle(request)
+	}
+}
+
+func (s *DapServer) handle(request dapRequest) {
+	var body any
+	var err error
+
+	switch request.Command {
+	case "initialize":
+		body = map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}
+		s.respond(request, body, nil)
+		s.sendEvent("initialized", nil)
+		return
+	case "launch":
+		err = s.launch(request.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(request.Arguments)
+	case "setExceptionBreakpoints":
+		body = map[string]any{"breakpoints": []dapBreakpoint{}}
+	case "configurationDone":
+		err = s.start()
+	case "threads":
+		body = map[string]any{"threads": []map[string]any{{"id": dapThreadID, "name": "main"}}}
+	case "stackTrace":
+		frames := s.stackTrace()
+		body = map[string]any{"stackFrames": frames, "totalFrames": len(frames)}
+	case "scopes":
+		body, err = s.scopes(request.Arguments)
+	case "variables":
+		body, err = s.variables(request.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(request.Arguments)
+	case "continue":
+		err = s.resume(stepContinue)
+		body = map[string]any{"allThreadsContinued": true}
+	case "next":
+		err = s.resume(stepOver)
+	case "stepIn":
+		err = s.resume(stepIn)
+	case "stepOut":
+		err = s.resume(stepOut)
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.pause()
+		}
+	case "terminate":
+		s.stopProgram()
+	default:
+		err = fmt.Errorf("unsupported request '%s'", request.Command)
+	}
+	s.respond(request, body, err)
+}
+
+// launch loads the program to be debugged. It isn't run until the client has
+// finished configuring breakpoints.
+func (s *DapServer) launch(arguments json.RawMessage) error {
+	var args dapLaunchArguments
+	if err := json.Unmarshal(arguments, &args); err != nil || args.Program == "" {
+		return errors.New("launch requires a 'program' to debug")
+	}
+	program, diagnostics, err := loadDebugProgram(args.Program, func(diag diagnostic) {
+		s.sendOutput("stderr", diag.String()+"\n")
+	})
+	for _, diag := range diagnostics {
+		s.sendOutput("stderr", diag.String()+"\n")
+	}
+	if err != nil {
+		return err
+	}
+
+	s.program = program
+	s.source = dapSource{Name: filepath.Base(args.Program), Path: args.Program}
+	s.interpreter = program.interpreter
+	s.interpreter.stdout = &dapOutputWriter{s, "stdout"}
+	s.debugger = NewDebugger(program.interpreter, program.stmtLines, s.stopped)
+	if args.StopOnEntry {
+		s.debugger.mode = stepIn
+	}
+	return nil
+}
+
+func (s *DapServer) setBreakpoints(arguments json.RawMessage) (any, error) {
+	var args dapSetBreakpointsArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if s.debugger == nil {
+		return nil, errors.New("no program has been launched")
+	}
+
+	lines := make([]int, 0, len(args.Breakpoints))
+	conditions := make([]string, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		lines = append(lines, bp.Line)
+		conditions = append(conditions, bp.Condition)
+	}
+	breakpoints, err := s.debugger.setBreakpoints(lines, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dapBreakpoint, 0, len(breakpoints))
+	for _, bp := range breakpoints {
+		if bp == nil {
+			result = append(result, dapBreakpoint{Verified: false, Message: "No statement at or after this line"})
+		} else {
+			result = append(result, dapBreakpoint{ID: bp.id, Verified: true, Line: bp.line})
+		}
+	}
+	return map[string]any{"breakpoints": result}, nil
+}
+
+// start runs the program in the background
+func (s *DapServer) start() error {
+	if s.interpreter == nil {
+		return errors.New("no program has been launched")
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	go func() {
+		defer close(s.done)
+		s.interpreter.interpret(s.program.statements)
+
+		exitCode := 0
+		if s.program.runtime.hadError() {
+			exitCode = 70
+		}
+		s.sendEvent("exited", map[string]any{"exitCode": exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+	return nil
+}
+
+// stopProgram terminates the program, if it's running, and waits for it to finish
+func (s *DapServer) stopProgram() {
+	if s.debugger != nil {
+		s.debugger.terminate()
+	}
+	if s.started {
+		<-s.done
+	}
+}
+
+// stopped is called by the Debugger (on the program's goroutine) when it stops
+func (s *DapServer) stopped(reason string, line int) {
+	s.sendEvent("stopped", map[string]any{
+		"reason":            reason,
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	})
+}
+
+func (s *DapServer) resume(mode stepMode) error {
+	if s.debugger == nil || !s.debugger.resume(mode) {
+		return errors.New("the program isn't stopped")
+	}
+	s.variableRefs = make(map[int]any)
+	return nil
+}
+
+// stackTrace returns the program's call frames, innermost first. Frame IDs are
+// 1-based indexes into the Interpreter's frames.
+func (s *DapServer) stackTrace() []dapStackFrame {
+	frames := make([]dapStackFrame, 0)
+	if s.debugger == nil || !s.debugger.isStopped() {
+		return frames
+	}
+	for i := len(s.interpreter.frames) - 1; i >= 0; i-- {
+		frame := s.interpreter.frames[i]
+		frames = append(frames, dapStackFrame{
+			ID:     i + 1,
+			Name:   frame.displayName(),
+			Source: s.source,
+			Line:   frame.line,
+			Column: 1,
+		})
+	}
+	return frames
+}
+
+// frame returns the call frame with the supplied ID, if the program is stopped
+func (s *DapServer) frame(id int) (*callFrame, error) {
+	if s.debugger == nil || !s.debugger.isStopped() {
+		return nil, errors.New("the program isn't stopped")
+	}
+	if id < 1 || id > len(s.interpreter.frames) {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+	return s.interpreter.frames[id-1], nil
+}
+
+func (s *DapServer) scopes(arguments json.RawMessage) (any, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]dapScope, 0)
+	for _, scope := range s.debugger.scopes(frame) {
+		scopes = append(scopes, dapScope{
+			Name:               scope.name,
+			VariablesReference: s.variablesReference(scope),
+			Expensive:          scope.name == "Globals",
+		})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *DapServer) variables(arguments json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	var variables []debugVariable
+	switch container := s.variableRefs[args.VariablesReference].(type) {
+	case debugScope:
+		variables = container.variables()
+	case *LoxInstance:
+		variables = instanceFields(container)
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+
+	result := make([]dapVariable, 0, len(variables))
+	for _, variable := range variables {
+		value, typeName := describeValue(variable.value)
+		result = append(result, dapVariable{
+			Name:               variable.name,
+			Value:              value,
+			Type:               typeName,
+			VariablesReference: s.valueReference(variable.value),
+		})
+	}
+	return map[string]any{"variables": result}, nil
+}
+
+func (s *DapServer) evaluate(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.FrameID == 0 && s.interpreter != nil {
+		args.FrameID = len(s.interpreter.frames)
+	}
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.debugger.evaluateIn(frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	result, typeName := describeValue(value)
+	return map[string]any{
+		"result":             result,
+		"type":               typeName,
+		"variablesReference": s.valueReference(value),
+	}, nil
+}
+
+func (s *DapServer) variablesReference(container any) int {
+	ref := len(s.variableRefs) + 1
+	s.variableRefs[ref] = container
+	return ref
+}
+
+// valueReference returns a reference for expanding a value, or 0 if it can't be
+// expanded. Only instances can be expanded, to show their fields.
+func (s *DapServer) valueReference(value any) int {
+	if instance, ok := value.(*LoxInstance); ok {
+		return s.variablesReference(instance)
+	}
+	return 0
+}
+
+func (s *DapServer) respond(request dapRequest, body any, err error) {
+	response := dapResponse{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    err == nil,
+		Command:    request.Command,
+		Body:       body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+	}
+	s.send(&response, &response.Seq)
+}
+
+func (s *DapServer) sendEvent(event string, body any) {
+	message := dapEvent{Type: "event", Event: event, Body: body}
+	s.send(&message, &message.Seq)
+}
+
+func (s *DapServer) sendOutput(category string, output string) {
+	s.sendEvent("output", map[string]any{"category": category, "output": output})
+}
+
+// send writes a message to the client, setting its sequence number
+func (s *DapServer) send(message any, seq *int) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.seq++
+	*seq = s.seq
+	body, err := json.Marshal(message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox dap: %v\n", err)
+		return
+	}
+	writeProtocolMessage(s.writer, body)
+}
+
+// dapOutputWriter sends the program's output to the client as output events
+type dapOutputWriter struct {
+	server   *DapServer
+	category string
+}
+
+func (w *dapOutputWriter) Write(p []byte) (int, error) {
+	w.server.sendOutput(w.category, string(p))
+	return len(p), nil
+}
+
+// dapCommand implements 'glox dap', which runs a debug adapter over stdio
+func dapCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: glox dap")
+		return 64
+	}
+	return NewDapServer(os.Stdin, os.Stdout).run()
+}
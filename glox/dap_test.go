@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// DEBUG ADAPTER TESTS
+// ============================================================================
+
+const dapTestProgram = `class Counter {
+    init(start) {
+        this.count = start;
+    }
+    add(n) {
+        var total = this.count + n;
+        this.count = total;
+        return total;
+    }
+}
+
+fun run(limit) {
+    var counter = Counter(10);
+    for (var i = 0; i < limit; i = i + 1) {
+        counter.add(i);
+    }
+    return counter.count;
+}
+
+print run(3);
+print "done";
+`
+
+// dapTestClient drives a DapServer through a pair of pipes. Messages from the server
+// are read continuously, so it's never blocked writing.
+type dapTestClient struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]any
+	seq      int
+	exit     chan int
+	pending  []map[string]any // messages read while waiting for a different one
+}
+
+func newDapTestClient(t *testing.T) *dapTestClient {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	client := &dapTestClient{t: t, in: inWriter, messages: make(chan map[string]any, 100), exit: make(chan int, 1)}
+	go func() {
+		client.exit <- NewDapServer(inReader, outWriter).run()
+		outWriter.Close()
+	}()
+	go func() {
+		reader := bufio.NewReader(outReader)
+		for {
+			body, err := readProtocolMessage(reader)
+			if err != nil {
+				close(client.messages)
+				return
+			}
+			var message map[string]any
+			json.Unmarshal(body, &message)
+			client.messages <- message
+		}
+	}()
+	return client
+}
+
+func (c *dapTestClient) send(command string, arguments any) int {
+	c.t.Helper()
+	c.seq++
+	body, _ := json.Marshal(map[string]any{
+		"seq": c.seq, "type": "request", "command": command, "arguments": arguments,
+	})
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", command, err)
+	}
+	return c.seq
+}
+
+// next returns the next message matching the supplied predicate, keeping any others
+// for later calls
+func (c *dapTestClient) next(description string, matches func(map[string]any) bool) map[string]any {
+	c.t.Helper()
+	for i, message := range c.pending {
+		if matches(message) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return message
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case message, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("Connection closed waiting for %s", description)
+			}
+			if matches(message) {
+				return message
+			}
+			c.pending = append(c.pending, message)
+		case <-timeout:
+			c.t.Fatalf("Timed out waiting for %s", description)
+		}
+	}
+}
+
+// request sends a request and waits for its response, failing the test if it
+// wasn't successful
+func (c *dapTestClient) request(command string, arguments any) map[string]any {
+	c.t.Helper()
+	response := c.requestAllowingFailure(command, arguments)
+	if response["success"] != true {
+		c.t.Fatalf("Request %s failed: %v", command, response["message"])
+	}
+	body, _ := response["body"].(map[string]any)
+	return body
+}
+
+func (c *dapTestClient) requestAllowingFailure(command string, arguments any) map[string]any {
+	c.t.Helper()
+	seq := c.send(command, arguments)
+	return c.next("response to "+command, func(m map[string]any) bool {
+		return m["type"] == "response" && m["request_seq"] == float64(seq)
+	})
+}
+
+func (c *dapTestClient) event(name string) map[string]any {
+	c.t.Helper()
+	message := c.next(name+" event", func(m map[string]any) bool {
+		return m["type"] == "event" && m["event"] == name
+	})
+	body, _ := message["body"].(map[string]any)
+	return body
+}
+
+// output returns the text of the next output event with the supplied category
+func (c *dapTestClient) output(category string) string {
+	c.t.Helper()
+	message := c.next(category+" output", func(m map[string]any) bool {
+		body, _ := m["body"].(map[string]any)
+		return m["event"] == "output" && body["category"] == category
+	})
+	return message["body"].(map[string]any)["output"].(string)
+}
+
+// launch starts a debug session for the supplied program, with breakpoints on the
+// supplied lines
+func (c *dapTestClient) launch(program string, stopOnEntry bool, breakpoints ...map[string]any) []any {
+	c.t.Helper()
+	path := filepath.Join(c.t.TempDir(), "program.lox")
+	writeTestFile(c.t, path, program)
+
+	c.request("initialize", map[string]any{"adapterID": "glox"})
+	c.event("initialized")
+	c.request("launch", map[string]any{"program": path, "stopOnEntry": stopOnEntry})
+	body := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": breakpoints,
+	})
+	c.request("configurationDone", nil)
+	return body["breakpoints"].([]any)
+}
+
+// stoppedAt waits for the program to stop, returning the reason and the name and
+// line of the innermost frame
+func (c *dapTestClient) stoppedAt() (string, string, int) {
+	c.t.Helper()
+	reason := c.event("stopped")["reason"].(string)
+	frames := c.request("stackTrace", map[string]any{"threadId": dapThreadID})["stackFrames"].([]any)
+	top := frames[0].(map[string]any)
+	return reason, top["name"].(string), int(top["line"].(float64))
+}
+
+// variables returns the values of the variables in a scope of the innermost frame
+func (c *dapTestClient) variables(scopeName string) map[string]map[string]any {
+	c.t.Helper()
+	frames := c.request("stackTrace", map[string]any{"threadId": dapThreadID})["stackFrames"].([]any)
+	frameID := frames[0].(map[string]any)["id"]
+	scopes := c.request("scopes", map[string]any{"frameId": frameID})["scopes"].([]any)
+	for _, s := range scopes {
+		scope := s.(map[string]any)
+		if scope["name"] == scopeName {
+			return c.expand(scope["variablesReference"])
+		}
+	}
+	c.t.Fatalf("No scope %s", scopeName)
+	return nil
+}
+
+func (c *dapTestClient) expand(reference any) map[string]map[string]any {
+	c.t.Helper()
+	result := make(map[string]map[string]any)
+	variables := c.request("variables", map[string]any{"variablesReference": reference})["variables"].([]any)
+	for _, v := range variables {
+		variable := v.(map[string]any)
+		result[variable["name"].(string)] = variable
+	}
+	return result
+}
+
+func (c *dapTestClient) disconnect() {
+	c.t.Helper()
+	c.request("disconnect", nil)
+	select {
+	case code := <-c.exit:
+		assertEqual(c.t, 0, code, "Exit code")
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("Server didn't exit")
+	}
+}
+
+func TestDapBreakpoints(t *testing.T) {
+	t.Run("Breakpoint stops in function with stack and variables", func(t *testing.T) {
+		client := newDapTestClient(t)
+		breakpoints := client.launch(dapTestProgram, false, map[string]any{"line": 6})
+		assertEqual(t, true, breakpoints[0].(map[string]any)["verified"], "Verified")
+
+		reason, name, line := client.stoppedAt()
+		assertEqual(t, "breakpoint", reason, "Reason")
+		assertEqual(t, "Counter.add", name, "Frame name")
+		assertEqual(t, 6, line, "Line")
+
+		frames := client.request("stackTrace", map[string]any{"threadId": dapThreadID})["stackFrames"].([]any)
+		assertEqual(t, 3, len(frames), "Frame count")
+		assertEqual(t, "run", frames[1].(map[string]any)["name"], "Caller")
+		assertEqual(t, float64(15), frames[1].(map[string]any)["line"], "Caller line")
+		assertEqual(t, "<script>", frames[2].(map[string]any)["name"], "Script frame")
+
+		locals := client.variables("Locals")
+		assertEqual(t, "0", locals["n"]["value"], "Parameter n")
+
+		closure := client.variables("Closure")
+		this := closure["this"]
+		assertEqual(t, "Counter instance", this["value"], "this")
+		fields := client.expand(this["variablesReference"])
+		assertEqual(t, "10", fields["count"]["value"], "Field count")
+
+		globals := client.variables("Globals")
+		assertEqual(t, "<fn run>", globals["run"]["value"], "Global function")
+
+		// The breakpoint is hit again on the next iteration
+		client.request("continue", map[string]any{"threadId": dapThreadID})
+		_, _, line = client.stoppedAt()
+		assertEqual(t, 6, line, "Second hit")
+		assertEqual(t, "1", client.variables("Locals")["n"]["value"], "Parameter n on second hit")
+
+		client.disconnect()
+	})
+
+	t.Run("Conditional breakpoint", func(t *testing.T) {
+		client := newDapTestClient(t)
+		client.launch(dapTestProgram, false, map[string]any{"line": 15, "condition": "i == 2"})
+
+		reason, _, line := client.stoppedAt()
+		assertEqual(t, "breakpoint", reason, "Reason")
+		assertEqual(t, 15, line, "Line")
+		assertEqual(t, "2", client.variables("Locals")["i"]["value"], "Loop variable")
+
+		evaluated := client.request("evaluate", map[string]any{"expression": "counter.count + 1", "frameId": 2})
+		assertEqual(t, "12", evaluated["result"], "Evaluated expression")
+
+		client.request("continue", map[string]any{"threadId": dapThreadID})
+		assertEqual(t, "13\n", client.output("stdout"), "Program output")
+		assertEqual(t, float64(0), client.event("exited")["exitCode"], "Exit code")
+		client.event("terminated")
+		client.disconnect()
+	})
+
+	t.Run("Breakpoint on a blank line moves to the next statement", func(t *testing.T) {
+		client := newDapTestClient(t)
+		breakpoints := client.launch(dapTestProgram, false, map[string]any{"line": 11}, map[string]any{"line": 100})
+		assertEqual(t, float64(12), breakpoints[0].(map[string]any)["line"], "Moved breakpoint")
+		assertEqual(t, false, breakpoints[1].(map[string]any)["verified"], "Breakpoint after end")
+		client.disconnect()
+	})
+}
+
+func TestDapStepping(t *testing.T) {
+	t.Run("Step over, in and out", func(t *testing.T) {
+		client := newDapTestClient(t)
+		client.launch(dapTestProgram, true)
+
+		reason, name, line := client.stoppedAt()
+		assertEqual(t, "step", reason, "Entry reason")
+		assertEqual(t, "<script>", name, "Entry frame")
+		assertEqual(t, 1, line, "Entry line")
+
+		client.request("next", map[string]any{"threadId": dapThreadID})
+		_, _, line = client.stoppedAt()
+		assertEqual(t, 12, line, "After stepping over class")
+
+		client.request("next", map[string]any{"threadId": dapThreadID})
+		_, _, line = client.stoppedAt()
+		assertEqual(t, 20, line, "After stepping over function")
+
+		client.request("stepIn", map[string]any{"threadId": dapThreadID})
+		_, name, line = client.stoppedAt()
+		assertEqual(t, "run", name, "Stepped into")
+		assertEqual(t, 13, line, "First line of run")
+
+		client.request("stepIn", map[string]any{"threadId": dapThreadID})
+		_, name, line = client.stoppedAt()
+		assertEqual(t, "Counter.init", name, "Stepped into initializer")
+		assertEqual(t, 3, line, "Initializer line")
+
+		client.request("stepOut", map[string]any{"threadId": dapThreadID})
+		_, name, line = client.stoppedAt()
+		assertEqual(t, "run", name, "Stepped out")
+		assertEqual(t, 14, line, "Line after call")
+
+		client.request("next", map[string]any{"threadId": dapThreadID})
+		_, name, line = client.stoppedAt()
+		assertEqual(t, "run", name, "Stepped over loop header")
+		assertEqual(t, 15, line, "Loop body")
+
+		client.request("next", map[string]any{"threadId": dapThreadID})
+		_, name, line = client.stoppedAt()
+		assertEqual(t, 14, line, "Loop update")
+
+		client.disconnect()
+	})
+
+	t.Run("Pause stops a running program", func(t *testing.T) {
+		client := newDapTestClient(t)
+		client.launch("var i = 0;\nwhile (true) {\n    i = i + 1;\n    if (i == 1) print \"running\";\n}\n", false)
+
+		// Only pause once the loop is running, so the program can't stop before it
+		assertEqual(t, "running\n", client.output("stdout"), "Program output")
+		client.request("pause", map[string]any{"threadId": dapThreadID})
+		reason, _, line := client.stoppedAt()
+		assertEqual(t, "pause", reason, "Reason")
+		if line < 2 || line > 4 {
+			t.Errorf("Expected to pause in the loop, got line %d", line)
+		}
+
+		client.request("terminate", nil)
+		client.event("terminated")
+		client.disconnect()
+	})
+}
+
+func TestDapErrors(t *testing.T) {
+	t.Run("Program with errors can't be launched", func(t *testing.T) {
+		client := newDapTestClient(t)
+		path := filepath.Join(t.TempDir(), "bad.lox")
+		writeTestFile(t, path, "print ;")
+
+		client.request("initialize", nil)
+		response := client.requestAllowingFailure("launch", map[string]any{"program": path})
+		assertEqual(t, false, response["success"], "Launch success")
+		assertContains(t, client.output("stderr"), "Expected expression")
+		client.disconnect()
+	})
+
+	t.Run("Runtime errors are reported as output", func(t *testing.T) {
+		client := newDapTestClient(t)
+		client.launch("print 1;\nprint -\"a\";\n", false)
+		assertEqual(t, "1\n", client.output("stdout"), "Output before error")
+		assertContains(t, client.output("stderr"), "[line 2]")
+		assertEqual(t, float64(70), client.event("exited")["exitCode"], "Exit code")
+		client.disconnect()
+	})
+
+	t.Run("Unknown requests fail", func(t *testing.T) {
+		client := newDapTestClient(t)
+		response := client.requestAllowingFailure("restartFrame", nil)
+		assertEqual(t, false, response["success"], "Success")
+		client.disconnect()
+	})
+}
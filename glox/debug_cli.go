@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const debugCLIHelp = `Commands:
+  break [file:]line [if condition]   set a breakpoint (b)
+  watch expression                   stop when the value of an expression changes
+  delete number                      delete a breakpoint or watchpoint
+  run                                start the program (r)
+  continue                           continue running the program (c)
+  step                               step to the next statement, entering calls (s)
+  next                               step to the next statement, stepping over calls (n)
+  finish                             run until the current function returns
+  print expression                   evaluate an expression in the current frame (p)
+  bt                                 print the call stack (backtrace)
+  locals                             print the local variables of the current frame
+  quit                               stop debugging (q)
+`
+
+type cliBreakpoint struct {
+	id        int
+	line      int // line requested by the user
+	condition string
+}
+
+type cliWatch struct {
+	id     int
+	source string
+}
+
+type debugStop struct {
+	reason string
+	line   int
+}
+
+// cliDebugger is an interactive command-line debugger for a single program. Commands
+// are read from in, and the debugger's output, along with the program's, goes to out.
+type cliDebugger struct {
+	path  string
+	in    *bufio.Scanner
+	out   io.Writer
+	lines []string // lines of the program's source
+
+	breakpoints []cliBreakpoint
+	watches     []cliWatch
+	nextID      int
+
+	program  *debugProgram
+	debugger *Debugger
+	// ids maps the Debugger's breakpoint and watchpoint IDs to the numbers the user
+	// knows them by
+	ids      map[int]int
+	started  bool // whether the loaded program has been run
+	running  bool
+	stops    chan debugStop
+	finished chan struct{}
+}
+
+func NewCLIDebugger(path string, source string, in io.Reader, out io.Writer) *cliDebugger {
+	return &cliDebugger{
+		path:  path,
+		in:    bufio.NewScanner(in),
+		out:   out,
+		lines: strings.Split(source, "\n"),
+	}
+}
+
+// load prepares the program to be run, applying the current breakpoints and
+// watchpoints
+func (c *cliDebugger) load() error {
+	program, diagnostics, err := loadDebugProgram(c.path, func(diag diagnostic) {
+		fmt.Fprintln(c.out, diag.String())
+	})
+	for _, diag := range diagnostics {
+		fmt.Fprintln(os.Stderr, diag.String())
+	}
+	if err != nil {
+		return err
+	}
+
+	c.program = program
+	c.started = false
+	program.interpreter.stdout = c.out
+	c.stops = make(chan debugStop)
+	c.finished = make(chan struct{}, 1)
+	c.debugger = NewDebugger(program.interpreter, program.stmtLines, func(reason string, line int) {
+		c.stops <- debugStop{reason, line}
+	})
+	c.ids = make(map[int]int)
+	if _, err := c.applyBreakpoints(); err != nil {
+		return err
+	}
+	for _, watch := range c.watches {
+		w, err := c.debugger.addWatch(watch.source)
+		if err != nil {
+			return err
+		}
+		c.ids[w.id] = watch.id
+	}
+	return nil
+}
+
+// applyBreakpoints sets the user's breakpoints in the Debugger, returning the
+// Debugger's breakpoints in the same order (nil where a breakpoint couldn't be set)
+func (c *cliDebugger) applyBreakpoints() ([]*breakpoint, error) {
+	lines := make([]int, 0, len(c.breakpoints))
+	conditions := make([]string, 0, len(c.breakpoints))
+	for _, bp := range c.breakpoints {
+		lines = append(lines, bp.line)
+		conditions = append(conditions, bp.condition)
+	}
+	set, err := c.debugger.setBreakpoints(lines, conditions)
+	if err != nil {
+		return nil, err
+	}
+	for i, bp := range set {
+		if bp != nil {
+			c.ids[bp.id] = c.breakpoints[i].id
+		}
+	}
+	return set, nil
+}
+
+// run reads and executes commands until the user quits or the input ends, returning
+// the process exit code
+func (c *cliDebugger) run() int {
+	fmt.Fprintf(c.out, "Debugging %s. Type 'help' for a list of commands.\n", c.path)
+	for {
+		fmt.Fprint(c.out, "(glox) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			c.quit()
+			return 0
+		}
+
+		command, argument, _ := strings.Cut(strings.TrimSpace(c.in.Text()), " ")
+		argument = strings.TrimSpace(argument)
+		switch command {
+		case "":
+			continue
+		case "help", "h":
+			fmt.Fprint(c.out, debugCLIHelp)
+		case "break", "b":
+			c.addBreakpoint(argument)
+		case "watch":
+			c.addWatch(argument)
+		case "delete", "d":
+			c.delete(argument)
+		case "run", "r":
+			c.start()
+		case "continue", "c":
+			c.resume(stepContinue)
+		case "step", "s":
+			c.resume(stepIn)
+		case "next", "n":
+			c.resume(stepOver)
+		case "finish":
+			c.resume(stepOut)
+		case "print", "p":
+			c.print(argument)
+		case "bt", "backtrace":
+			c.backtrace()
+		case "locals":
+			c.locals()
+		case "quit", "q":
+			c.quit()
+			return 0
+		default:
+			fmt.Fprintf(c.out, "Unknown command '%s'. Type 'help' for a list of commands.\n", command)
+		}
+	}
+}
+
+// addBreakpoint handles 'break [file:]line [if condition]'
+func (c *cliDebugger) addBreakpoint(argument string) {
+	location, condition, _ := strings.Cut(argument, " if ")
+	location = strings.TrimSpace(location)
+	if file, line, found := strings.Cut(location, ":"); found {
+		if filepath.Base(file) != filepath.Base(c.path) {
+			fmt.Fprintf(c.out, "No source file named %s.\n", file)
+			return
+		}
+		location = line
+	}
+	line, err := strconv.Atoi(location)
+	if err != nil || line < 1 {
+		fmt.Fprintln(c.out, "Usage: break [file:]line [if condition]")
+		return
+	}
+
+	c.nextID++
+	bp := cliBreakpoint{id: c.nextID, line: line, condition: strings.TrimSpace(condition)}
+	c.breakpoints = append(c.breakpoints, bp)
+	set, err := c.applyBreakpoints()
+	if err == nil && set[len(set)-1] == nil {
+		err = fmt.Errorf("no statement at or after line %d", line)
+	}
+	if err != nil {
+		c.breakpoints = c.breakpoints[:len(c.breakpoints)-1]
+		c.nextID--
+		c.applyBreakpoints()
+		fmt.Fprintf(c.out, "Can't set breakpoint: %v.\n", err)
+		return
+	}
+	fmt.Fprintf(c.out, "Breakpoint %d at %s:%d\n", bp.id, filepath.Base(c.path), set[len(set)-1].line)
+}
+
+// addWatch handles 'watch expression'
+func (c *cliDebugger) addWatch(source string) {
+	if source == "" {
+		fmt.Fprintln(c.out, "Usage: watch expression")
+		return
+	}
+	w, err := c.debugger.addWatch(source)
+	if err != nil {
+		fmt.Fprintf(c.out, "Can't watch '%s': %v.\n", source, err)
+		return
+	}
+	c.nextID++
+	c.watches = append(c.watches, cliWatch{c.nextID, source})
+	c.ids[w.id] = c.nextID
+	fmt.Fprintf(c.out, "Watchpoint %d: %s\n", c.nextID, source)
+}
+
+// delete handles 'delete number'
+func (c *cliDebugger) delete(argument string) {
+	id, err := strconv.Atoi(argument)
+	if err != nil {
+		fmt.Fprintln(c.out, "Usage: delete number")
+		return
+	}
+
+	for i, bp := range c.breakpoints {
+		if bp.id == id {
+			c.breakpoints = append(c.breakpoints[:i], c.breakpoints[i+1:]...)
+			c.applyBreakpoints()
+			fmt.Fprintf(c.out, "Deleted breakpoint %d\n", id)
+			return
+		}
+	}
+	for i, watch := range c.watches {
+		if watch.id == id {
+			c.watches = append(c.watches[:i], c.watches[i+1:]...)
+			for debuggerID, cliID := range c.ids {
+				if cliID == id {
+					c.debugger.removeWatch(debuggerID)
+				}
+			}
+			fmt.Fprintf(c.out, "Deleted watchpoint %d\n", id)
+			return
+		}
+	}
+	fmt.Fprintf(c.out, "No breakpoint or watchpoint number %d.\n", id)
+}
+
+// start handles 'run', running the program from the beginning
+func (c *cliDebugger) start() {
+	if c.running {
+		fmt.Fprintln(c.out, "The program is already running.")
+		return
+	}
+	// A program can only be run once, so it's reloaded if it has been run before
+	if c.started {
+		if err := c.load(); err != nil {
+			fmt.Fprintf(c.out, "Can't reload %s: %v.\n", c.path, err)
+			return
+		}
+	}
+
+	c.started, c.running = true, true
+	program, finished := c.program, c.finished
+	go func() {
+		program.interpreter.interpret(program.statements)
+		finished <- struct{}{}
+	}()
+	c.wait()
+}
+
+func (c *cliDebugger) resume(mode stepMode) {
+	if !c.running {
+		fmt.Fprintln(c.out, "The program is not being run.")
+		return
+	}
+	c.debugger.resume(mode)
+	c.wait()
+}
+
+// wait waits for the program to stop or finish, and reports what happened
+func (c *cliDebugger) wait() {
+	select {
+	case stop := <-c.stops:
+		switch stop.reason {
+		case "breakpoint":
+			fmt.Fprintf(c.out, "Breakpoint %d, ", c.ids[c.debugger.breakpoints[stop.line].id])
+		case "watch":
+			watch := c.debugger.triggered
+			fmt.Fprintf(c.out, "Watchpoint %d: %s\nOld value = %s\nNew value = %s\n",
+				c.ids[watch.id], watch.source, formatDebugValue(watch.oldValue), formatDebugValue(watch.value))
+		}
+		frame := c.topFrame()
+		fmt.Fprintf(c.out, "%s at %s:%d\n", frame.displayName(), filepath.Base(c.path), stop.line)
+		if stop.line <= len(c.lines) {
+			fmt.Fprintf(c.out, "%d\t%s\n", stop.line, c.lines[stop.line-1])
+		}
+	case <-c.finished:
+		c.running = false
+		if c.program.runtime.hadError() {
+			fmt.Fprintln(c.out, "Program exited with code 70.")
+		} else {
+			fmt.Fprintln(c.out, "Program exited normally.")
+		}
+	}
+}
+
+func (c *cliDebugger) topFrame() *callFrame {
+	frames := c.program.interpreter.frames
+	return frames[len(frames)-1]
+}
+
+// print handles 'print expression'
+func (c *cliDebugger) print(source string) {
+	if !c.running {
+		fmt.Fprintln(c.out, "The program is not being run.")
+		return
+	}
+	value, err := c.debugger.evaluateIn(c.topFrame(), source)
+	if err != nil {
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintln(c.out, formatDebugValue(value))
+}
+
+// backtrace handles 'bt'
+func (c *cliDebugger) backtrace() {
+	if !c.running {
+		fmt.Fprintln(c.out, "The program is not being run.")
+		return
+	}
+	frames := c.program.interpreter.frames
+	for i := len(frames) - 1; i >= 0; i-- {
+		fmt.Fprintf(c.out, "#%d  %s at %s:%d\n", len(frames)-1-i, frames[i].displayName(), filepath.Base(c.path), frames[i].line)
+	}
+}
+
+// locals handles 'locals', printing the variables in the current frame's local and
+// closure scopes
+func (c *cliDebugger) locals() {
+	if !c.running {
+		fmt.Fprintln(c.out, "The program is not being run.")
+		return
+	}
+	found := false
+	for _, scope := range c.debugger.scopes(c.topFrame()) {
+		if scope.name == "Globals" {
+			continue
+		}
+		for _, variable := range scope.variables() {
+			fmt.Fprintf(c.out, "%s = %s\n", variable.name, formatDebugValue(variable.value))
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintln(c.out, "No locals.")
+	}
+}
+
+func (c *cliDebugger) quit() {
+	if c.running {
+		c.debugger.terminate()
+		<-c.finished
+		c.running = false
+	}
+}
+
+// formatDebugValue describes a value for the command-line debugger, including the
+// fields of instances
+func formatDebugValue(value any) string {
+	description, _ := describeValue(value)
+	if instance, ok := value.(*LoxInstance); ok {
+		fields := make([]string, 0, len(instance.fields))
+		for _, field := range instanceFields(instance) {
+			fieldValue, _ := describeValue(field.value)
+			fields = append(fields, field.name+" = "+fieldValue)
+		}
+		description += " {" + strings.Join(fields, ", ") + "}"
+	}
+	return description
+}
+
+// debugCommand implements 'glox debug', an interactive command-line debugger
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox debug file.lox")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 64
+	}
+
+	path := flags.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox debug: %v\n", err)
+		return 66
+	}
+
+	debugger := NewCLIDebugger(path, string(data), os.Stdin, os.Stdout)
+	if err := debugger.load(); err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			fmt.Fprintf(os.Stderr, "glox debug: %v\n", err)
+			return 66
+		}
+		return 65
+	}
+	return debugger.run()
+}
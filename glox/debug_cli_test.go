@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// COMMAND-LINE DEBUGGER TESTS
+// ============================================================================
+
+// runDebugSession runs the command-line debugger on a program with the supplied
+// commands, returning everything it printed
+func runDebugSession(t *testing.T, program string, commands ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "program.lox")
+	writeTestFile(t, path, program)
+
+	var out strings.Builder
+	debugger := NewCLIDebugger(path, program, strings.NewReader(strings.Join(commands, "\n")+"\n"), &out)
+	if err := debugger.load(); err != nil {
+		t.Fatalf("Failed to load program: %v", err)
+	}
+	assertEqual(t, 0, debugger.run(), "Exit code")
+	return out.String()
+}
+
+func TestCLIDebugger(t *testing.T) {
+	t.Run("Breakpoint, backtrace, locals and print", func(t *testing.T) {
+		output := runDebugSession(t, dapTestProgram,
+			"break program.lox:6",
+			"run",
+			"bt",
+			"locals",
+			"print this.count * 2",
+			"continue",
+			"print n",
+			"delete 1",
+			"continue",
+		)
+		assertContains(t, output, "Breakpoint 1 at program.lox:6\n")
+		assertContains(t, output, "Breakpoint 1, Counter.add at program.lox:6\n6\t        var total = this.count + n;\n")
+		assertContains(t, output, "#0  Counter.add at program.lox:6\n#1  run at program.lox:15\n#2  <script> at program.lox:20\n")
+		assertContains(t, output, "n = 0\nthis = Counter instance {count = 10}\n")
+		assertContains(t, output, "(glox) 20\n")
+		assertContains(t, output, "(glox) 1\n")
+		assertContains(t, output, "Deleted breakpoint 1\n(glox) 13\ndone\nProgram exited normally.\n")
+	})
+
+	t.Run("Stepping", func(t *testing.T) {
+		output := runDebugSession(t, dapTestProgram,
+			"break 13",
+			"run",
+			"step",
+			"finish",
+			"next",
+			"next",
+			"quit",
+		)
+		assertContains(t, output, "Breakpoint 1, run at program.lox:13\n")
+		assertContains(t, output, "(glox) Counter.init at program.lox:3\n3\t        this.count = start;\n")
+		assertContains(t, output, "(glox) run at program.lox:14\n")
+		assertContains(t, output, "(glox) run at program.lox:15\n")
+		assertContains(t, output, "(glox) run at program.lox:14\n14\t    for (var i = 0; i < limit; i = i + 1) {\n(glox) ")
+	})
+
+	t.Run("Conditional breakpoint", func(t *testing.T) {
+		output := runDebugSession(t, dapTestProgram,
+			"break 15 if i == 1",
+			"run",
+			"print i",
+			"quit",
+		)
+		assertContains(t, output, "Breakpoint 1, run at program.lox:15\n")
+		assertContains(t, output, "(glox) 1\n")
+	})
+
+	t.Run("Watchpoint", func(t *testing.T) {
+		output := runDebugSession(t, "var a = 1;\nvar b = 2;\na = a + b;\nprint a;\n",
+			"watch a",
+			"run",
+			"continue",
+		)
+		assertContains(t, output, "Watchpoint 1: a\n")
+		assertContains(t, output, "Watchpoint 1: a\nOld value = 1\nNew value = 3\n<script> at program.lox:4\n4\tprint a;\n")
+		assertContains(t, output, "(glox) 3\nProgram exited normally.\n")
+	})
+
+	t.Run("Program can be run again", func(t *testing.T) {
+		output := runDebugSession(t, "print \"hi\";\n", "run", "run")
+		assertEqual(t, 2, strings.Count(output, "hi\nProgram exited normally."), "Runs")
+	})
+
+	t.Run("Runtime errors are reported", func(t *testing.T) {
+		output := runDebugSession(t, "print -\"a\";\n", "run")
+		assertContains(t, output, "[line 1] ")
+		assertContains(t, output, "Program exited with code 70.\n")
+	})
+
+	t.Run("Invalid commands", func(t *testing.T) {
+		output := runDebugSession(t, "print 1;\n",
+			"break other.lox:1",
+			"break 50",
+			"print 1",
+			"frobnicate",
+			"delete 7",
+		)
+		assertContains(t, output, "No source file named other.lox.\n")
+		assertContains(t, output, "Can't set breakpoint: no statement at or after line 50.\n")
+		assertContains(t, output, "The program is not being run.\n")
+		assertContains(t, output, "Unknown command 'frobnicate'")
+		assertContains(t, output, "No breakpoint or watchpoint number 7.\n")
+	})
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// stepMode determines where a debugged program next stops after being resumed
+type stepMode int
+
+const (
+	stepContinue  stepMode = iota // run until a breakpoint is hit, or a pause is requested
+	stepIn                        // stop at the next statement, in any function
+	stepOver                      // stop at the next statement in the current function or its callers
+	stepOut                       // stop at the next statement in a caller of the current function
+	stepTerminate                 // stop running the program
+)
+
+// errDebugTerminated is returned by the debugger's execution hook to abort a program
+// that the client has asked to terminate
+var errDebugTerminated = errors.New("program terminated by debugger")
+
+type breakpoint struct {
+	id        int
+	line      int
+	condition Expr // nil for an unconditional breakpoint
+}
+
+// watchpoint stops the program when the value of an expression changes
+type watchpoint struct {
+	id       int
+	source   string
+	expr     Expr
+	value    any
+	hasValue bool // false until the expression has been successfully evaluated
+	oldValue any  // value before the change that most recently stopped the program
+}
+
+// Debugger controls the execution of a program by an Interpreter, stopping at
+// breakpoints and stepping through statements. While stopped, the program's call
+// frames and variables can be inspected.
+type Debugger struct {
+	interpreter *Interpreter
+	stmtLines   map[Stmt]int // lines of statements in the program, from the Parser
+
+	lock           sync.Mutex
+	breakpoints    map[int]*breakpoint // keyed by line
+	watches        []*watchpoint
+	nextID         int // for breakpoints and watchpoints
+	pauseRequested bool
+	terminated     bool
+
+	mode      stepMode
+	stepDepth int // number of frames when the current step started
+
+	stopped    bool
+	evaluating bool // set while evaluating an expression for the client
+	// triggered is the watchpoint that most recently stopped the program
+	triggered *watchpoint
+
+	// onStop is called when the program stops, with the reason (eg "breakpoint") and
+	// the line at which it stopped. The program stays stopped until resume is called.
+	onStop  func(reason string, line int)
+	resumed chan stepMode
+}
+
+func NewDebugger(interpreter *Interpreter, stmtLines map[Stmt]int, onStop func(reason string, line int)) *Debugger {
+	d := &Debugger{
+		interpreter: interpreter,
+		stmtLines:   stmtLines,
+		breakpoints: make(map[int]*breakpoint),
+		onStop:      onStop,
+		resumed:     make(chan stepMode),
+	}
+	interpreter.hook = d
+	return d
+}
+
+// setBreakpoints replaces all breakpoints. Breakpoints on lines without a statement
+// are moved to the next line that has one. Returns the breakpoints that were set, in
+// the same order as the requested lines; a nil entry means no statement was found for
+// the breakpoint.
+func (d *Debugger) setBreakpoints(lines []int, conditions []string) ([]*breakpoint, error) {
+	statementLines := make([]int, 0, len(d.stmtLines))
+	for stmt, line := range d.stmtLines {
+		if _, isBlock := stmt.(*BlockStmt); !isBlock {
+			statementLines = append(statementLines, line)
+		}
+	}
+	sort.Ints(statementLines)
+
+	result := make([]*breakpoint, 0, len(lines))
+	breakpoints := make(map[int]*breakpoint)
+	for i, line := range lines {
+		var condition Expr
+		if i < len(conditions) && conditions[i] != "" {
+			var err error
+			if condition, err = parseDebugExpression(conditions[i]); err != nil {
+				return nil, fmt.Errorf("invalid condition on line %d: %v", line, err)
+			}
+		}
+
+		index := sort.SearchInts(statementLines, line)
+		if index == len(statementLines) {
+			result = append(result, nil)
+			continue
+		}
+
+		d.lock.Lock()
+		d.nextID++
+		bp := &breakpoint{id: d.nextID, line: statementLines[index], condition: condition}
+		d.lock.Unlock()
+		breakpoints[bp.line] = bp
+		result = append(result, bp)
+	}
+
+	d.lock.Lock()
+	d.breakpoints = breakpoints
+	d.lock.Unlock()
+	return result, nil
+}
+
+// addWatch adds a watchpoint for the supplied expression
+func (d *Debugger) addWatch(source string) (*watchpoint, error) {
+	expr, err := parseDebugExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.nextID++
+	watch := &watchpoint{id: d.nextID, source: source, expr: expr}
+	d.watches = append(d.watches, watch)
+	return watch, nil
+}
+
+// removeWatch deletes the watchpoint with the supplied ID
+func (d *Debugger) removeWatch(id int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, watch := range d.watches {
+		if watch.id == id {
+			d.watches = append(d.watches[:i], d.watches[i+1:]...)
+			return
+		}
+	}
+}
+
+// beforeStatement implements executionHook, deciding whether to stop before the
+// supplied statement is executed
+func (d *Debugger) beforeStatement(stmt Stmt) error {
+	if d.evaluating {
+		return nil
+	}
+
+	d.lock.Lock()
+	terminated := d.terminated
+	d.lock.Unlock()
+	if terminated {
+		return errDebugTerminated
+	}
+
+	// Blocks aren't stopped at, as they don't do anything themselves
+	line, ok := d.stmtLines[stmt]
+	if _, isBlock := stmt.(*BlockStmt); !ok || isBlock {
+		return nil
+	}
+
+	// A statement is a new stopping point in its frame if it's on a different line to
+	// the previous statement, or if the same statement is being executed again (eg in a
+	// loop). This avoids stopping at every statement on a single line.
+	frame := d.interpreter.frames[len(d.interpreter.frames)-1]
+	isNewLine := line != frame.line || stmt == frame.stmt
+	frame.line, frame.stmt, frame.env = line, stmt, d.interpreter.currentEnv
+
+	reason := d.stopReason(line, isNewLine)
+	if reason == "" {
+		return nil
+	}
+
+	d.lock.Lock()
+	d.stopped = true
+	d.lock.Unlock()
+	d.onStop(reason, line)
+
+	mode := <-d.resumed
+	if mode == stepTerminate {
+		return errDebugTerminated
+	}
+	d.mode = mode
+	d.stepDepth = len(d.interpreter.frames)
+	return nil
+}
+
+// stopReason returns why the program should stop at a statement on the supplied
+// line, or "" if it shouldn't
+func (d *Debugger) stopReason(line int, isNewLine bool) string {
+	d.lock.Lock()
+	pause := d.pauseRequested
+	d.pauseRequested = false
+	bp := d.breakpoints[line]
+	d.lock.Unlock()
+
+	if pause {
+		return "pause"
+	}
+	if d.changedWatch() != nil {
+		return "watch"
+	}
+	if !isNewLine {
+		return ""
+	}
+
+	depth := len(d.interpreter.frames)
+	switch {
+	case d.mode == stepIn,
+		d.mode == stepOver && depth <= d.stepDepth,
+		d.mode == stepOut && depth < d.stepDepth:
+		return "step"
+	}
+
+	if bp != nil && d.conditionHolds(bp) {
+		return "breakpoint"
+	}
+	return ""
+}
+
+// changedWatch evaluates the watched expressions, returning the first whose value has
+// changed. Expressions that can't currently be evaluated (eg because they refer to
+// variables that aren't in scope) are skipped.
+func (d *Debugger) changedWatch() *watchpoint {
+	d.lock.Lock()
+	watches := append([]*watchpoint(nil), d.watches...)
+	d.lock.Unlock()
+	if len(watches) == 0 {
+		return nil
+	}
+
+	frame := d.interpreter.frames[len(d.interpreter.frames)-1]
+	for _, watch := range watches {
+		value, err := d.evaluate(frame, watch.expr)
+		if err != nil {
+			continue
+		}
+		if !watch.hasValue {
+			watch.value, watch.hasValue = value, true
+			continue
+		}
+		if !isEqual(value, watch.value) {
+			watch.oldValue, watch.value = watch.value, value
+			d.triggered = watch
+			return watch
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) conditionHolds(bp *breakpoint) bool {
+	if bp.condition == nil {
+		return true
+	}
+	frame := d.interpreter.frames[len(d.interpreter.frames)-1]
+	value, err := d.evaluate(frame, bp.condition)
+	// A condition that can't be evaluated stops the program, so the problem is noticed
+	return err != nil || isTruthy(value)
+}
+
+// resume continues a stopped program with the supplied step mode
+func (d *Debugger) resume(mode stepMode) bool {
+	d.lock.Lock()
+	if !d.stopped {
+		d.lock.Unlock()
+		return false
+	}
+	d.stopped = false
+	d.lock.Unlock()
+
+	d.resumed <- mode
+	return true
+}
+
+// pause asks a running program to stop at the next statement
+func (d *Debugger) pause() {
+	d.lock.Lock()
+	d.pauseRequested = true
+	d.lock.Unlock()
+}
+
+// terminate stops the program, whether it's running or stopped
+func (d *Debugger) terminate() {
+	d.lock.Lock()
+	d.terminated = true
+	d.lock.Unlock()
+	d.resume(stepTerminate)
+}
+
+func (d *Debugger) isStopped() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.stopped
+}
+
+// ----------------------------------------------------------------------------
+// Loading a program
+// ----------------------------------------------------------------------------
+
+// debugProgram is a program that has been loaded to run under the debugger
+type debugProgram struct {
+	path        string
+	statements  []Stmt
+	stmtLines   map[Stmt]int
+	interpreter *Interpreter
+	runtime     *debugRuntime
+}
+
+// loadDebugProgram reads, parses and resolves a program, using the lint rules from the
+// project config file. Any problems found are returned as diagnostics; runtime errors
+// are passed to reportRuntimeError as the program runs.
+func loadDebugProgram(path string, reportRuntimeError func(diagnostic)) (*debugProgram, []diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := loadProjectConfig(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, string(data))
+	tokens := scanner.scanTokens()
+	parser := NewParser(collector, tokens)
+	statements, _ := parser.parse()
+
+	runtime := &debugRuntime{NewDiagnosticCollector(), reportRuntimeError}
+	interpreter := NewInterpreter(runtime)
+	if !collector.hadError() {
+		resolver := NewResolver(collector, interpreter)
+		resolver.linter = NewLinter(collector, config.lint, scanner.lintDirectives, parser)
+		resolver.resolveStmts(statements)
+	}
+	if collector.hadError() {
+		return nil, collector.diagnostics, errors.New("the program has errors")
+	}
+
+	return &debugProgram{
+		path:        path,
+		statements:  statements,
+		stmtLines:   parser.stmtLines,
+		interpreter: interpreter,
+		runtime:     runtime,
+	}, collector.diagnostics, nil
+}
+
+// debugRuntime records runtime errors in a program being debugged, additionally
+// reporting them as they happen. The error used to terminate the program isn't
+// reported.
+type debugRuntime struct {
+	*diagnosticCollector
+	report func(diagnostic)
+}
+
+func (r *debugRuntime) runtimeError(err error) {
+	if errors.Is(err, errDebugTerminated) {
+		return
+	}
+	r.diagnosticCollector.runtimeError(err)
+	r.report(r.diagnostics[len(r.diagnostics)-1])
+}
+
+// ----------------------------------------------------------------------------
+// Inspecting a stopped program
+// ----------------------------------------------------------------------------
+
+// debugScope is a named group of variables visible in a call frame, with the
+// environments that hold them from innermost to outermost
+type debugScope struct {
+	name string
+	envs []*Environment
+}
+
+// scopes returns the variables visible in a call frame: its locals, any variables
+// captured by the function's closure (including 'this' for methods), and globals
+func (d *Debugger) scopes(frame *callFrame) []debugScope {
+	globals := d.interpreter.globalEnv
+	stopAt := globals
+	if frame.function != nil {
+		stopAt = frame.function.closure
+	}
+
+	locals := debugScope{name: "Locals", envs: make([]*Environment, 0)}
+	env := frame.env
+	for ; env != nil && env != stopAt && env != globals; env = env.enclosing {
+		locals.envs = append(locals.envs, env)
+	}
+	closure := debugScope{name: "Closure", envs: make([]*Environment, 0)}
+	for ; env != nil && env != globals; env = env.enclosing {
+		closure.envs = append(closure.envs, env)
+	}
+
+	scopes := []debugScope{locals}
+	if len(closure.envs) > 0 {
+		scopes = append(scopes, closure)
+	}
+	return append(scopes, debugScope{name: "Globals", envs: []*Environment{globals}})
+}
+
+// debugVariable is a named value shown to the client
+type debugVariable struct {
+	name  string
+	value any
+}
+
+// variables returns the variables in a scope, sorted by name. Where a name is
+// defined in more than one environment, the innermost definition is used.
+func (s debugScope) variables() []debugVariable {
+	seen := make(map[string]bool)
+	variables := make([]debugVariable, 0)
+	for _, env := range s.envs {
+		for name, value := range env.values {
+			if !seen[name] {
+				seen[name] = true
+				variables = append(variables, debugVariable{name, value})
+			}
+		}
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].name < variables[j].name })
+	return variables
+}
+
+// instanceFields returns the fields of an instance, sorted by name
+func instanceFields(instance *LoxInstance) []debugVariable {
+	fields := make([]debugVariable, 0, len(instance.fields))
+	for name, value := range instance.fields {
+		fields = append(fields, debugVariable{name, value})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// describeValue returns how a value is shown in the debugger, along with its type
+func describeValue(value any) (string, string) {
+	switch v := value.(type) {
+	case nil:
+		return "nil", "nil"
+	case bool:
+		return strconv.FormatBool(v), "boolean"
+	case float64:
+		return fmt.Sprintf("%v", v), "number"
+	case string:
+		return strconv.Quote(v), "string"
+	case *LoxFunction:
+		return "<fn " + v.declaration.functionName.lexeme + ">", "function"
+	case *LoxBoundMethod:
+		return "<fn " + v.name() + ">", "function"
+	case *LoxClass:
+		return "<class " + v.name + ">", "class"
+	case *LoxTrait:
+		return "<trait " + v.name + ">", "trait"
+	case *LoxInstance:
+		return v.class.name + " instance", "instance"
+	case LoxCallable:
+		return "<native fn>", "function"
+	default:
+		return fmt.Sprintf("%v", v), ""
+	}
+}
+
+// evaluateIn parses and evaluates an expression in the context of a call frame
+func (d *Debugger) evaluateIn(frame *callFrame, source string) (any, error) {
+	expr, err := parseDebugExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	return d.evaluate(frame, expr)
+}
+
+func (d *Debugger) evaluate(frame *callFrame, expr Expr) (any, error) {
+	// The expression hasn't been through the Resolver, so bind its variables to
+	// the environments they're found in from the frame's point of view
+	bound := make([]Expr, 0)
+	d.bindVariables(expr, frame.env, &bound)
+	defer func() {
+		for _, e := range bound {
+			delete(d.interpreter.locals, e)
+		}
+	}()
+
+	previousEnv := d.interpreter.currentEnv
+	d.interpreter.currentEnv = frame.env
+	d.evaluating = true
+	defer func() {
+		d.interpreter.currentEnv = previousEnv
+		d.evaluating = false
+	}()
+	return d.interpreter.evaluate(expr)
+}
+
+// bindVariables records the environment distance of each variable referenced in an
+// expression, as the Resolver does for the program itself
+func (d *Debugger) bindVariables(expr Expr, env *Environment, bound *[]Expr) {
+	bind := func(e Expr, name string) {
+		distance := 0
+		for scope := env; scope != nil && scope != d.interpreter.globalEnv; scope = scope.enclosing {
+			if _, ok := scope.values[name]; ok {
+				d.interpreter.locals[e] = distance
+				*bound = append(*bound, e)
+				return
+			}
+			distance++
+		}
+	}
+
+	switch e := expr.(type) {
+	case *VariableExpr:
+		bind(e, e.variable.lexeme)
+	case *ThisExpr:
+		bind(e, "this")
+	case *AssignExpr:
+		bind(e, e.variable.lexeme)
+		d.bindVariables(e.value, env, bound)
+	case *BinaryExpr:
+		d.bindVariables(e.Left, env, bound)
+		d.bindVariables(e.Right, env, bound)
+	case *LogicalExpr:
+		d.bindVariables(e.Left, env, bound)
+		d.bindVariables(e.Right, env, bound)
+	case *UnaryExpr:
+		d.bindVariables(e.Right, env, bound)
+	case *GroupingExpr:
+		d.bindVariables(e.Expression, env, bound)
+	case *CallExpr:
+		d.bindVariables(e.Callee, env, bound)
+		for _, arg := range e.Arguments {
+			d.bindVariables(arg, env, bound)
+		}
+	case *InnerExpr:
+		for _, arg := range e.arguments {
+			d.bindVariables(arg, env, bound)
+		}
+	case *IndexExpr:
+		d.bindVariables(e.object, env, bound)
+		d.bindVariables(e.index, env, bound)
+	case *PropGetExpr:
+		d.bindVariables(e.object, env, bound)
+	case *PropSetExpr:
+		d.bindVariables(e.object, env, bound)
+		d.bindVariables(e.propValue, env, bound)
+	}
+}
+
+// parseDebugExpression parses a single expression, eg a breakpoint condition
+func parseDebugExpression(source string) (Expr, error) {
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, source)
+	tokens := scanner.scanTokens()
+	if collector.hadError() {
+		return nil, errors.New(collector.diagnostics[0].message)
+	}
+
+	parser := NewParser(collector, tokens)
+	expr, err := parser.expression()
+	if err != nil || collector.hadError() {
+		if len(collector.diagnostics) > 0 {
+			return nil, errors.New(collector.diagnostics[0].message)
+		}
+		return nil, err
+	}
+	if !parser.isAtEnd() {
+		return nil, errors.New("unexpected '" + parser.peek().lexeme + "' after expression")
+	}
+	return expr, nil
+}
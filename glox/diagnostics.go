@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+)
+
+type diagnosticKind int
+
+const (
+	diagnosticError diagnosticKind = iota
+	diagnosticWarning
+	diagnosticRuntimeError
+)
+
+// diagnostic is a single error or warning reported while processing Lox source
+type diagnostic struct {
+	kind    diagnosticKind
+	line    int
+	token   *Token // token at which the problem was found, if known
+	message string
+}
+
+func (d diagnostic) String() string {
+	label := "Error"
+	if d.kind == diagnosticWarning {
+		label = "Warning"
+	}
+	if d.kind == diagnosticRuntimeError {
+		return fmt.Sprintf("[line %d] %s", d.line, d.message)
+	}
+
+	where := ""
+	if d.token != nil {
+		if d.token.token_type == EOF {
+			where = " at end"
+		} else {
+			where = fmt.Sprintf(" at %s ", d.token.lexeme)
+		}
+	}
+	return fmt.Sprintf("[line %d] %s%s: %s", d.line, label, where, d.message)
+}
+
+// diagnosticCollector implements the LoxRuntime interface by recording errors and
+// warnings instead of printing them, for use by tools that process Lox source
+// without running it
+type diagnosticCollector struct {
+	diagnostics []diagnostic
+}
+
+func NewDiagnosticCollector() *diagnosticCollector {
+	return &diagnosticCollector{diagnostics: make([]diagnostic, 0)}
+}
+
+func (d *diagnosticCollector) error(line int, message string) {
+	d.diagnostics = append(d.diagnostics, diagnostic{kind: diagnosticError, line: line, message: message})
+}
+
+func (d *diagnosticCollector) parseError(token Token, message string) {
+	d.diagnostics = append(d.diagnostics, diagnostic{kind: diagnosticError, line: token.line, token: &token, message: message})
+}
+
+func (d *diagnosticCollector) runtimeError(err error) {
+	runtimeErr, _ := err.(RuntimeError)
+	d.diagnostics = append(d.diagnostics,
+		diagnostic{kind: diagnosticRuntimeError, line: runtimeErr.token.line, token: &runtimeErr.token, message: err.Error()})
+}
+
+func (d *diagnosticCollector) warning(token Token, message string) {
+	d.diagnostics = append(d.diagnostics, diagnostic{kind: diagnosticWarning, line: token.line, token: &token, message: message})
+}
+
+func (d *diagnosticCollector) hadError() bool {
+	for _, diag := range d.diagnostics {
+		if diag.kind != diagnosticWarning {
+			return true
+		}
+	}
+	return false
+}
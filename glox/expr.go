@@ -19,6 +19,8 @@ type ExprVisitor interface {
 	VisitVariableExpr(expr *VariableExpr) (any, error)
 	VisitThisExpr(expr *ThisExpr) (any, error)
 	VisitSuperExpr(expr *SuperExpr) (any, error)
+	VisitInnerExpr(expr *InnerExpr) (any, error)
+	VisitIndexExpr(expr *IndexExpr) (any, error)
 }
 
 // AssignExpr represents an assignment expression
@@ -140,3 +142,27 @@ type SuperExpr struct {
 func (s *SuperExpr) Accept(visitor ExprVisitor) (any, error) {
 	return visitor.VisitSuperExpr(s)
 }
+
+// InnerExpr represents a call to 'inner', which calls the next more derived
+// refinement of the method that it's in
+type InnerExpr struct {
+	keyword   Token
+	paren     Token
+	arguments []Expr
+}
+
+func (e *InnerExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitInnerExpr(e)
+}
+
+// IndexExpr represents indexing an object eg matrix[row], which calls the object's
+// __index method
+type IndexExpr struct {
+	object  Expr
+	bracket Token
+	index   Expr
+}
+
+func (e *IndexExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitIndexExpr(e)
+}
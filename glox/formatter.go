@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	fmtIndent       = "    "
+	fmtMaxLineWidth = 100
+)
+
+// formattedLine is a single line of formatted output: either a (possibly partial)
+// statement with an optional trailing comment, or a standalone comment
+type formattedLine struct {
+	indent      int
+	tokens      []Token
+	comment     string
+	blankBefore bool // preserve a blank line separating this line from the previous one
+}
+
+// Formatter re-prints Lox source in a canonical layout. It works from the token stream
+// rather than the AST, since the parser desugars some constructs (eg 'for' loops) and
+// discards comments, both of which need to be preserved.
+type Formatter struct {
+	tokens      []Token // code tokens, excluding EOF
+	comments    []Token
+	nextComment int // index of the next comment that hasn't been printed yet
+
+	lines        []*formattedLine
+	current      *formattedLine
+	indent       int
+	parenDepth   int
+	continuation bool // set when a statement is split across lines by a comment
+	lastLine     int  // source line of the most recently printed token or comment
+	lastCodeLine int  // source line of the most recently printed token
+}
+
+func NewFormatter(tokens []Token, comments []Token) *Formatter {
+	codeTokens := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		if token.token_type != EOF {
+			codeTokens = append(codeTokens, token)
+		}
+	}
+	return &Formatter{tokens: codeTokens, comments: comments}
+}
+
+// formatSource formats a complete Lox program. The source has to be syntactically
+// valid; any errors found are returned as diagnostics.
+func formatSource(source string) (string, []diagnostic, error) {
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, source)
+	scanner.keepComments = true
+	tokens := scanner.scanTokens()
+
+	// Refuse to format code that doesn't parse, since the layout rules rely on
+	// the code being well-formed
+	parser := NewParser(collector, tokens)
+	if _, err := parser.parse(); err != nil || collector.hadError() {
+		return "", collector.diagnostics, fmt.Errorf("source contains syntax errors")
+	}
+
+	formatted := NewFormatter(tokens, scanner.comments).format()
+
+	// Sanity check that formatting only changed the layout of the code
+	if err := checkSameTokens(source, formatted); err != nil {
+		return "", nil, err
+	}
+	return formatted, nil, nil
+}
+
+func (f *Formatter) format() string {
+	for i := 0; i < len(f.tokens); i++ {
+		token := f.tokens[i]
+		f.flushCommentsBefore(token.line)
+
+		switch token.token_type {
+		case LEFT_BRACE:
+			f.add(token)
+			// Keep empty blocks on a single line ie {}
+			if i+1 < len(f.tokens) && f.tokens[i+1].token_type == RIGHT_BRACE && !f.hasCommentBefore(f.tokens[i+1].line+1) {
+				i++
+				f.add(f.tokens[i])
+				f.endBlock(i)
+			} else {
+				f.newline()
+				f.indent++
+			}
+
+		case RIGHT_BRACE:
+			f.newline()
+			if f.indent > 0 {
+				f.indent--
+			}
+			f.add(token)
+			f.endBlock(i)
+
+		case LEFT_PAREN:
+			f.parenDepth++
+			f.add(token)
+
+		case RIGHT_PAREN:
+			if f.parenDepth > 0 {
+				f.parenDepth--
+			}
+			f.add(token)
+
+		case SEMICOLON:
+			f.add(token)
+			// Semicolons inside parentheses separate the clauses of a 'for' loop
+			if f.parenDepth == 0 {
+				f.newline()
+			}
+
+		default:
+			f.add(token)
+		}
+	}
+
+	f.flushCommentsBefore(f.lastLine + len(f.comments) + 1)
+	f.newline()
+
+	return f.render()
+}
+
+// endBlock starts a new line after a closing brace, unless the brace is followed by
+// something that belongs on the same line eg "} else {"
+func (f *Formatter) endBlock(braceIndex int) {
+	if braceIndex+1 < len(f.tokens) {
+		switch f.tokens[braceIndex+1].token_type {
+		case ELSE, SEMICOLON, RIGHT_PAREN, COMMA, DOT:
+			return
+		}
+	}
+	f.newline()
+}
+
+// add appends a token to the line currently being built
+func (f *Formatter) add(token Token) {
+	if f.current == nil {
+		f.startLine(token.line, token.token_type != RIGHT_BRACE)
+	}
+	f.current.tokens = append(f.current.tokens, token)
+	f.lastLine = token.line
+	f.lastCodeLine = token.line
+}
+
+func (f *Formatter) startLine(sourceLine int, allowBlank bool) {
+	indent := f.indent
+	if f.continuation {
+		indent++
+	}
+	f.current = &formattedLine{indent: indent}
+
+	// Preserve (at most one) blank line between statements, but not at the start of
+	// a block
+	if allowBlank && len(f.lines) > 0 && sourceLine > f.lastLine+1 {
+		previous := f.lines[len(f.lines)-1]
+		if len(previous.tokens) == 0 || previous.tokens[len(previous.tokens)-1].token_type != LEFT_BRACE {
+			f.current.blankBefore = true
+		}
+	}
+}
+
+// newline finishes the line currently being built
+func (f *Formatter) newline() {
+	if f.current != nil {
+		f.lines = append(f.lines, f.current)
+		f.current = nil
+	}
+	f.continuation = false
+}
+
+func (f *Formatter) hasCommentBefore(line int) bool {
+	return f.nextComment < len(f.comments) && f.comments[f.nextComment].line < line
+}
+
+// flushCommentsBefore prints all comments that appear in the source before the
+// given line
+func (f *Formatter) flushCommentsBefore(line int) {
+	for f.hasCommentBefore(line) {
+		comment := f.comments[f.nextComment]
+		f.nextComment++
+		text := strings.TrimRight(comment.lexeme, " \t\r")
+
+		if comment.line == f.lastCodeLine && (f.current != nil || len(f.lines) > 0) {
+			// Trailing comment, attach it to the line containing the preceding code. If
+			// the statement isn't finished, it has to continue on the next line.
+			if f.current != nil {
+				f.current.comment = text
+				f.lines = append(f.lines, f.current)
+				f.current = nil
+				f.continuation = true
+			} else {
+				f.lines[len(f.lines)-1].comment = text
+			}
+		} else {
+			// Comment on a line of its own
+			if f.current != nil {
+				f.lines = append(f.lines, f.current)
+				f.current = nil
+				f.continuation = true
+			}
+			f.startLine(comment.line, true)
+			f.current.comment = text
+			f.lines = append(f.lines, f.current)
+			f.current = nil
+		}
+		f.lastLine = comment.line
+	}
+}
+
+func (f *Formatter) render() string {
+	var sb strings.Builder
+	for _, line := range f.lines {
+		if line.blankBefore {
+			sb.WriteString("\n")
+		}
+
+		var rendered []string
+		if len(line.tokens) > 0 {
+			rendered = wrapTokens(line.tokens, line.indent)
+		}
+		if line.comment != "" {
+			if len(rendered) == 0 {
+				rendered = []string{strings.Repeat(fmtIndent, line.indent) + line.comment}
+			} else {
+				rendered[len(rendered)-1] += " " + line.comment
+			}
+		}
+
+		for _, text := range rendered {
+			sb.WriteString(strings.TrimRight(text, " "))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// wrapTokens renders a line of tokens, splitting long argument and parameter lists
+// so there's one argument per line eg
+//
+//	someFunction(
+//	    firstArgument,
+//	    secondArgument
+//	);
+func wrapTokens(tokens []Token, indent int) []string {
+	text := strings.Repeat(fmtIndent, indent) + renderTokens(tokens)
+	if len(text) <= fmtMaxLineWidth {
+		return []string{text}
+	}
+
+	open, close := findWrappableGroup(tokens)
+	if open < 0 {
+		return []string{text}
+	}
+
+	lines := wrapTokens(tokens[:open+1], indent)
+	arguments := splitTopLevel(tokens[open+1:close], COMMA)
+	for i, argument := range arguments {
+		if i < len(arguments)-1 { // keep the separating comma at the end of the line
+			argument = append(append([]Token(nil), argument...), Token{COMMA, ",", nil, 0, 0, 0})
+		}
+		lines = append(lines, wrapTokens(argument, indent+1)...)
+	}
+	return append(lines, wrapTokens(tokens[close:], indent)...)
+}
+
+// findWrappableGroup returns the positions of the first pair of parentheses that
+// enclose a comma-separated list, or -1 if there isn't one
+func findWrappableGroup(tokens []Token) (int, int) {
+	for open, token := range tokens {
+		if token.token_type != LEFT_PAREN {
+			continue
+		}
+
+		depth := 0
+		hasComma := false
+		for close := open; close < len(tokens); close++ {
+			switch tokens[close].token_type {
+			case LEFT_PAREN:
+				depth++
+			case RIGHT_PAREN:
+				depth--
+			case COMMA:
+				if depth == 1 {
+					hasComma = true
+				}
+			}
+			if depth == 0 {
+				if hasComma {
+					return open, close
+				}
+				break
+			}
+		}
+	}
+	return -1, -1
+}
+
+// splitTopLevel splits a list of tokens at each separator that isn't nested
+// inside parentheses. The separators themselves are dropped.
+func splitTopLevel(tokens []Token, separator TokenType) [][]Token {
+	parts := make([][]Token, 0)
+	depth := 0
+	start := 0
+	for i, token := range tokens {
+		switch token.token_type {
+		case LEFT_PAREN:
+			depth++
+		case RIGHT_PAREN:
+			depth--
+		case separator:
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// renderTokens prints a sequence of tokens on a single line, with canonical
+// spacing between them
+func renderTokens(tokens []Token) string {
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 && needsSpaceBefore(tokens, i) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(token.lexeme)
+	}
+	return sb.String()
+}
+
+func needsSpaceBefore(tokens []Token, i int) bool {
+	previous, current := tokens[i-1], tokens[i]
+
+	switch current.token_type {
+	case RIGHT_PAREN, RIGHT_BRACKET, COMMA, SEMICOLON, DOT:
+		return false
+	case LEFT_PAREN, LEFT_BRACKET:
+		// No space between a function and its argument list, or an object and its
+		// index. 'this' can be called in a class method, where it's the class.
+		switch previous.token_type {
+		case IDENTIFIER, RIGHT_PAREN, RIGHT_BRACKET, THIS:
+			return false
+		}
+	case RIGHT_BRACE:
+		return previous.token_type != LEFT_BRACE
+	}
+
+	switch previous.token_type {
+	case LEFT_PAREN, LEFT_BRACKET, DOT, BANG:
+		return false
+	case MINUS:
+		return !isUnaryMinus(tokens, i-1)
+	}
+
+	return true
+}
+
+// isUnaryMinus reports whether the '-' at the supplied position negates its operand,
+// rather than being a subtraction
+func isUnaryMinus(tokens []Token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch tokens[i-1].token_type {
+	case IDENTIFIER, NUMBER, STRING, RIGHT_PAREN, RIGHT_BRACKET, TRUE, FALSE, NIL, THIS:
+		return false
+	}
+	return true
+}
+
+// checkSameTokens verifies that the formatted source consists of the same tokens and
+// comments as the original source
+func checkSameTokens(original string, formatted string) error {
+	scan := func(source string) []Token {
+		scanner := NewScanner(NewDiagnosticCollector(), source)
+		scanner.keepComments = true
+		tokens := scanner.scanTokens()
+		return append(tokens, scanner.comments...)
+	}
+
+	before, after := scan(original), scan(formatted)
+	if len(before) != len(after) {
+		return fmt.Errorf("internal error: formatting changed the number of tokens")
+	}
+	for i := range before {
+		if before[i].token_type != after[i].token_type ||
+			strings.TrimRight(before[i].lexeme, " \t\r") != strings.TrimRight(after[i].lexeme, " \t\r") {
+			return fmt.Errorf("internal error: formatting changed token '%s' on line %d", before[i].lexeme, before[i].line)
+		}
+	}
+	return nil
+}
+
+// fmtCommand implements 'glox fmt', which formats the supplied files, or all .lox files
+// in the supplied directories. By default the formatted source is written to stdout.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs, without changing them")
+	write := flags.Bool("write", false, "write formatted source back to the files")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox fmt [--check | --write] path...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *check && *write {
+		fmt.Fprintln(os.Stderr, "glox fmt: --check and --write can't be used together")
+		return 64
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	files, err := collectLoxFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox fmt: %v\n", err)
+		return 66
+	}
+
+	exitCode := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox fmt: %v\n", err)
+			exitCode = 66
+			continue
+		}
+
+		formatted, diagnostics, err := formatSource(string(data))
+		if err != nil {
+			for _, diag := range diagnostics {
+				fmt.Fprintf(os.Stderr, "%s:%v\n", file, diag)
+			}
+			fmt.Fprintf(os.Stderr, "glox fmt: %s: %v\n", file, err)
+			exitCode = 65
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(data, []byte(formatted)) {
+				fmt.Println(file)
+				if exitCode == 0 {
+					exitCode = 1
+				}
+			}
+		case *write:
+			if !bytes.Equal(data, []byte(formatted)) {
+				if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "glox fmt: %v\n", err)
+					exitCode = 74
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return exitCode
+}
+
+// collectLoxFiles expands the supplied paths into a list of files, replacing
+// directories with all the .lox files they contain
+func collectLoxFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(p) == ".lox" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// FORMATTER TESTS
+// ============================================================================
+
+func TestFormatterLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			"Spacing around operators",
+			"var a=1+2*-3;print !a==false;",
+			"var a = 1 + 2 * -3;\nprint !a == false;\n",
+		},
+		{
+			"Getter and setter",
+			"class A{x{return this._x;}set x(v){this._x=v;}}",
+			"class A {\n    x {\n        return this._x;\n    }\n    set x(v) {\n        this._x = v;\n    }\n}\n",
+		},
+		{
+			"Declared fields",
+			"sealed class A{var x=1;var #y;m(){}}",
+			"sealed class A {\n    var x = 1;\n    var #y;\n    m() {}\n}\n",
+		},
+		{
+			"Class methods",
+			"class A{class make(){return this();}m(){}}",
+			"class A {\n    class make() {\n        return this();\n    }\n    m() {}\n}\n",
+		},
+		{
+			"Shebang line",
+			"#!/usr/bin/env glox\nprint 1+2;",
+			"#!/usr/bin/env glox\nprint 1 + 2;\n",
+		},
+		{
+			"Binary minus after call and grouping",
+			"print f()-(a)-1;",
+			"print f() - (a) - 1;\n",
+		},
+		{
+			"Function declaration and calls",
+			"fun add(a,b){return a+b;}print add(1,2);",
+			"fun add(a, b) {\n    return a + b;\n}\nprint add(1, 2);\n",
+		},
+		{
+			"Class with superclass, getter and this",
+			"class B<A{init(x){this.x=x;}area{return super.area*2;}}",
+			"class B < A {\n    init(x) {\n        this.x = x;\n    }\n    area {\n        return super.area * 2;\n    }\n}\n",
+		},
+		{
+			"If/else keeps else on closing brace line",
+			"if(a){print 1;}else if(b){print 2;}else{print 3;}",
+			"if (a) {\n    print 1;\n} else if (b) {\n    print 2;\n} else {\n    print 3;\n}\n",
+		},
+		{
+			"For loop clauses stay on one line",
+			"for(var i=0;i<10;i=i+1)print i;for(;;){}",
+			"for (var i = 0; i < 10; i = i + 1) print i;\nfor (;;) {}\n",
+		},
+		{
+			"Empty class body",
+			"class A{}",
+			"class A {}\n",
+		},
+		{
+			"Blank lines are collapsed and trimmed at block start",
+			"var a = 1;\n\n\n\nvar b = 2;\n{\n\n    print a;\n}\n",
+			"var a = 1;\n\nvar b = 2;\n{\n    print a;\n}\n",
+		},
+		{
+			"Comments are preserved",
+			"// header\nvar a = 1; // trailing\n{\n  // inside\n  print a;\n}\n// footer\n",
+			"// header\nvar a = 1; // trailing\n{\n    // inside\n    print a;\n}\n// footer\n",
+		},
+		{
+			"Comment splitting a statement",
+			"print add(1, // first\n2);",
+			"print add(1, // first\n    2);\n",
+		},
+		{
+			"Literals are printed as written",
+			"print \"a  b\" + \"\";print 1.50;",
+			"print \"a  b\" + \"\";\nprint 1.50;\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			formatted, diagnostics, err := formatSource(test.source)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v %v", err, diagnostics)
+			}
+			if formatted != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", test.expected, formatted)
+			}
+		})
+	}
+}
+
+func TestFormatterLineWrapping(t *testing.T) {
+	t.Run("Long argument list is split one per line", func(t *testing.T) {
+		source := "print someFunction(firstArgumentName, secondArgumentName, thirdArgumentName, fourthArgumentName, fifthArgumentName);"
+		expected := `print someFunction(
+    firstArgumentName,
+    secondArgumentName,
+    thirdArgumentName,
+    fourthArgumentName,
+    fifthArgumentName
+);
+`
+		formatted, _, err := formatSource(source)
+		assertNoError(t, err, "Format long line")
+		assertEqual(t, expected, formatted, "Long argument list")
+	})
+
+	t.Run("Short nested call is kept together", func(t *testing.T) {
+		source := "{ print someFunction(firstArgumentName, secondArgumentName, thirdArgumentName, fourthArgumentName, inner(a, b)); }"
+		expected := `{
+    print someFunction(
+        firstArgumentName,
+        secondArgumentName,
+        thirdArgumentName,
+        fourthArgumentName,
+        inner(a, b)
+    );
+}
+`
+		formatted, _, err := formatSource(source)
+		assertNoError(t, err, "Format nested call")
+		assertEqual(t, expected, formatted, "Nested call")
+	})
+
+	t.Run("Long line without argument list is left alone", func(t *testing.T) {
+		source := "var x = " + strings.Repeat("a + ", 30) + "a;"
+		formatted, _, err := formatSource(source)
+		assertNoError(t, err, "Format long expression")
+		assertEqual(t, 1, strings.Count(formatted, "\n"), "Line count")
+	})
+}
+
+func TestFormatterProperties(t *testing.T) {
+	t.Run("Formatting is idempotent", func(t *testing.T) {
+		source := `
+// comment
+class Foo<Bar{init(a,b){this.a=a;this.b=b;} // trailing
+sum{return this.a+this.b;}}
+
+
+var f=Foo(1,2);if(f.sum>2)print f.sum;else print -f.sum;
+for(var i=0;i<3;i=i+1){print callSomething(i, "a long string argument that is here", "another long string", i * 2);}
+`
+		first, _, err := formatSource(source)
+		assertNoError(t, err, "First format")
+		second, _, err := formatSource(first)
+		assertNoError(t, err, "Second format")
+		assertEqual(t, first, second, "Idempotent formatting")
+	})
+
+	t.Run("Formatting keeps code covered by lint directives", func(t *testing.T) {
+		source := "// lox:ignore unused-local\n{var z = 1;}\nprint \"ok\";\n"
+		formatted, _, err := formatSource(source)
+		assertNoError(t, err, "Format")
+
+		config := &ProjectConfig{lint: NewLintConfig()}
+		assertNoError(t, config.lint.setSeverity("unused-local", "error"), "Set severity")
+		assertEqual(t, 0, len(checkSource(source, config)), "Diagnostics before formatting")
+		assertEqual(t, 0, len(checkSource(formatted, config)), "Diagnostics after formatting")
+	})
+
+	t.Run("Syntax errors are reported and nothing is formatted", func(t *testing.T) {
+		_, diagnostics, err := formatSource("var a = ;")
+		assertError(t, err, "Syntax error")
+		if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].String(), "Expected expression") {
+			t.Errorf("Expected a single parse error, got %v", diagnostics)
+		}
+	})
+
+	t.Run("Scanner retains comments only when asked", func(t *testing.T) {
+		scanner := NewScanner(NewTestGLox(), "// one\nvar a; // two")
+		tokens := scanner.scanTokens()
+		assertEqual(t, 0, len(scanner.comments), "Comments discarded by default")
+		assertEqual(t, 4, len(tokens), "Token count")
+
+		scanner = NewScanner(NewTestGLox(), "// one\nvar a; // two")
+		scanner.keepComments = true
+		tokens = scanner.scanTokens()
+		assertEqual(t, 4, len(tokens), "Comments not in token stream")
+		compareTokens(t, []Token{
+			createToken(COMMENT, "// one", nil, 1),
+			createToken(COMMENT, "// two", nil, 2),
+		}, scanner.comments, "Retained comments")
+	})
+}
+
+func TestFmtCommand(t *testing.T) {
+	unformatted := "var a=1;\n"
+	formatted := "var a = 1;\n"
+
+	t.Run("Check reports unformatted files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "bad.lox"), unformatted)
+		writeTestFile(t, filepath.Join(dir, "good.lox"), formatted)
+		writeTestFile(t, filepath.Join(dir, "notes.txt"), unformatted)
+
+		stdout, code := captureCommandOutput(t, func() int { return fmtCommand([]string{"--check", dir}) })
+		assertEqual(t, 1, code, "Exit code")
+		assertEqual(t, filepath.Join(dir, "bad.lox")+"\n", stdout, "Listed files")
+	})
+
+	t.Run("Write rewrites files in place", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "bad.lox")
+		writeTestFile(t, path, unformatted)
+
+		_, code := captureCommandOutput(t, func() int { return fmtCommand([]string{"--write", path}) })
+		assertEqual(t, 0, code, "Exit code")
+		data, _ := os.ReadFile(path)
+		assertEqual(t, formatted, string(data), "Rewritten file")
+	})
+
+	t.Run("Default mode prints formatted source", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.lox")
+		writeTestFile(t, path, unformatted)
+
+		stdout, code := captureCommandOutput(t, func() int { return fmtCommand([]string{path}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, formatted, stdout, "Printed source")
+	})
+}
+
+// captureCommandOutput runs a command, returning what it wrote to stdout along with
+// its exit code. Anything written to stderr is discarded.
+func captureCommandOutput(t *testing.T, command func() int) (string, int) {
+	t.Helper()
+
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdout = w
+	os.Stderr = devNull
+
+	code := command()
+
+	w.Close()
+	devNull.Close()
+	os.Stdout, os.Stderr = originalStdout, originalStderr
+
+	var sb strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		sb.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	return sb.String(), code
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 
@@ -13,50 +16,326 @@ type GLox struct {
 	hadError bool
 	hadRuntimeError bool 
 	interpreter *Interpreter 
+	config *ProjectConfig // settings from the project config file, if any
+	// If stmtLines is set, the lines of all statements that are run are recorded in
+	// it, for use by execution hooks
+	stmtLines map[Stmt]int
 }
 
 func main() {
-	
-	lox := GLox {}
-	lox.interpreter = NewInterpreter(&lox)
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: glox [script]")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "repl":
+			os.Exit(replCommand(os.Args[2:]))
+		case "check":
+			os.Exit(checkCommand(os.Args[2:]))
+		case "test":
+			os.Exit(testCommand(os.Args[2:]))
+		case "conformance":
+			os.Exit(conformanceCommand(os.Args[2:]))
+		case "fmt":
+			os.Exit(fmtCommand(os.Args[2:]))
+		case "ast":
+			os.Exit(astCommand(os.Args[2:]))
+		case "lsp":
+			os.Exit(lspCommand(os.Args[2:]))
+		case "dap":
+			os.Exit(dapCommand(os.Args[2:]))
+		case "debug":
+			os.Exit(debugCommand(os.Args[2:]))
+		case "tokens":
+			os.Exit(tokensCommand(os.Args[2:]))
+		case "help", "-h", "-help", "--help":
+			printUsage(os.Stdout)
+			os.Exit(0)
+		}
+	}
+
+	// Without a subcommand, glox runs the REPL, or runs a script as 'glox run' does
+	if len(os.Args) == 1 {
+		os.Exit(replCommand(nil))
+	}
+	if isUnknownCommand(os.Args[1]) {
+		fmt.Fprintf(os.Stderr, "glox: unknown command '%s'\n", os.Args[1])
+		printUsage(os.Stderr)
 		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		lox.runFile(os.Args[1])
-	} else {
-		lox.runPrompt()
 	}
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: glox [file.lox | - | -e source] [arg...]")
+	fmt.Fprintln(w, "       glox run [--trace] [--trace-file=path] [--trace-func=names] [--trace-lines=from-to]")
+	fmt.Fprintln(w, "                [--profile=path] [--profile-format=text|folded|pprof]")
+	fmt.Fprintln(w, "                [--coverprofile=path] [--coverreport=path] (file.lox | - | -e source) [arg...]")
+	fmt.Fprintln(w, "       glox repl")
+	fmt.Fprintln(w, "       glox check path...")
+	fmt.Fprintln(w, "       glox test [-run regexp] [-parallel n] [-v] [-cover] [-coverprofile=path] [path...]")
+	fmt.Fprintln(w, "       glox conformance [-v] path...")
+	fmt.Fprintln(w, "       glox fmt [--check | --write] path...")
+	fmt.Fprintln(w, "       glox ast [--format=sexpr|json] [--depths] file.lox")
+	fmt.Fprintln(w, "       glox tokens [--json] [--comments] file.lox")
+	fmt.Fprintln(w, "       glox lsp")
+	fmt.Fprintln(w, "       glox dap")
+	fmt.Fprintln(w, "       glox debug file.lox")
+}
+
+// isUnknownCommand reports whether the first argument looks like a mistyped
+// subcommand, rather than a script or a flag: a plain word that isn't a file
+func isUnknownCommand(arg string) bool {
+	if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "./\\") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err != nil
 }
 
-func (l *GLox) runFile(file string) {
-	if data, err := os.ReadFile(file); err != nil {
-		log.Fatal(err)
+// readScript reads a script from a file, or from stdin if the path is "-"
+func readScript(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
 	} else {
-		l.run(string(data), false)
+		data, err = os.ReadFile(path)
 	}
+	return string(data), err
+}
+
+// runScript runs a script, using the project config from the supplied directory, and
+// returns the process exit code
+func (l *GLox) runScript(dir string, source string) int {
+	l.loadConfig(dir)
+	l.run(source, false)
 
 	if l.hadError {
-		os.Exit(65)
+		return 65
 	}
 	if l.hadRuntimeError {
-		os.Exit(70);
+		return 70
 	}
+	return 0
 }
 
-func (l *GLox) runPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-        fmt.Print("> ")
-        if scanner.Scan() {
-            line := scanner.Text()
-			l.run(line, true)
-			l.hadError = false; // reset error state
-        } else {
-            break
-        }
-    }
+// runCommand implements 'glox run', which runs a script with optional tracing,
+// profiling and coverage. Any arguments after the script are passed to it.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	eval := flags.String("e", "", "run the supplied source rather than a file")
+	trace := flags.Bool("trace", false, "log executed statements, calls and assignments")
+	traceFile := flags.String("trace-file", "", "write the trace to a file instead of stderr")
+	traceFunctions := flags.String("trace-func", "", "only trace calls to these (comma-separated) functions")
+	traceLines := flags.String("trace-lines", "", "only trace events on these lines eg 10-20")
+	profileFile := flags.String("profile", "", "write a profile of function calls and line hits to a file")
+	profileFormat := flags.String("profile-format", "", "profile format: text, folded (for flame graphs) or pprof (default based on the file extension)")
+	coverProfile := flags.String("coverprofile", "", "write statement, branch and function coverage to a file in LCOV format")
+	coverReport := flags.String("coverreport", "", "write the source annotated with coverage to a file")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox run [--trace] [--trace-file=path] [--trace-func=names] [--trace-lines=from-to] [--profile=path] [--profile-format=text|folded|pprof] [--coverprofile=path] [--coverreport=path] (file.lox | - | -e source) [arg...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	evaluating := false
+	flags.Visit(func(f *flag.Flag) { evaluating = evaluating || f.Name == "e" })
+	if !evaluating && flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	// path is how the script is named in traces, profiles and coverage, and dir is
+	// where its project config is looked for
+	path, dir, source := "<eval>", ".", *eval
+	scriptArgs := flags.Args()
+	if !evaluating {
+		path, scriptArgs = flags.Arg(0), flags.Args()[1:]
+		var err error
+		if source, err = readScript(path); err != nil {
+			fmt.Fprintf(os.Stderr, "glox run: %v\n", err)
+			return 66
+		}
+		if path == "-" {
+			path = "<stdin>"
+		} else {
+			dir = filepath.Dir(path)
+		}
+	}
 
+	format := *profileFormat
+	if format == "" {
+		format = profileFormatFor(*profileFile)
+	}
+	if format != "text" && format != "folded" && format != "pprof" {
+		fmt.Fprintf(os.Stderr, "glox run: unknown profile format '%s'\n", format)
+		return 64
+	}
+
+	lox := &GLox{}
+	lox.interpreter = NewInterpreter(lox)
+	lox.interpreter.scriptArgs = scriptArgs
+	tracing := *trace || *traceFile != "" || *traceFunctions != "" || *traceLines != ""
+	covering := *coverProfile != "" || *coverReport != ""
+	if !tracing && *profileFile == "" && !covering {
+		return lox.runScript(dir, source)
+	}
+
+	lox.stmtLines = make(map[Stmt]int)
+	var hooks hookChain
+
+	if tracing {
+		var out io.Writer = os.Stderr
+		if *traceFile != "" {
+			file, err := os.Create(*traceFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "glox run: %v\n", err)
+				return 73
+			}
+			defer file.Close()
+			out = file
+		}
+		traceOut := bufio.NewWriter(out)
+		defer traceOut.Flush()
+
+		tracer := NewTracer(lox.interpreter, traceOut, filepath.Base(path), source, lox.stmtLines)
+		tracer.setFunctionFilter(*traceFunctions)
+		if *traceLines != "" {
+			if err := tracer.setLineFilter(*traceLines); err != nil {
+				fmt.Fprintf(os.Stderr, "glox run: %v\n", err)
+				return 64
+			}
+		}
+		hooks = append(hooks, tracer)
+	}
+
+	var profiler *Profiler
+	if *profileFile != "" {
+		profiler = NewProfiler(filepath.Base(path), source, lox.stmtLines)
+		hooks = append(hooks, profiler)
+		profiler.begin()
+	}
+
+	var coverage *Coverage
+	if covering {
+		coverage = NewCoverage()
+		hooks = append(hooks, coverage.addFile(path, source, lox.stmtLines))
+	}
+
+	if len(hooks) == 1 {
+		lox.interpreter.hook = hooks[0]
+	} else {
+		lox.interpreter.hook = hooks
+	}
+	code := lox.runScript(dir, source)
+
+	if profiler != nil {
+		profiler.finish()
+		if err := writeProfile(profiler, *profileFile, format); err != nil {
+			fmt.Fprintf(os.Stderr, "glox run: %v\n", err)
+			return 73
+		}
+	}
+	if coverage != nil {
+		if err := writeCoverage(coverage, *coverProfile, *coverReport); err != nil {
+			fmt.Fprintf(os.Stderr, "glox run: %v\n", err)
+			return 73
+		}
+		fmt.Fprintf(os.Stderr, "coverage: %s\n", coverage.summary())
+	}
+	return code
+}
+
+// writeCoverage writes an LCOV file and/or an annotated source report of the
+// coverage recorded for some scripts
+func writeCoverage(coverage *Coverage, lcovPath string, reportPath string) error {
+	write := func(path string, writer func(io.Writer) error) error {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out := bufio.NewWriter(file)
+		err = writer(out)
+		if err == nil {
+			err = out.Flush()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	if lcovPath != "" {
+		if err := write(lcovPath, coverage.writeLCOV); err != nil {
+			return err
+		}
+	}
+	if reportPath != "" {
+		return write(reportPath, coverage.writeReport)
+	}
+	return nil
+}
+
+// profileFormatFor returns the default format for a profile written to the supplied
+// file: pprof for .pprof and .pb.gz files, folded stacks for .folded files, and text
+// otherwise
+func profileFormatFor(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".pprof") || strings.HasSuffix(path, ".pb.gz"):
+		return "pprof"
+	case strings.HasSuffix(path, ".folded"):
+		return "folded"
+	}
+	return "text"
+}
+
+func writeProfile(profiler *Profiler, path string, format string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	switch format {
+	case "folded":
+		err = profiler.writeFolded(out)
+	case "pprof":
+		err = profiler.writePprof(out)
+	default:
+		err = profiler.writeText(out)
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// replCommand implements 'glox repl', the interactive prompt
+func replCommand(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox repl")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 64
+	}
+
+	lox := &GLox{}
+	lox.interpreter = NewInterpreter(lox)
+	lox.loadConfig(".")
+	repl := NewRepl(lox, os.Stdin, os.Stdout)
+	if isTerminal(os.Stdin.Fd()) {
+		repl.useTerminal(os.Stdin)
+	}
+	repl.run()
+	return 0
 }
 
 func (l *GLox) run(source string, in_repl bool) {
@@ -70,9 +349,20 @@ func (l *GLox) run(source string, in_repl bool) {
 	if l.hadError { // bail out if parsing failed 
 		return 
 	}
+	if l.stmtLines != nil {
+		for stmt, line := range parser.stmtLines {
+			l.stmtLines[stmt] = line
+		}
+	}
 
 	// Do some static analysis to resolve variables to the right scopes/closures
+	// and report any lint rule violations
+	var lintConfig *LintConfig
+	if l.config != nil {
+		lintConfig = l.config.lint
+	}
 	resolver := NewResolver(l, l.interpreter)
+	resolver.linter = NewLinter(l, lintConfig, scanner.lintDirectives, parser)
 	resolver.resolveStmts(statements)
 	if l.hadError {
 		return 
@@ -85,11 +375,22 @@ func (l *GLox) run(source string, in_repl bool) {
 	// entered 
 	if in_repl && len(results) > 0 {
 		for _, result := range(results) {
-			fmt.Printf("%v\n", result)
+			fmt.Fprintf(l.interpreter.output(), "%v\n", result)
 		}
 	}
 }
 
+// loadConfig reads the project config file that applies to scripts in the
+// supplied directory
+func (l *GLox) loadConfig(dir string) {
+	config, err := loadProjectConfig(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
+		os.Exit(64)
+	}
+	l.config = config
+}
+
 func (l *GLox) error(line int, message string) {
 	l.report(line, "", message)
 }
@@ -108,6 +409,14 @@ func (l *GLox) runtimeError(err error) {
 	l.hadRuntimeError = true 
 }
 
+func (l *GLox) warning(token Token, message string) {
+	where := " at end"
+	if token.token_type != EOF {
+		where = fmt.Sprintf(" at %s ", token.lexeme)
+	}
+	fmt.Fprint(os.Stderr, "[line " + strconv.Itoa(token.line) + "] Warning" + where + ": " + message + "\n")
+}
+
 func (l *GLox) report(line int, where string, message string) {
 	fmt.Fprint(os.Stderr, "[line " + strconv.Itoa(line) + "] Error" + where + ": " + message + "\n")
 	l.hadError = true 
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// COMMAND-LINE TESTS
+// ============================================================================
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	program := "var a = args();\nprint a.length;\nfor (var i = 0; i < a.length; i = i + 1) print a.get(i);\nprint a.get(a.length) == nil;\n"
+
+	t.Run("Script arguments", func(t *testing.T) {
+		path := filepath.Join(dir, "args.lox")
+		writeTestFile(t, path, program)
+		stdout, code := captureCommandOutput(t, func() int { return runCommand([]string{path, "one", "--two"}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, "2\none\n--two\ntrue\n", stdout, "Output")
+	})
+
+	t.Run("No arguments", func(t *testing.T) {
+		path := filepath.Join(dir, "args.lox")
+		writeTestFile(t, path, program)
+		stdout, _ := captureCommandOutput(t, func() int { return runCommand([]string{path}) })
+		assertEqual(t, "0\ntrue\n", stdout, "Output")
+	})
+
+	t.Run("Evaluate source", func(t *testing.T) {
+		stdout, code := captureCommandOutput(t, func() int { return runCommand([]string{"-e", "print 1 + 2;"}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, "3\n", stdout, "Output")
+
+		stdout, _ = captureCommandOutput(t, func() int { return runCommand([]string{"-e", program, "x"}) })
+		assertEqual(t, "1\nx\ntrue\n", stdout, "Output with arguments")
+
+		_, code = captureCommandOutput(t, func() int { return runCommand([]string{"-e", "print -nil;"}) })
+		assertEqual(t, 70, code, "Runtime error")
+		_, code = captureCommandOutput(t, func() int { return runCommand([]string{"-e", "print ;"}) })
+		assertEqual(t, 65, code, "Syntax error")
+	})
+
+	t.Run("Read from stdin", func(t *testing.T) {
+		path := filepath.Join(dir, "stdin.lox")
+		writeTestFile(t, path, "#!/usr/bin/env glox\n"+program)
+		stdin, err := os.Open(path)
+		assertNoError(t, err, "Open script")
+		defer stdin.Close()
+		originalStdin := os.Stdin
+		os.Stdin = stdin
+		defer func() { os.Stdin = originalStdin }()
+
+		stdout, code := captureCommandOutput(t, func() int { return runCommand([]string{"-", "a"}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, "1\na\ntrue\n", stdout, "Output")
+	})
+
+	t.Run("Usage errors", func(t *testing.T) {
+		_, code := captureCommandOutput(t, func() int { return runCommand(nil) })
+		assertEqual(t, 64, code, "No script")
+		_, code = captureCommandOutput(t, func() int { return runCommand([]string{filepath.Join(dir, "missing.lox")}) })
+		assertEqual(t, 66, code, "Missing script")
+	})
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.lox")
+	writeTestFile(t, good, "#!/usr/bin/env glox\nfun f(unused) { return 1; }\nprint f(1);\n")
+	bad := filepath.Join(dir, "bad.lox")
+	writeTestFile(t, bad, "print 1;\nprint ;\n")
+
+	var out strings.Builder
+	assertEqual(t, 0, checkFiles(&out, []string{good}), "Exit code with warnings")
+	assertContains(t, out.String(), good+":[line 2] Warning")
+
+	out.Reset()
+	assertEqual(t, 65, checkFiles(&out, []string{good, bad}), "Exit code with errors")
+	assertContains(t, out.String(), bad+":[line 2] Error")
+
+	_, code := captureCommandOutput(t, func() int { return checkCommand(nil) })
+	assertEqual(t, 64, code, "No paths")
+}
+
+func TestIsUnknownCommand(t *testing.T) {
+	assertTruthy(t, isUnknownCommand("chek"), "chek")
+	assertFalsy(t, isUnknownCommand("script.lox"), "script.lox")
+	assertFalsy(t, isUnknownCommand("-e"), "-e")
+	assertFalsy(t, isUnknownCommand("-"), "-")
+	assertFalsy(t, isUnknownCommand("glox_test.go"), "Existing file")
+}
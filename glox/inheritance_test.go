@@ -205,3 +205,191 @@ f();
 		runProgramAndExpectError(t, program, "Can't use 'super' outside a class", "Can't use super in a top-level function")
 	})
 }
+
+func TestInner(t *testing.T) {
+	t.Run("Base class method calls subclass refinement", func(t *testing.T) {
+		program := `
+class Page {
+    render() {
+        print "<html>";
+        inner();
+        print "</html>";
+    }
+}
+class Article < Page {
+    render() {
+        print "article";
+    }
+}
+Article().render();
+`
+		expected := []string{"<html>", "article", "</html>"}
+		runProgramAndCheckOutput(t, program, expected, "Base class method calls subclass refinement")
+	})
+
+	t.Run("Inner through several levels", func(t *testing.T) {
+		program := `
+class A {
+    m() {
+        print "A before";
+        inner();
+        print "A after";
+    }
+}
+class B < A {
+    m() {
+        print "B before";
+        inner();
+        print "B after";
+    }
+}
+class C < B {
+    m() {
+        print "C";
+    }
+}
+C().m();
+`
+		expected := []string{"A before", "B before", "C", "B after", "A after"}
+		runProgramAndCheckOutput(t, program, expected, "Inner through several levels")
+	})
+
+	t.Run("Inner is nil without a refinement", func(t *testing.T) {
+		program := `
+class Base {
+    value() {
+        return inner();
+    }
+}
+print Base().value();
+`
+		expected := []string{"<nil>"}
+		runProgramAndCheckOutput(t, program, expected, "Inner is nil without a refinement")
+	})
+
+	t.Run("Inner passes arguments and returns the refinement's value", func(t *testing.T) {
+		program := `
+class Greeter {
+    greet(name) {
+        return "Hello, " + inner(name + "!");
+    }
+}
+class Loud < Greeter {
+    greet(text) {
+        return text + text;
+    }
+}
+print Loud().greet("Bob");
+`
+		expected := []string{"Hello, Bob!Bob!"}
+		runProgramAndCheckOutput(t, program, expected, "Inner passes arguments and returns the refinement's value")
+	})
+
+	t.Run("Refinement skips classes without the method", func(t *testing.T) {
+		program := `
+class A {
+    m() {
+        print "A";
+        inner();
+    }
+}
+class B < A {}
+class C < B {
+    m() {
+        print "C";
+    }
+}
+C().m();
+`
+		expected := []string{"A", "C"}
+		runProgramAndCheckOutput(t, program, expected, "Refinement skips classes without the method")
+	})
+
+	t.Run("Methods without inner are still overridden", func(t *testing.T) {
+		program := `
+class A {
+    m() {
+        print "A";
+    }
+}
+class B < A {
+    m() {
+        print "B";
+        super.m();
+    }
+}
+B().m();
+`
+		expected := []string{"B", "A"}
+		runProgramAndCheckOutput(t, program, expected, "Methods without inner are still overridden")
+	})
+
+	t.Run("Inner in an initializer", func(t *testing.T) {
+		program := `
+class Base {
+    init() {
+        this.steps = "base";
+        inner();
+    }
+}
+class Derived < Base {
+    init() {
+        this.steps = this.steps + ",derived";
+    }
+}
+print Derived().steps;
+`
+		expected := []string{"base,derived"}
+		runProgramAndCheckOutput(t, program, expected, "Inner in an initializer")
+	})
+
+	t.Run("Inner can be used as a name outside classes", func(t *testing.T) {
+		program := `
+fun inner(x) {
+    return x * 2;
+}
+print inner(21);
+`
+		expected := []string{"42"}
+		runProgramAndCheckOutput(t, program, expected, "Inner can be used as a name outside classes")
+	})
+}
+
+func TestInnerErrors(t *testing.T) {
+	t.Run("Inner in a function nested in a method", func(t *testing.T) {
+		program := `
+class A {
+    m() {
+        fun helper() {
+            inner();
+        }
+    }
+}
+`
+		runProgramAndExpectError(t, program, "Can't use 'inner' outside of a method.", "Inner in a function nested in a method")
+	})
+
+	t.Run("Inner in a field initializer", func(t *testing.T) {
+		program := `
+class A {
+    var x = inner();
+}
+`
+		runProgramAndExpectError(t, program, "Can't use 'inner' outside of a method.", "Inner in a field initializer")
+	})
+
+	t.Run("Inner with the wrong number of arguments", func(t *testing.T) {
+		program := `
+class A {
+    m() {
+        inner();
+    }
+}
+class B < A {
+    m(x) {}
+}
+B().m();
+`
+		runProgramAndExpectError(t, program, "Expected 1 arguments but got 0", "Inner with the wrong number of arguments")
+	})
+}
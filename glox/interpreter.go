@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 )
 
@@ -13,16 +15,127 @@ type Interpreter struct {
 	// locals holds the distance from the currently-active environment to 
 	// the environment in which to look up a given Expr
 	locals  map[Expr]int 
+	// privateAccess holds the class body that each access to a private member is in
+	privateAccess map[Expr]*ClassStmt
+	// frames is the stack of active function calls, with the top-level script at
+	// the bottom and the innermost call last
+	frames  []*callFrame
+	// hook, if set, is notified before each statement is executed
+	hook    executionHook
+	// stdout, if set, receives the output of print statements instead of os.Stdout
+	stdout  io.Writer
+	// scriptArgs are the command-line arguments passed to the script, returned by args()
+	scriptArgs []string
+}
+
+// callFrame is an active call to a Lox function
+type callFrame struct {
+	name     string // see displayName
+	function *LoxFunction // nil for the top-level script
+	// The following are maintained by the execution hook, if there is one: the
+	// environment, line and statement that the frame most recently started executing
+	env      *Environment
+	line     int
+	stmt     Stmt
+}
+
+// executionHook is implemented by tools, such as the debugger, that need to observe
+// execution statement by statement. Returning an error from beforeStatement aborts
+// execution of the program.
+type executionHook interface {
+	beforeStatement(stmt Stmt) error
+}
+
+// callObserver is implemented by execution hooks that also need to be notified of
+// calls to, and returns from, Lox functions
+type callObserver interface {
+	enteredCall(frame *callFrame, arguments []any)
+	returnedFromCall(frame *callFrame, result any, err error)
+}
+
+// assignmentObserver is implemented by execution hooks that also need to be notified
+// of assignments to variables and fields
+type assignmentObserver interface {
+	assignedVariable(name Token, value any)
+	assignedField(instance *LoxInstance, name Token, value any)
+}
+
+// branchObserver is implemented by execution hooks that also need to be notified of
+// which way each if statement and logical expression went. Branch 0 is the 'then'
+// branch of an if statement, or a logical expression that short-circuited; branch 1
+// is the 'else' branch (even if there isn't one), or evaluating the right operand.
+type branchObserver interface {
+	tookBranch(node any, branch int)
+}
+
+// hookChain is an execution hook that passes every notification on to each of a
+// list of hooks, so that several tools, eg the tracer and the profiler, can observe
+// the same run
+type hookChain []executionHook
+
+func (h hookChain) beforeStatement(stmt Stmt) error {
+	for _, hook := range h {
+		if err := hook.beforeStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h hookChain) enteredCall(frame *callFrame, arguments []any) {
+	for _, hook := range h {
+		if observer, ok := hook.(callObserver); ok {
+			observer.enteredCall(frame, arguments)
+		}
+	}
+}
+
+func (h hookChain) returnedFromCall(frame *callFrame, result any, err error) {
+	for _, hook := range h {
+		if observer, ok := hook.(callObserver); ok {
+			observer.returnedFromCall(frame, result, err)
+		}
+	}
+}
+
+func (h hookChain) tookBranch(node any, branch int) {
+	for _, hook := range h {
+		if observer, ok := hook.(branchObserver); ok {
+			observer.tookBranch(node, branch)
+		}
+	}
+}
+
+func (h hookChain) assignedVariable(name Token, value any) {
+	for _, hook := range h {
+		if observer, ok := hook.(assignmentObserver); ok {
+			observer.assignedVariable(name, value)
+		}
+	}
+}
+
+func (h hookChain) assignedField(instance *LoxInstance, name Token, value any) {
+	for _, hook := range h {
+		if observer, ok := hook.(assignmentObserver); ok {
+			observer.assignedField(instance, name, value)
+		}
+	}
 }
 
 func NewInterpreter(lox LoxRuntime) *Interpreter {
 	globals := NewEnvironment(nil)
 	globals.defineVarValue("clock", clockFn{})
+	globals.defineVarValue("args", argsFn{})
+	for _, fn := range reflectionFns {
+		globals.defineVarValue(fn.name, fn)
+	}
 	return &Interpreter{
 		lox:     lox,
 		globalEnv: globals,
 		currentEnv:     globals,
 		locals:  make(map[Expr]int),
+		privateAccess: make(map[Expr]*ClassStmt),
+		frames:  []*callFrame{{name: "<script>", env: globals}},
 	}
 }
 
@@ -32,6 +145,10 @@ func (i *Interpreter) interpret(statements []Stmt) []any {
 		// Collect the results of evaluating any top-level statements that are
 		// expressions, used for REPL mode
 		if expr_stmt, ok := stmt.(*ExpressionStmt); ok {
+			if err := i.notifyHook(stmt); err != nil {
+				i.lox.runtimeError(err)
+				return nil
+			}
 			if value, err := i.evaluate(expr_stmt.expression); err == nil {
 				results = append(results, value)
 			} else {
@@ -50,9 +167,66 @@ func (i *Interpreter) interpret(statements []Stmt) []any {
 }
 
 func (i *Interpreter) execute(stmt Stmt) error {
+	if err := i.notifyHook(stmt); err != nil {
+		return err
+	}
 	return stmt.Accept(i)
 }
 
+func (i *Interpreter) notifyHook(stmt Stmt) error {
+	if i.hook != nil {
+		return i.hook.beforeStatement(stmt)
+	}
+	return nil
+}
+
+func (i *Interpreter) notifyBranch(node any, branch int) {
+	if observer, ok := i.hook.(branchObserver); ok {
+		observer.tookBranch(node, branch)
+	}
+}
+
+func (i *Interpreter) pushFrame(function *LoxFunction, arguments []any) {
+	frame := &callFrame{function: function, env: i.currentEnv}
+	i.frames = append(i.frames, frame)
+	if observer, ok := i.hook.(callObserver); ok {
+		observer.enteredCall(frame, arguments)
+	}
+}
+
+// displayName is how the frame is named in stack traces and reports, eg
+// "Point.move". It's only worked out when it's first needed, as most calls are
+// never reported.
+func (f *callFrame) displayName() string {
+	if f.name == "" {
+		f.name = methodName(f.function.declaration)
+		switch this := f.function.closure.values["this"].(type) {
+		case *LoxInstance:
+			f.name = this.class.name + "." + f.name
+		case *LoxClass:
+			f.name = this.name + "." + f.name
+		}
+	}
+	return f.name
+}
+
+// methodName is how a function or method is named in stack traces and reports. A
+// setter is named after its property with '=' appended, eg "name=", to tell it
+// apart from a getter for the same property.
+func methodName(declaration *FunctionStmt) string {
+	if declaration.isSetter {
+		return declaration.functionName.lexeme + "="
+	}
+	return declaration.functionName.lexeme
+}
+
+func (i *Interpreter) popFrame(result any, err error) {
+	if observer, ok := i.hook.(callObserver); ok {
+		observer.returnedFromCall(i.frames[len(i.frames)-1], result, err)
+	}
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
 func (i *Interpreter) evaluate(e Expr) (any, error) {
 	return e.Accept(i)
 }
@@ -95,10 +269,19 @@ func (i *Interpreter) VisitPrintStmt(stmt *PrintStmt) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%v\n", value) // Print statement outputs result of evaluating expression
+	// Print statement outputs result of evaluating expression
+	fmt.Fprintf(i.output(), "%v\n", value)
 	return nil
 }
 
+// output returns the writer that program output goes to
+func (i *Interpreter) output() io.Writer {
+	if i.stdout != nil {
+		return i.stdout
+	}
+	return os.Stdout
+}
+
 // Execute return statement
 func (i *Interpreter) VisitReturnStmt(stmt *ReturnStmt) error {
 	var value any
@@ -128,8 +311,11 @@ func (i *Interpreter) VisitIfStmt(stmt *IfStmt) error {
 		return err
 	}
 	if isTruthy(condition) {
+		i.notifyBranch(stmt, 0)
 		return i.execute(stmt.thenBranch)
-	} else if stmt.elseBranch != nil {
+	}
+	i.notifyBranch(stmt, 1)
+	if stmt.elseBranch != nil {
 		return i.execute(stmt.elseBranch)
 	}
 
@@ -156,6 +342,22 @@ func (i *Interpreter) VisitWhileStmt(stmt *WhileStmt) error {
 	return nil
 }
 
+// classMethods creates the functions for a class's method declarations, returning
+// the setters separately from the other methods
+func (i *Interpreter) classMethods(declarations []*FunctionStmt) (map[string]*LoxFunction, map[string]*LoxFunction) {
+	methods := make(map[string]*LoxFunction)
+	setters := make(map[string]*LoxFunction)
+	for _, method := range declarations {
+		name := method.functionName.lexeme
+		if method.isSetter {
+			setters[name] = &LoxFunction{method, i.currentEnv, false}
+		} else {
+			methods[name] = &LoxFunction{method, i.currentEnv, name == "init"}
+		}
+	}
+	return methods, setters
+}
+
 func (i *Interpreter) VisitClassStmt(stmt *ClassStmt) error {
 	// Build object representing superclass, if any 
 	var superclass *LoxClass 
@@ -176,26 +378,44 @@ func (i *Interpreter) VisitClassStmt(stmt *ClassStmt) error {
 
 	// If class has a superclass, create a child environment containing a reference to 'super', 
 	// so that class methods can access it, and define methods in that environment
+	enclosing := i.currentEnv
 	if stmt.superclass != nil {
 		i.currentEnv = NewEnvironment(i.currentEnv)
 		i.currentEnv.defineVarValue("super", superclass)
 	}
 
-	methods := make(map[string]*LoxFunction)
-	for _, method := range stmt.methods {
-		function := &LoxFunction{method, i.currentEnv, method.functionName.lexeme == "init"}
-		methods[method.functionName.lexeme] = function
+	methods, setters := i.classMethods(stmt.methods)
+	traits, err := i.composeTraits(stmt, superclass, methods, setters)
+	if err != nil {
+		i.currentEnv = enclosing
+		return err
 	}
 
+	// Class methods live in the class's metaclass, which inherits from the
+	// superclass's metaclass so that class methods are inherited too
+	classMethods, classSetters := i.classMethods(stmt.classMethods)
+	var superMetaclass *LoxClass
+	if superclass != nil {
+		superMetaclass = superclass.metaclass
+	}
+	metaclass := NewLoxClass(stmt.className.lexeme+" metaclass", superMetaclass, classMethods)
+	metaclass.setters = classSetters
+
+	// Field initializers are evaluated in the same environment as the methods
+	fieldsEnv := i.currentEnv
+
 	// 'super' should no longer be in scope after method definitions, so go back to 
 	// previous environment 
-	if stmt.superclass != nil {
-		i.currentEnv = i.currentEnv.enclosing
-	}
+	i.currentEnv = enclosing
 
 	// All components of runtime representation of the class are now filled-in, so create it and 
 	// update the value assigned to the class name to point to it 
 	class := NewLoxClass(stmt.className.lexeme, superclass, methods)
+	class.metaclass = metaclass
+	class.setters = setters
+	class.declaration = stmt
+	class.fieldsEnv = fieldsEnv
+	class.traits = traits
 	if err := i.currentEnv.assignVarValue(stmt.className, class); err != nil {
 		return err
 	}
@@ -203,6 +423,58 @@ func (i *Interpreter) VisitClassStmt(stmt *ClassStmt) error {
 	return nil
 }
 
+// composeTraits adds the methods of the traits a class uses to the class's own
+// methods. A method the class declares itself takes precedence over a trait's
+// method, and two traits can only provide the same method if the class declares it.
+func (i *Interpreter) composeTraits(stmt *ClassStmt, superclass *LoxClass, methods, setters map[string]*LoxFunction) ([]*LoxTrait, error) {
+	declared := make(map[string]bool)
+	for _, method := range stmt.methods {
+		declared[methodKey(method)] = true
+	}
+
+	traits := make([]*LoxTrait, 0, len(stmt.traits))
+	providedBy := make(map[string]*LoxTrait)
+	for _, traitExpr := range stmt.traits {
+		value, err := i.evaluate(traitExpr)
+		if err != nil {
+			return nil, err
+		}
+		trait, ok := value.(*LoxTrait)
+		if !ok {
+			return nil, RuntimeError{traitExpr.variable, "Not a trait."}
+		}
+		traits = append(traits, trait)
+
+		traitMethods, traitSetters := trait.composeInto(superclass)
+		for _, functions := range []map[string]*LoxFunction{traitMethods, traitSetters} {
+			for name, function := range functions {
+				key := methodKey(function.declaration)
+				if declared[key] {
+					continue
+				}
+				if other, ok := providedBy[key]; ok {
+					return nil, RuntimeError{traitExpr.variable, fmt.Sprintf(
+						"Method '%s' is provided by traits %s and %s, so class %s must declare it.",
+						methodName(function.declaration), other.name, trait.name, stmt.className.lexeme)}
+				}
+				providedBy[key] = trait
+				if function.declaration.isSetter {
+					setters[name] = function
+				} else {
+					methods[name] = function
+				}
+			}
+		}
+	}
+	return traits, nil
+}
+
+func (i *Interpreter) VisitTraitStmt(stmt *TraitStmt) error {
+	methods, setters := i.classMethods(stmt.methods)
+	i.currentEnv.defineVarValue(stmt.traitName.lexeme, &LoxTrait{stmt.traitName.lexeme, methods, setters})
+	return nil
+}
+
 // Execute statements within a block ie { ... }
 func (i *Interpreter) VisitBlockStmt(stmt *BlockStmt) error {
 	// When interpreting a block, create a new environment to handle
@@ -235,13 +507,14 @@ func (i *Interpreter) VisitAssignExpr(expr *AssignExpr) (any, error) {
 	// If local variable, assign to the right scope
 	if distance, ok := i.locals[expr]; ok {
 		i.currentEnv.assignAt(distance, expr.variable, value)
-		return value, nil
-	}
-	// Else, it's a variable in the global scope
-	if err = i.globalEnv.assignVarValue(expr.variable, value); err != nil {
+	} else if err = i.globalEnv.assignVarValue(expr.variable, value); err != nil {
+		// Else, it's a variable in the global scope
 		return nil, err
 	}
 
+	if observer, ok := i.hook.(assignmentObserver); ok {
+		observer.assignedVariable(expr.variable, value)
+	}
 	return value, nil // Assignment expressions return the value on the RHS
 }
 
@@ -255,7 +528,20 @@ func (i *Interpreter) VisitBinaryExpr(expr *BinaryExpr) (any, error) {
 		return nil, err
 	}
 
+	// Classes can overload the operators, other than 'is'
+	if expr.Operator.token_type != IS {
+		if instance, ok := left.(*LoxInstance); ok {
+			return i.binaryOperator(expr.Operator, instance, right)
+		}
+		if instance, ok := right.(*LoxInstance); ok {
+			return i.rightOperandOperator(expr.Operator, left, instance)
+		}
+	}
+
 	switch expr.Operator.token_type {
+	case IS:
+		return isInstanceOf(expr.Operator, left, right)
+
 	case BANG_EQUAL:
 		return !isEqual(left, right), nil
 	case EQUAL_EQUAL:
@@ -361,7 +647,11 @@ func (i *Interpreter) VisitCallExpr(e *CallExpr) (any, error) {
 
 	// Make actual call to function, if it is callable
 	var ok bool
-	if callable, ok = callee.(LoxCallable); !ok {
+	if callable, ok = asCallable(callee); !ok {
+		// Instances are callable if their class defines __call
+		if instance, isInstance := callee.(*LoxInstance); isInstance {
+			return nil, RuntimeError{e.Paren, missingOperatorMethod(instance, "__call", "calls")}
+		}
 		return nil, RuntimeError{e.Paren, "Can only call functions and classes."}
 	}
 	if callable.arity() != len(arguments) {
@@ -369,7 +659,11 @@ func (i *Interpreter) VisitCallExpr(e *CallExpr) (any, error) {
 			fmt.Sprintf("Expected %d arguments but got %d", callable.arity(), len(arguments))}
 	}
 
-	return callable.call(i, arguments)
+	result, err := callable.call(i, arguments)
+	if native, ok := err.(nativeError); ok {
+		return nil, RuntimeError{e.Paren, string(native)}
+	}
+	return result, err
 }
 
 // Retrieve instance properties 
@@ -381,19 +675,21 @@ func (i *Interpreter) VisitPropGetExpr(p *PropGetExpr) (any, error) {
 	if obj, err = i.evaluate(p.object); err != nil {
 		return nil, err
 	}
-	var instance *LoxInstance
-	var ok bool
-	if instance, ok = obj.(*LoxInstance); !ok {
-		return nil, RuntimeError{p.propName, "Only instances have properties"}
-	}
-
-	// Try to retrieve the property 
-	var propValue any
-	if propValue, err = instance.get(p.propName); err != nil {
-		return nil, err
+	// Classes are objects too, with class methods and static fields
+	private := isPrivateName(p.propName.lexeme)
+	switch object := obj.(type) {
+	case *LoxInstance:
+		if private {
+			return object.getPrivate(i.privateAccess[p], p.propName)
+		}
+		return object.get(p.propName)
+	case *LoxClass:
+		if private {
+			return object.getPrivate(i, i.privateAccess[p], p.propName)
+		}
+		return object.get(i, p.propName)
 	}
-
-	return propValue, nil
+	return nil, RuntimeError{p.propName, "Only instances have properties"}
 }
 
 // Set instance properties 
@@ -405,9 +701,9 @@ func (i *Interpreter) VisitPropSetExpr(p *PropSetExpr) (any, error) {
 	if obj, err = i.evaluate(p.object); err != nil {
 		return nil, err
 	}
-	var instance *LoxInstance
-	var ok bool
-	if instance, ok = obj.(*LoxInstance); !ok {
+	instance, isInstance := obj.(*LoxInstance)
+	class, isClass := obj.(*LoxClass)
+	if !isInstance && !isClass {
 		return nil, RuntimeError{p.propName, "Only instances have fields"}
 	}
 
@@ -421,12 +717,58 @@ func (i *Interpreter) VisitPropSetExpr(p *PropSetExpr) (any, error) {
 		return nil, RuntimeError{p.propName, "Can't set a field to be a class"}
 	}
 
+	if isPrivateName(p.propName.lexeme) {
+		inSetter := i.inSetter(obj, p.propName.lexeme)
+		if isClass {
+			return propValue, class.setPrivate(i, i.privateAccess[p], p.propName, propValue, inSetter)
+		}
+		if err := instance.setPrivate(i.privateAccess[p], p.propName, propValue, inSetter); err != nil {
+			return nil, err
+		}
+		if observer, ok := i.hook.(assignmentObserver); ok {
+			observer.assignedField(instance, p.propName, propValue)
+		}
+		return propValue, nil
+	}
+
+	// If the property has a setter, call it, unless this is the setter assigning
+	// the property, in which case the field is set
+	objectClass := class
+	if isInstance {
+		objectClass = instance.class
+	} else {
+		objectClass = class.metaclass
+	}
+	if objectClass != nil && !i.inSetter(obj, p.propName.lexeme) {
+		if handled, err := objectClass.assignProperty(i, obj, p.propName, propValue); handled || err != nil {
+			return propValue, err
+		}
+	}
+
 	// Actually set the property 
+	if isClass {
+		class.set(p.propName, propValue)
+		return propValue, nil
+	}
+	if err := instance.class.checkDeclaredField(instance.class, p.propName); err != nil {
+		return nil, err
+	}
 	instance.set(p.propName, propValue)
+	if observer, ok := i.hook.(assignmentObserver); ok {
+		observer.assignedField(instance, p.propName, propValue)
+	}
 
 	return propValue, nil
 }
 
+// inSetter reports whether the innermost call is to the setter for a property of
+// the supplied object
+func (i *Interpreter) inSetter(object any, name string) bool {
+	function := i.frames[len(i.frames)-1].function
+	return function != nil && function.declaration.isSetter && function.declaration.functionName.lexeme == name &&
+		function.closure.values["this"] == object
+}
+
 func (i *Interpreter) VisitThisExpr(t *ThisExpr) (any, error) {
 	return i.lookupVariable(t.keyword, t)
 }
@@ -442,9 +784,24 @@ func (i *Interpreter) VisitSuperExpr(s *SuperExpr) (any, error) {
 	if superclass, ok = maybeClass.(*LoxClass); !ok {
 		return nil, RuntimeError{s.keyword, "Is not a class"}
 	}
+	if superclass == nil {
+		// A trait method used by a class with no superclass
+		return nil, RuntimeError{s.keyword, "Can't use 'super' in a class with no superclass"}
+	}
 
-	// Retrieve current class instance 
+	// Retrieve current class instance. In a class method, 'this' is the class, and
+	// 'super' refers to the superclass's class methods.
 	maybeInstance := i.currentEnv.getAt(distance - 1, "this")
+	if class, isClass := maybeInstance.(*LoxClass); isClass {
+		var method *LoxFunction
+		if superclass.metaclass != nil {
+			method = superclass.metaclass.findMethod(s.method.lexeme)
+		}
+		if method == nil {
+			return nil, RuntimeError{s.method, "Undefined property " + s.method.lexeme + "."}
+		}
+		return &LoxBoundMethod{class, method}, nil
+	}
 	if currentInstance, ok = maybeInstance.(*LoxInstance); !ok {
 		return nil, RuntimeError{s.keyword,"'this' is bound to an object instance"}
 	}
@@ -453,8 +810,47 @@ func (i *Interpreter) VisitSuperExpr(s *SuperExpr) (any, error) {
 	if method == nil {
 		return nil, RuntimeError{s.method, "Undefined property " + s.method.lexeme + "."}
 	}
+	if method.declaration.isAbstract {
+		return nil, RuntimeError{s.method, fmt.Sprintf("Can't call abstract method '%s'.", s.method.lexeme)}
+	}
+
+	return &LoxBoundMethod{currentInstance, method}, nil
+}
+
+// VisitInnerExpr calls the refinement of the method being executed: the method with
+// the same name in the next class, going down the receiver's class hierarchy from
+// the class that declared the method. If there's no refinement, inner() is nil.
+func (i *Interpreter) VisitInnerExpr(e *InnerExpr) (any, error) {
+	arguments := make([]any, 0, len(e.arguments))
+	for _, arg := range e.arguments {
+		value, err := i.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, value)
+	}
+
+	// The resolver ensures that inner() is directly inside a method, so the method is
+	// the innermost call
+	method := i.frames[len(i.frames)-1].function
+	this := method.closure.values["this"]
+	var class *LoxClass
+	switch receiver := this.(type) {
+	case *LoxInstance:
+		class = receiver.class
+	case *LoxClass:
+		class = receiver.metaclass
+	}
 
-	return method.bindThis(currentInstance), nil 
+	refinement := class.findRefinement(method)
+	if refinement == nil {
+		return nil, nil
+	}
+	if refinement.arity() != len(arguments) {
+		return nil, RuntimeError{e.paren,
+			fmt.Sprintf("Expected %d arguments but got %d", refinement.arity(), len(arguments))}
+	}
+	return refinement.bindThis(this).call(i, arguments)
 }
 
 // Evaluate expressions in parentheses
@@ -477,13 +873,16 @@ func (i *Interpreter) VisitLogicalExpr(expr *LogicalExpr) (any, error) {
 		// is truthy or not
 		if isTruthy(left) {
 			if expr.Operator.token_type == OR { 
+				i.notifyBranch(expr, 0)
 				return left, nil
 			}
 		} else if expr.Operator.token_type == AND {
+			i.notifyBranch(expr, 0)
 			return left, nil
 		}
 	}
 
+	i.notifyBranch(expr, 1)
 	return i.evaluate(expr.Right)
 }
 
@@ -501,6 +900,8 @@ func (i *Interpreter) VisitUnaryExpr(expr *UnaryExpr) (any, error) {
 	case MINUS:
 		if value, ok := right.(float64); ok {
 			return (-value), nil
+		} else if instance, ok := right.(*LoxInstance); ok {
+			return i.callOperatorMethod(expr.Operator, "'-'", instance, "__neg")
 		} else {
 			return nil, RuntimeError{expr.Operator, "operand to operator - must be a number"}
 		}
@@ -548,5 +949,8 @@ func convertNumberOperands(operator Token, a, b any) (float64, float64, error) {
 }
 
 func isEqual(a any, b any) bool {
+	if method, ok := a.(*LoxBoundMethod); ok {
+		return method.equals(b)
+	}
 	return reflect.DeepEqual(a, b)
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal, with emacs-style editing keys, and the up
+// and down arrows to recall earlier entries
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// rawMode, if set, puts the terminal into raw mode while a line is being read,
+	// returning a function that restores it
+	rawMode func() (func(), error)
+	// complete, if set, is called when Tab is pressed. It returns the words that
+	// could complete the one before the cursor, and the position the word starts at.
+	complete func(line string, pos int) (int, []string)
+}
+
+// lineState is a line being edited
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int // cursor position in buf
+}
+
+func NewLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+// readLine reads a line, which can be edited, or replaced with one of the supplied
+// history entries (oldest first). It returns io.EOF if Ctrl-D is pressed on an
+// empty line, and errInterrupted if Ctrl-C is pressed.
+func (e *lineEditor) readLine(prompt string, history []string) (string, error) {
+	if e.rawMode != nil {
+		restore, err := e.rawMode()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	line := &lineState{prompt: prompt}
+	historyIndex := len(history)
+	var edited []rune // the new line, while a history entry is being shown
+	recall := func(index int) {
+		if index < 0 || index > len(history) || index == historyIndex {
+			return
+		}
+		if historyIndex == len(history) {
+			edited = line.buf
+		}
+		historyIndex = index
+		if index == len(history) {
+			line.buf = edited
+		} else {
+			line.buf = []rune(history[index])
+		}
+		line.pos = len(line.buf)
+	}
+
+	e.refresh(line)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(line.buf) > 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return string(line.buf), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line.buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line.buf) == 0 {
+				return "", io.EOF
+			}
+			line.deleteForward()
+		case 1: // Ctrl-A
+			line.pos = 0
+		case 5: // Ctrl-E
+			line.pos = len(line.buf)
+		case 2: // Ctrl-B
+			line.pos = max(line.pos-1, 0)
+		case 6: // Ctrl-F
+			line.pos = min(line.pos+1, len(line.buf))
+		case 8, 127: // Backspace
+			if line.pos > 0 {
+				line.buf = append(line.buf[:line.pos-1], line.buf[line.pos:]...)
+				line.pos--
+			}
+		case 11: // Ctrl-K
+			line.buf = line.buf[:line.pos]
+		case 21: // Ctrl-U
+			line.buf = line.buf[line.pos:]
+			line.pos = 0
+		case 23: // Ctrl-W
+			line.deleteWordBackward()
+		case '\t':
+			e.completeWord(line)
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			recall(historyIndex - 1)
+		case 14: // Ctrl-N
+			recall(historyIndex + 1)
+		case 27: // Escape sequence
+			switch e.readEscape() {
+			case "[A", "OA":
+				recall(historyIndex - 1)
+			case "[B", "OB":
+				recall(historyIndex + 1)
+			case "[C", "OC":
+				line.pos = min(line.pos+1, len(line.buf))
+			case "[D", "OD":
+				line.pos = max(line.pos-1, 0)
+			case "[H", "OH", "[1~":
+				line.pos = 0
+			case "[F", "OF", "[4~":
+				line.pos = len(line.buf)
+			case "[3~":
+				line.deleteForward()
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line.buf = append(line.buf[:line.pos], append([]rune{r}, line.buf[line.pos:]...)...)
+				line.pos++
+			}
+		}
+		e.refresh(line)
+	}
+}
+
+// completeWord completes the word before the cursor. If there's more than one way to
+// complete it, as much as they have in common is inserted, and if that's nothing the
+// candidates are listed.
+func (e *lineEditor) completeWord(line *lineState) {
+	if e.complete == nil {
+		return
+	}
+	start, candidates := e.complete(string(line.buf), line.pos)
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		word = commonPrefix(word, []rune(candidate))
+	}
+	if len(word) > line.pos-start {
+		line.buf = append(append(append([]rune{}, line.buf[:start]...), word...), line.buf[line.pos:]...)
+		line.pos = start + len(word)
+	} else if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func commonPrefix(a []rune, b []rune) []rune {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// readEscape reads the rest of an escape sequence, after the escape character
+func (e *lineEditor) readEscape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	sequence := []rune{first}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		sequence = append(sequence, r)
+		if r < '0' || r > '9' {
+			return string(sequence)
+		}
+	}
+}
+
+// refresh redraws the line, and puts the cursor in the right place
+func (e *lineEditor) refresh(line *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", line.prompt, string(line.buf))
+	if back := len(line.buf) - line.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (l *lineState) deleteForward() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+func (l *lineState) deleteWordBackward() {
+	start := l.pos
+	for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+	l.pos = start
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lintSeverity controls what happens when a lint rule is violated: nothing,
+// a warning that doesn't block execution, or an error that does
+type lintSeverity int
+
+const (
+	lintOff lintSeverity = iota
+	lintWarning
+	lintError
+)
+
+var lintSeverityNames = map[string]lintSeverity{
+	"off":     lintOff,
+	"warning": lintWarning,
+	"error":   lintError,
+}
+
+type lintRule string
+
+const (
+	lintUnusedLocal           lintRule = "unused-local"
+	lintUnusedParameter       lintRule = "unused-parameter"
+	lintShadowing             lintRule = "shadowing"
+	lintUndeclaredGlobal      lintRule = "undeclared-global"
+	lintSelfComparison        lintRule = "self-comparison"
+	lintUnimplementedAbstract lintRule = "unimplemented-abstract"
+)
+
+// Severity of each lint rule, if not overridden by the project config file
+var defaultLintSeverities = map[lintRule]lintSeverity{
+	lintUnusedLocal:           lintWarning,
+	lintUnusedParameter:       lintWarning,
+	lintShadowing:             lintOff,
+	lintUndeclaredGlobal:      lintWarning,
+	lintSelfComparison:        lintWarning,
+	lintUnimplementedAbstract: lintWarning,
+}
+
+// lintIgnoreDirective is the comment prefix used to suppress lint rules
+// eg "// lox:ignore unused-local, shadowing". A directive without any rule
+// names suppresses all rules.
+const lintIgnoreDirective = "lox:ignore"
+
+// lintDirective is a 'lox:ignore' comment. A trailing comment, after code on the
+// same line, only applies to its own line. A comment on a line of its own applies to
+// the line after it, and to the whole of the statement that follows it, so that
+// reformatting the statement can't move code out from under the directive.
+type lintDirective struct {
+	rules    []string // nil means all rules
+	trailing bool
+}
+
+// LintConfig holds the severity of each lint rule
+type LintConfig struct {
+	severities map[lintRule]lintSeverity
+}
+
+func NewLintConfig() *LintConfig {
+	return &LintConfig{severities: make(map[lintRule]lintSeverity)}
+}
+
+// disabledLintConfig turns off all lint rules, for tools that resolve code without
+// wanting to report style problems
+func disabledLintConfig() *LintConfig {
+	config := NewLintConfig()
+	for rule := range defaultLintSeverities {
+		config.severities[rule] = lintOff
+	}
+	return config
+}
+
+func (c *LintConfig) severity(rule lintRule) lintSeverity {
+	if c != nil {
+		if severity, ok := c.severities[rule]; ok {
+			return severity
+		}
+	}
+	return defaultLintSeverities[rule]
+}
+
+func (c *LintConfig) setSeverity(rule string, severity string) error {
+	if _, ok := defaultLintSeverities[lintRule(rule)]; !ok {
+		return fmt.Errorf("unknown lint rule '%s'", rule)
+	}
+	value, ok := lintSeverityNames[severity]
+	if !ok {
+		return fmt.Errorf("unknown severity '%s' for lint rule '%s' (expected off, warning or error)", severity, rule)
+	}
+	c.severities[lintRule(rule)] = value
+	return nil
+}
+
+// Linter reports lint rule violations found by the Resolver, taking into account the
+// configured severity of each rule and any 'lox:ignore' comments in the source
+type Linter struct {
+	runtime LoxRuntime
+	config  *LintConfig
+	// ignores maps a source line to the rules suppressed on that line; a nil
+	// rule list means all rules are suppressed
+	ignores map[int][]string
+}
+
+func NewLinter(runtime LoxRuntime, config *LintConfig, directives map[int]lintDirective, parser *Parser) *Linter {
+	ignores := make(map[int][]string)
+	for line, directive := range directives {
+		first, last := line, line
+		if !directive.trailing {
+			first, last = line+1, max(line+1, nextStatementEnd(parser, line))
+		}
+		for l := first; l <= last; l++ {
+			if existing, ok := ignores[l]; ok && (existing == nil || directive.rules == nil) {
+				ignores[l] = nil
+			} else {
+				ignores[l] = append(existing, directive.rules...)
+			}
+		}
+	}
+
+	return &Linter{runtime: runtime, config: config, ignores: ignores}
+}
+
+// nextStatementEnd returns the line on which the first statement after the supplied
+// line ends, or 0 if there's no such statement. Of the statements starting on the
+// same line, the outermost one is used.
+func nextStatementEnd(parser *Parser, line int) int {
+	if parser == nil {
+		return 0
+	}
+	start, end := 0, 0
+	for stmt, stmtLine := range parser.stmtLines {
+		if stmtLine <= line {
+			continue
+		}
+		if start == 0 || stmtLine < start {
+			start, end = stmtLine, parser.stmtEndLines[stmt]
+		} else if stmtLine == start {
+			end = max(end, parser.stmtEndLines[stmt])
+		}
+	}
+	return end
+}
+
+func (l *Linter) report(rule lintRule, token Token, message string) {
+	if l.isIgnored(rule, token.line) {
+		return
+	}
+
+	message = fmt.Sprintf("%s [%s]", message, rule)
+	switch l.config.severity(rule) {
+	case lintWarning:
+		l.runtime.warning(token, message)
+	case lintError:
+		l.runtime.parseError(token, message)
+	}
+}
+
+func (l *Linter) isIgnored(rule lintRule, line int) bool {
+	rules, ok := l.ignores[line]
+	if !ok {
+		return false
+	}
+	if rules == nil {
+		return true
+	}
+	for _, r := range rules {
+		if r == string(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLintDirective extracts the rules named in a 'lox:ignore' comment. The
+// second return value is false if the comment isn't a lint directive.
+func parseLintDirective(comment string) ([]string, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+	if !strings.HasPrefix(text, lintIgnoreDirective) {
+		return nil, false
+	}
+
+	text = strings.TrimPrefix(text, lintIgnoreDirective)
+	if text != "" && text[0] != ' ' && text[0] != '\t' {
+		return nil, false // eg "lox:ignored", not a directive
+	}
+
+	var rules []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		rules = append(rules, field)
+	}
+	return rules, true
+}
+
+// sameOperand reports whether two expressions trivially refer to the same value,
+// eg 'x' and 'x', or 'this.x' and 'this.x'
+func sameOperand(a Expr, b Expr) bool {
+	if group, ok := a.(*GroupingExpr); ok {
+		return sameOperand(group.Expression, b)
+	}
+	if group, ok := b.(*GroupingExpr); ok {
+		return sameOperand(a, group.Expression)
+	}
+
+	switch left := a.(type) {
+	case *VariableExpr:
+		right, ok := b.(*VariableExpr)
+		return ok && left.variable.lexeme == right.variable.lexeme
+	case *ThisExpr:
+		_, ok := b.(*ThisExpr)
+		return ok
+	case *PropGetExpr:
+		right, ok := b.(*PropGetExpr)
+		return ok && left.propName.lexeme == right.propName.lexeme && sameOperand(left.object, right.object)
+	}
+	return false
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// LINT TESTS
+// ============================================================================
+
+func TestLintRules(t *testing.T) {
+	t.Run("Unused local variable is a warning by default", func(t *testing.T) {
+		program := `
+{
+  var unused = 1;
+}
+`
+		runProgramAndExpectWarning(t, program, "Unused variable 'unused' [unused-local]", "Unused local variable")
+	})
+
+	t.Run("Unused parameter is a warning by default", func(t *testing.T) {
+		program := `
+fun callback(a, b) {
+  print a;
+}
+callback(1, 2);
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError {
+			t.Errorf("Expected unused parameter not to block execution")
+		}
+		assertContains(t, stderr, "Warning at b : Unused variable 'b' (function parameter) [unused-parameter]")
+	})
+
+	t.Run("Unimplemented abstract method is a warning by default", func(t *testing.T) {
+		program := `
+abstract class Shape {
+  abstract area();
+  abstract perimeter();
+}
+trait Round {
+  perimeter() {
+    return 0;
+  }
+}
+class Circle < Shape with Round {}
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError {
+			t.Errorf("Expected unimplemented abstract method not to block execution")
+		}
+		assertContains(t, stderr, "Warning at Circle : Class 'Circle' doesn't implement abstract method 'area' of 'Shape' [unimplemented-abstract]")
+		if strings.Contains(stderr, "'perimeter'") {
+			t.Errorf("Expected method implemented by a trait not to be reported, got: %s", stderr)
+		}
+	})
+
+	t.Run("Shadowing is off by default", func(t *testing.T) {
+		program := `
+var a = 1;
+{
+  var a = 2;
+  print a;
+}
+`
+		stderr, _ := runProgramWithLintConfig(t, program, nil)
+		if strings.Contains(stderr, "shadowing") {
+			t.Errorf("Expected no shadowing warning, got: %s", stderr)
+		}
+	})
+
+	t.Run("Shadowing of local and global variables", func(t *testing.T) {
+		program := `
+var a = 1;
+fun f(x) {
+  var a = x;
+  {
+    var x = a;
+    print x;
+  }
+}
+f(1);
+`
+		config := NewLintConfig()
+		_ = config.setSeverity("shadowing", "warning")
+		stderr, glox := runProgramWithLintConfig(t, program, config)
+		if glox.hadError {
+			t.Errorf("Expected shadowing warning not to block execution")
+		}
+		assertContains(t, stderr, "[line 4] Warning at a : Declaration of 'a' shadows a variable in an enclosing scope [shadowing]")
+		assertContains(t, stderr, "[line 6] Warning at x : Declaration of 'x' shadows a variable in an enclosing scope [shadowing]")
+	})
+
+	t.Run("Assignment to undeclared global", func(t *testing.T) {
+		program := `
+fun f() {
+  countr = 1;
+  count = 2;
+}
+var count = 0;
+`
+		stderr, _ := runProgramWithLintConfig(t, program, nil)
+		assertContains(t, stderr, "Assignment to undeclared variable 'countr' [undeclared-global]")
+		if strings.Contains(stderr, "'count'") {
+			t.Errorf("Expected global declared after use not to be reported, got: %s", stderr)
+		}
+	})
+
+	t.Run("Assignment to native function is not undeclared", func(t *testing.T) {
+		program := `clock = 1;`
+		stderr, _ := runProgramWithLintConfig(t, program, nil)
+		if strings.Contains(stderr, "undeclared-global") {
+			t.Errorf("Expected no undeclared-global warning, got: %s", stderr)
+		}
+	})
+
+	t.Run("Self comparison", func(t *testing.T) {
+		program := `
+var x = 1;
+class A {
+  init() { this.y = 1; }
+  check() { return this.y == this.y; }
+}
+print x == x;
+print x < (x);
+print x == 1;
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError {
+			t.Errorf("Expected self comparison warning not to block execution")
+		}
+		assertContains(t, stderr, "[line 5] Warning at == : Comparison of an expression with itself [self-comparison]")
+		assertContains(t, stderr, "[line 7] Warning at == : Comparison of an expression with itself [self-comparison]")
+		assertContains(t, stderr, "[line 8] Warning at < : Comparison of an expression with itself [self-comparison]")
+		if strings.Contains(stderr, "[line 9]") {
+			t.Errorf("Expected no warning for comparison with a literal, got: %s", stderr)
+		}
+	})
+
+	t.Run("Rule can be turned off", func(t *testing.T) {
+		program := `
+{
+  var unused = 1;
+}
+`
+		config := NewLintConfig()
+		_ = config.setSeverity("unused-local", "off")
+		stderr, glox := runProgramWithLintConfig(t, program, config)
+		if glox.hadError || stderr != "" {
+			t.Errorf("Expected disabled rule not to be reported, got: %s", stderr)
+		}
+	})
+
+	t.Run("Warning can be promoted to an error", func(t *testing.T) {
+		program := `
+fun f(a) {}
+f(1);
+`
+		config := NewLintConfig()
+		_ = config.setSeverity("unused-parameter", "error")
+		stderr, glox := runProgramWithLintConfig(t, program, config)
+		if !glox.hadError {
+			t.Errorf("Expected unused parameter to be an error")
+		}
+		assertContains(t, stderr, "Error at a : Unused variable 'a' (function parameter)")
+	})
+}
+
+func TestLintIgnoreComments(t *testing.T) {
+	t.Run("Trailing ignore comment", func(t *testing.T) {
+		program := `
+{
+  var unused = 1; // lox:ignore unused-local
+}
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError || stderr != "" {
+			t.Errorf("Expected ignored rule not to be reported, got: %s", stderr)
+		}
+	})
+
+	t.Run("Ignore comment on preceding line", func(t *testing.T) {
+		program := `
+// lox:ignore unused-parameter, unused-local
+fun f(a) { var b; }
+f(1);
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError || stderr != "" {
+			t.Errorf("Expected ignored rules not to be reported, got: %s", stderr)
+		}
+	})
+
+	t.Run("Ignore comment without rules ignores everything", func(t *testing.T) {
+		program := `
+fun f(a) { var b; } // lox:ignore
+f(1);
+`
+		stderr, glox := runProgramWithLintConfig(t, program, nil)
+		if glox.hadError || stderr != "" {
+			t.Errorf("Expected all rules to be ignored, got: %s", stderr)
+		}
+	})
+
+	t.Run("Ignore comment only applies to named rules", func(t *testing.T) {
+		program := `
+fun f(a) { var b; } // lox:ignore unused-parameter
+f(1);
+`
+		runProgramAndExpectWarning(t, program, "Unused variable 'b'", "Ignore comment only applies to named rules")
+	})
+
+	t.Run("Ignore comment on its own line covers the whole next statement", func(t *testing.T) {
+		program := `
+// lox:ignore unused-local
+{
+    var z = 1;
+}
+{
+    var y = 2;
+}
+`
+		config := NewLintConfig()
+		assertNoError(t, config.setSeverity("unused-local", "error"), "Set severity")
+		stderr, _ := runProgramWithLintConfig(t, program, config)
+		assertContains(t, stderr, "Unused variable 'y'")
+		if strings.Contains(stderr, "Unused variable 'z'") {
+			t.Errorf("Expected ignored rule not to be reported in the statement, got: %s", stderr)
+		}
+	})
+
+	t.Run("Trailing ignore comment doesn't apply to the next line", func(t *testing.T) {
+		program := `
+{
+  var a = 1; // lox:ignore unused-local
+  var b = 2;
+}
+`
+		stderr, _ := runProgramWithLintConfig(t, program, nil)
+		assertContains(t, stderr, "Unused variable 'b'")
+		if strings.Contains(stderr, "Unused variable 'a'") {
+			t.Errorf("Expected ignored rule not to be reported on its own line, got: %s", stderr)
+		}
+	})
+
+	t.Run("Parse lint directives", func(t *testing.T) {
+		tests := []struct {
+			comment  string
+			rules    []string
+			isIgnore bool
+		}{
+			{"// lox:ignore", nil, true},
+			{"//lox:ignore shadowing", []string{"shadowing"}, true},
+			{"// lox:ignore shadowing,unused-local", []string{"shadowing", "unused-local"}, true},
+			{"// lox:ignored", nil, false},
+			{"// just a comment", nil, false},
+		}
+		for _, test := range tests {
+			rules, ok := parseLintDirective(test.comment)
+			assertEqual(t, test.isIgnore, ok, test.comment)
+			assertEqual(t, test.rules, rules, test.comment)
+		}
+	})
+}
+
+func TestLintConfigFile(t *testing.T) {
+	t.Run("Config file is found in parent directory", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, projectConfigFileName), `{"lint": {"unused-local": "warning", "shadowing": "error"}}`)
+		subdir := filepath.Join(root, "scripts", "nested")
+		if err := os.MkdirAll(subdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := loadProjectConfig(subdir)
+		assertNoError(t, err, "Load config")
+		assertEqual(t, lintWarning, config.lint.severity(lintUnusedLocal), "unused-local")
+		assertEqual(t, lintError, config.lint.severity(lintShadowing), "shadowing")
+		assertEqual(t, lintWarning, config.lint.severity(lintSelfComparison), "default severity")
+	})
+
+	t.Run("Missing config file gives defaults", func(t *testing.T) {
+		config, err := loadProjectConfig(t.TempDir())
+		assertNoError(t, err, "Load config")
+		assertEqual(t, lintWarning, config.lint.severity(lintUnusedLocal), "unused-local")
+	})
+
+	t.Run("Unknown rule is rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, projectConfigFileName), `{"lint": {"no-such-rule": "off"}}`)
+		_, err := loadProjectConfig(dir)
+		assertError(t, err, "Unknown rule")
+	})
+
+	t.Run("Unknown severity is rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, projectConfigFileName), `{"lint": {"shadowing": "fatal"}}`)
+		_, err := loadProjectConfig(dir)
+		assertError(t, err, "Unknown severity")
+	})
+}
+
+// ============================================================================
+// HELPER FUNCTIONS
+// ============================================================================
+
+// runProgramWithLintConfig runs a program with the supplied lint configuration,
+// returning everything written to stderr
+// runProgramAndExpectWarning runs a program that should run despite reporting a
+// lint warning
+func runProgramAndExpectWarning(t *testing.T, program string, expectedWarning string, testName string) {
+	t.Helper()
+	stderr, glox := runProgramWithLintConfig(t, program, nil)
+	if glox.hadError || glox.hadRuntimeError {
+		t.Errorf("%s: Expected only a warning, got: %s", testName, stderr)
+	}
+	assertContains(t, stderr, "Warning at")
+	assertContains(t, stderr, expectedWarning)
+}
+
+func runProgramWithLintConfig(t *testing.T, program string, config *LintConfig) (string, *GLox) {
+	t.Helper()
+
+	glox := &GLox{config: &ProjectConfig{lint: config}}
+	glox.interpreter = NewInterpreter(glox)
+
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdout = devNull
+	os.Stderr = w
+
+	glox.run(program, false)
+
+	w.Close()
+	devNull.Close()
+	os.Stdout, os.Stderr = originalStdout, originalStderr
+
+	buf := make([]byte, 4096)
+	n, _ := r.Read(buf)
+	return string(buf[:n]), glox
+}
+
+func assertContains(t *testing.T, s string, substr string) {
+	t.Helper()
+	if !strings.Contains(s, substr) {
+		t.Errorf("Expected output to contain '%s', got: %s", substr, s)
+	}
+}
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+// LoxBoundMethod is a method accessed through an object, bound to that object so
+// that 'this' refers to it when the method is called. The receiver is an instance,
+// or a class for class methods. A new bound method is created each time a method is
+// accessed, so two bound methods are equal if they bind the same method to the same
+// receiver.
+type LoxBoundMethod struct {
+	receiver any
+	method   *LoxFunction
+}
+
+func (bm *LoxBoundMethod) call(interpreter *Interpreter, arguments []any) (any, error) {
+	return bm.method.bindThis(bm.receiver).call(interpreter, arguments)
+}
+
+func (bm *LoxBoundMethod) arity() int {
+	return bm.method.arity()
+}
+
+func (bm *LoxBoundMethod) name() string {
+	return bm.method.declaration.functionName.lexeme
+}
+
+// equals reports whether other is a bound method with the same receiver and method
+func (bm *LoxBoundMethod) equals(other any) bool {
+	otherMethod, ok := other.(*LoxBoundMethod)
+	return ok && bm.receiver == otherMethod.receiver && bm.method == otherMethod.method
+}
+
+func (bm *LoxBoundMethod) String() string {
+	return fmt.Sprintf("<fn %s>", bm.name())
+}
@@ -1,8 +1,22 @@
 package main 
 
 // The LoxCallable interface needs to be implemented by anything that can be 
-// called from a Lox program ie functions and (class) methods. 
+// called from a Lox program ie functions, classes, bound methods, and instances
+// of classes that define __call. 
 type LoxCallable interface {
 	arity() int 
 	call(i *Interpreter, arguments []any) (any, error)
-}
\ No newline at end of file
+}
+
+// asCallable returns a value as a LoxCallable if it can be called, which includes
+// instances whose class defines __call
+func asCallable(value any) (LoxCallable, bool) {
+	if instance, ok := value.(*LoxInstance); ok {
+		if method := instance.class.findMethod("__call"); method != nil {
+			return &callableInstance{instance, method}, true
+		}
+		return nil, false
+	}
+	callable, ok := value.(LoxCallable)
+	return callable, ok
+}
@@ -1,20 +1,44 @@
 package main
 
+import (
+	"fmt"
+)
+
 type LoxClass struct {
 	name    string
 	superclass *LoxClass 
 	methods map[string]*LoxFunction
+	// A class is also an object, whose class is its metaclass: the metaclass holds
+	// the class methods, and fields holds the static fields
+	metaclass *LoxClass
+	fields    map[string]any
+	// setters are the 'set name(value)' methods, which are kept apart from the other
+	// methods since a property can have both a getter and a setter
+	setters map[string]*LoxFunction
+	// declaration is the statement that declared the class, if it was declared in Lox
+	declaration *ClassStmt
+	private     privateFields // private static fields, see private.go
+	// fieldsEnv is the environment that the initializers of declared fields are
+	// evaluated in, with 'this' bound to the new instance
+	fieldsEnv *Environment
+	traits    []*LoxTrait // traits the class uses, which 'is' checks too
 }
 
 func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
-	return &LoxClass{name: name, superclass: superclass, methods: methods}
+	return &LoxClass{name: name, superclass: superclass, methods: methods, fields: make(map[string]any)}
 }
 
 // call() is invoked on a LoxClass to construct a new instance of the class
 func (lc *LoxClass) call(i *Interpreter, arguments []any) (any, error) {
+	if err := lc.checkInstantiable(); err != nil {
+		return nil, err
+	}
 
-	// construct the instance
+	// construct the instance, and initialize its declared fields before init() runs
 	instance := NewLoxInstance(i, lc)
+	if err := lc.initializeFields(i, instance); err != nil {
+		return nil, err
+	}
 
 	// If class has an init() function, call it to do any instance initialization needed
 	if initializer := lc.findMethod("init"); initializer != nil {
@@ -25,6 +49,86 @@ func (lc *LoxClass) call(i *Interpreter, arguments []any) (any, error) {
 	return instance, nil
 }
 
+// initializeFields sets the fields declared in the class body on a new instance,
+// starting with those declared by its superclasses
+func (lc *LoxClass) initializeFields(i *Interpreter, instance *LoxInstance) error {
+	if lc.superclass != nil {
+		if err := lc.superclass.initializeFields(i, instance); err != nil {
+			return err
+		}
+	}
+	if lc.declaration == nil || len(lc.declaration.fields) == 0 {
+		return nil
+	}
+
+	env := NewEnvironment(lc.fieldsEnv)
+	env.defineVarValue("this", instance)
+	prevEnv := i.currentEnv
+	i.currentEnv = env
+	defer func() { i.currentEnv = prevEnv }()
+
+	for _, field := range lc.declaration.fields {
+		var value any
+		if field.initializer != nil {
+			var err error
+			if value, err = i.evaluate(field.initializer); err != nil {
+				return err
+			}
+		}
+		if isPrivateName(field.variable.lexeme) {
+			if instance.private == nil {
+				instance.private = make(privateFields)
+			}
+			instance.private[privateKey{lc.declaration, field.variable.lexeme}] = value
+		} else {
+			instance.set(field.variable, value)
+		}
+	}
+	return nil
+}
+
+// isSealed reports whether the class, or one of its superclasses, is sealed
+func (lc *LoxClass) isSealed() bool {
+	for class := lc; class != nil; class = class.superclass {
+		if class.declaration != nil && class.declaration.sealed {
+			return true
+		}
+	}
+	return false
+}
+
+// declaresField reports whether the class body declares a field with the given name
+func (lc *LoxClass) declaresField(name string) bool {
+	if lc.declaration == nil {
+		return false
+	}
+	for _, field := range lc.declaration.fields {
+		if field.variable.lexeme == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeclaredField returns an error if an instance of a sealed class is assigned
+// a field that isn't declared by owner or, for public fields, one of its
+// superclasses
+func (lc *LoxClass) checkDeclaredField(owner *LoxClass, token Token) error {
+	if !lc.isSealed() {
+		return nil
+	}
+	for class := owner; class != nil; class = class.superclass {
+		if class.declaresField(token.lexeme) {
+			return nil
+		}
+		if isPrivateName(token.lexeme) {
+			break // private fields are only declared by their owner
+		}
+	}
+	return RuntimeError{token, fmt.Sprintf("Can't assign undeclared field '%s' on instance of sealed class %s.",
+		token.lexeme, lc.name)}
+}
+
 func (lc *LoxClass) arity() int {
 	// Arity is determined by number of parameters the init function takes,
 	// if there is an init function
@@ -35,19 +139,101 @@ func (lc *LoxClass) arity() int {
 	return 0
 }
 
+// findMethod finds the method with the supplied name. A method is normally overridden
+// by a method with the same name in a subclass, but a method that calls inner() is
+// refined instead: it's found even though subclasses have the method too, and calls
+// their methods through inner().
 func (lc *LoxClass) findMethod(methodName string) *LoxFunction {
-	if method, ok := lc.methods[methodName]; ok {
-		return method
+	return lc.findMember(methodName, func(class *LoxClass) map[string]*LoxFunction { return class.methods })
+}
+
+func (lc *LoxClass) findSetter(name string) *LoxFunction {
+	return lc.findMember(name, func(class *LoxClass) map[string]*LoxFunction { return class.setters })
+}
+
+func (lc *LoxClass) findMember(name string, membersOf func(*LoxClass) map[string]*LoxFunction) *LoxFunction {
+	var found *LoxFunction
+	for class := lc; class != nil; class = class.superclass {
+		if member, ok := membersOf(class)[name]; ok && (found == nil || member.declaration.usesInner) {
+			found = member
+		}
 	}
+	return found
+}
 
-	// Couldn't find method on class itself, look in superclass 
-	if lc.superclass != nil {
-		return lc.superclass.findMethod(methodName)
+// findRefinement finds the method that refines a bound method, looking down the class
+// hierarchy from the class that declared the method towards this class
+func (lc *LoxClass) findRefinement(method *LoxFunction) *LoxFunction {
+	hierarchy := make([]*LoxClass, 0)
+	for class := lc; class != nil; class = class.superclass {
+		hierarchy = append(hierarchy, class)
 	}
 
+	// A bound method's closure encloses the closure of the method it was bound from
+	declaring := method.closure.enclosing
+	name := method.declaration.functionName.lexeme
+	found := false
+	for n := len(hierarchy) - 1; n >= 0; n-- {
+		methods := hierarchy[n].methods
+		if method.declaration.isSetter {
+			methods = hierarchy[n].setters
+		}
+		if candidate, ok := methods[name]; ok {
+			if found {
+				return candidate
+			}
+			found = candidate.closure == declaring
+		}
+	}
 	return nil
 }
 
+// assignProperty assigns to a property of an object whose class is the supplied
+// class. If the class has a setter for the property, the setter is called and true
+// is returned. Assigning to a property that only has a getter is an error.
+func (lc *LoxClass) assignProperty(i *Interpreter, object any, token Token, value any) (bool, error) {
+	if setter := lc.findSetter(token.lexeme); setter != nil {
+		_, err := setter.bindThis(object).call(i, []any{value})
+		return true, err
+	}
+	if method := lc.findMethod(token.lexeme); method != nil && method.declaration.isGetter {
+		return false, RuntimeError{token, fmt.Sprintf("Can't assign to read-only property '%s'.", token.lexeme)}
+	}
+	return false, nil
+}
+
+// get retrieves a property of the class itself: a static field, which can be
+// inherited from a superclass, or a class method bound to the class
+func (lc *LoxClass) get(i *Interpreter, token Token) (any, error) {
+	// Private members are only accessible through getPrivate
+	if isPrivateName(token.lexeme) {
+		_, err := privateOwner(nil, nil, token)
+		return nil, err
+	}
+
+	for class := lc; class != nil; class = class.superclass {
+		if value, ok := class.fields[token.lexeme]; ok {
+			return value, nil
+		}
+	}
+
+	if lc.metaclass != nil {
+		if method := lc.metaclass.findMethod(token.lexeme); method != nil {
+			if method.declaration.isGetter {
+				return method.bindThis(lc).call(i, nil)
+			}
+			return &LoxBoundMethod{lc, method}, nil
+		}
+	}
+
+	return nil, RuntimeError{token, fmt.Sprintf("undefined property name %s", token.lexeme)}
+}
+
+// set sets a static field of the class
+func (lc *LoxClass) set(token Token, value any) {
+	lc.fields[token.lexeme] = value
+}
+
 func (lc *LoxClass) String() string {
 	return lc.name
 }
@@ -8,8 +8,16 @@ type LoxFunction struct {
 	isInitializer bool
 }
 
-// Execute the actual function that's wrapped by the enclosing LoxFunction
+// Execute the actual function that's wrapped by the enclosing LoxFunction, as a
+// new call frame
 func (lf *LoxFunction) call(interpreter *Interpreter, arguments []any) (any, error) {
+	interpreter.pushFrame(lf, arguments)
+	result, err := lf.execute(interpreter, arguments)
+	interpreter.popFrame(result, err)
+	return result, err
+}
+
+func (lf *LoxFunction) execute(interpreter *Interpreter, arguments []any) (any, error) {
 
 	// Create the scope within which the function will execute, as a child of the closure/scope
 	// associated with the function
@@ -46,10 +54,14 @@ func (lf *LoxFunction) arity() int {
 	return len(lf.declaration.params)
 }
 
+func (lf *LoxFunction) String() string {
+	return "<fn " + lf.declaration.functionName.lexeme + ">"
+}
+
 // bindThis() binds the 'this' variable for the given function instance to the
-// supplied class instance,
-func (lf *LoxFunction) bindThis(li *LoxInstance) *LoxFunction {
+// supplied class instance, or to the class itself for a class method
+func (lf *LoxFunction) bindThis(this any) *LoxFunction {
 	env := NewEnvironment(lf.closure)
-	env.defineVarValue("this", li)
+	env.defineVarValue("this", this)
 	return &LoxFunction{lf.declaration, env, lf.isInitializer}
 }
@@ -8,6 +8,7 @@ type LoxInstance struct {
 	interpreter *Interpreter
 	class       *LoxClass // class that this is an instance of
 	fields      map[string]any
+	private     privateFields // private fields, see private.go
 }
 
 func NewLoxInstance(interpreter *Interpreter, class *LoxClass) *LoxInstance {
@@ -19,6 +20,12 @@ func NewLoxInstance(interpreter *Interpreter, class *LoxClass) *LoxInstance {
 }
 
 func (li *LoxInstance) get(token Token) (any, error) {
+	// Private members are only accessible through getPrivate
+	if isPrivateName(token.lexeme) {
+		_, err := privateOwner(nil, nil, token)
+		return nil, err
+	}
+
 	// First look for instance fields with matching name
 	if value, ok := li.fields[token.lexeme]; ok {
 		return value, nil
@@ -27,24 +34,39 @@ func (li *LoxInstance) get(token Token) (any, error) {
 	// No instance field matches, look for matching method on class, and
 	// bind it to this instance
 	if method := li.class.findMethod(token.lexeme); method != nil {
-		boundMethod := method.bindThis(li)
 		// If the method is a getter function, execute it immediately, to generate
 		// the return value from the getter
 		if method.declaration.isGetter {
-			return boundMethod.call(li.interpreter, nil)
+			return method.bindThis(li).call(li.interpreter, nil)
 		}
 
-		// Otherwise, just return the function itself
-		return boundMethod, nil
+		// Otherwise, just return the method bound to this instance
+		return &LoxBoundMethod{li, method}, nil
 	}
 
 	return nil, RuntimeError{token, fmt.Sprintf("undefined property name %s", token.lexeme)}
 }
 
+// set sets a public field. Private fields are set with setPrivate.
 func (li *LoxInstance) set(token Token, value any) {
 	li.fields[token.lexeme] = value
 }
 
+// callableInstance is an instance whose class defines __call, which makes it
+// callable. Instances are wrapped in one by asCallable when they're called.
+type callableInstance struct {
+	instance *LoxInstance
+	method   *LoxFunction // the class's __call method
+}
+
+func (ci *callableInstance) call(interpreter *Interpreter, arguments []any) (any, error) {
+	return ci.method.bindThis(ci.instance).call(interpreter, arguments)
+}
+
+func (ci *callableInstance) arity() int {
+	return ci.method.arity()
+}
+
 func (li *LoxInstance) String() string {
 	return fmt.Sprintf("Instance of class %s", li.class.name)
 }
@@ -0,0 +1,28 @@
+package main
+
+// LoxTrait is the runtime representation of a trait: a set of methods that can be
+// copied into any class that lists the trait after 'with'
+type LoxTrait struct {
+	name    string
+	methods map[string]*LoxFunction
+	setters map[string]*LoxFunction
+}
+
+// composeInto returns copies of the trait's methods and setters for use by a class
+// with the supplied superclass, so that 'super' in the trait's methods refers to it
+func (lt *LoxTrait) composeInto(superclass *LoxClass) (map[string]*LoxFunction, map[string]*LoxFunction) {
+	compose := func(functions map[string]*LoxFunction) map[string]*LoxFunction {
+		composed := make(map[string]*LoxFunction, len(functions))
+		for name, function := range functions {
+			env := NewEnvironment(function.closure)
+			env.defineVarValue("super", superclass)
+			composed[name] = &LoxFunction{function.declaration, env, function.isInitializer}
+		}
+		return composed
+	}
+	return compose(lt.methods), compose(lt.setters)
+}
+
+func (lt *LoxTrait) String() string {
+	return lt.name
+}
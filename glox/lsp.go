@@ -0,0 +1,816 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ----------------------------------------------------------------------------
+// Protocol types. Only the subset of the Language Server Protocol used by glox is
+// defined here.
+// ----------------------------------------------------------------------------
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+// Diagnostic severities
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+)
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+}
+
+type lspReferenceParams struct {
+	lspTextDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+	Range    lspRange         `json:"range"`
+}
+
+// Symbol kinds used in document symbols
+const (
+	lspSymbolKindClass       = 5
+	lspSymbolKindMethod      = 6
+	lspSymbolKindProperty    = 7
+	lspSymbolKindField       = 8
+	lspSymbolKindConstructor = 9
+	lspSymbolKindInterface   = 11
+	lspSymbolKindFunction    = 12
+	lspSymbolKindVariable    = 13
+)
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+// Completion item kinds
+const (
+	lspCompletionKindMethod    = 2
+	lspCompletionKindFunction  = 3
+	lspCompletionKindField     = 5
+	lspCompletionKindVariable  = 6
+	lspCompletionKindClass     = 7
+	lspCompletionKindInterface = 8
+	lspCompletionKindKeyword   = 14
+)
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  any              `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// JSON-RPC error codes
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+)
+
+// ----------------------------------------------------------------------------
+// Document analysis
+// ----------------------------------------------------------------------------
+
+// documentAnalysis holds the result of running the scanner, parser and resolver over
+// the contents of a document
+type documentAnalysis struct {
+	text        string
+	tokens      []Token
+	statements  []Stmt // the statements that parsed
+	parsed      bool   // whether the whole document parsed
+	symbols     *SymbolTable
+	diagnostics []lspDiagnostic
+}
+
+func analyzeDocument(text string, lintConfig *LintConfig) *documentAnalysis {
+	analysis := &documentAnalysis{text: text, symbols: NewSymbolTable(), diagnostics: make([]lspDiagnostic, 0)}
+
+	// Scanner errors are taken from the scanner itself, since it knows their columns
+	scanner := NewScanner(NewDiagnosticCollector(), text)
+	analysis.tokens = scanner.scanTokens()
+	for _, err := range scanner.errors {
+		start := lspPosition{err.line - 1, err.column - 1}
+		analysis.diagnostics = append(analysis.diagnostics, lspDiagnostic{
+			Range:    lspRange{start, lspPosition{start.Line, start.Character + 1}},
+			Severity: lspSeverityError,
+			Source:   "glox",
+			Message:  err.message,
+		})
+	}
+
+	collector := NewDiagnosticCollector()
+	parser := NewParser(collector, analysis.tokens)
+	analysis.statements = parser.parseRecovering()
+	analysis.parsed = !collector.hadError()
+
+	// If the document doesn't parse, the statements that did are still resolved so
+	// that they can be navigated, but the problems found in them aren't reported, as
+	// they may just be caused by the syntax errors
+	resolverRuntime := collector
+	if !analysis.parsed {
+		resolverRuntime, lintConfig = NewDiagnosticCollector(), disabledLintConfig()
+	}
+	resolver := NewResolver(resolverRuntime, NewInterpreter(resolverRuntime))
+	resolver.linter = NewLinter(resolverRuntime, lintConfig, scanner.lintDirectives, parser)
+	resolver.symbols = analysis.symbols
+	_ = resolver.resolveStmts(analysis.statements)
+
+	for _, diag := range collector.diagnostics {
+		analysis.diagnostics = append(analysis.diagnostics, analysis.lspDiagnostic(diag))
+	}
+	return analysis
+}
+
+func (a *documentAnalysis) lspDiagnostic(diag diagnostic) lspDiagnostic {
+	severity := lspSeverityError
+	if diag.kind == diagnosticWarning {
+		severity = lspSeverityWarning
+	}
+
+	// Errors without a token apply to the whole line
+	var rng lspRange
+	if diag.token != nil {
+		rng = tokenRange(*diag.token)
+	} else {
+		lines := strings.Split(a.text, "\n")
+		length := 0
+		if diag.line-1 < len(lines) {
+			length = len([]rune(lines[diag.line-1]))
+		}
+		rng = lspRange{lspPosition{diag.line - 1, 0}, lspPosition{diag.line - 1, length}}
+	}
+	return lspDiagnostic{Range: rng, Severity: severity, Source: "glox", Message: diag.message}
+}
+
+func tokenRange(token Token) lspRange {
+	start := lspPosition{token.startLine - 1, token.column - 1}
+	return lspRange{start, lspPosition{start.Line, start.Character + len([]rune(token.lexeme))}}
+}
+
+// symbolAt returns the symbol at the supplied (0-based) position, if there is one
+func (a *documentAnalysis) symbolAt(pos lspPosition) (Token, *symbolDecl, bool) {
+	return a.symbols.symbolAt(pos.Line+1, pos.Character+1)
+}
+
+func (a *documentAnalysis) hover(pos lspPosition) *lspHover {
+	token, decl, ok := a.symbolAt(pos)
+	if !ok {
+		return nil
+	}
+
+	kind := decl.kind.String()
+	if decl.kind == symbolVariable {
+		if decl.global {
+			kind = "global variable"
+		} else {
+			kind = "local variable"
+		}
+	}
+	value := fmt.Sprintf("```lox\n%s\n```\n%s, declared on line %d", decl.detail, kind, decl.token.line)
+	return &lspHover{Contents: lspMarkupContent{"markdown", value}, Range: tokenRange(token)}
+}
+
+func (a *documentAnalysis) documentSymbols() []lspDocumentSymbol {
+	return declarationSymbols(a.statements, true)
+}
+
+// declarationSymbols converts the class, function and (if requested) variable
+// declarations in a list of statements into document symbols
+func declarationSymbols(statements []Stmt, includeVariables bool) []lspDocumentSymbol {
+	symbols := make([]lspDocumentSymbol, 0)
+	for _, stmt := range statements {
+		switch s := stmt.(type) {
+		case *ClassStmt:
+			methods := make([]lspDocumentSymbol, 0, len(s.fields)+len(s.methods)+len(s.classMethods))
+			for _, field := range s.fields {
+				methods = append(methods, lspDocumentSymbol{
+					Name:           field.variable.lexeme,
+					Detail:         "var " + field.variable.lexeme,
+					Kind:           lspSymbolKindField,
+					Range:          tokenRange(field.variable),
+					SelectionRange: tokenRange(field.variable),
+				})
+			}
+			for n, method := range slices.Concat(s.methods, s.classMethods) {
+				isClassMethod := n >= len(s.methods)
+				kind := lspSymbolKindMethod
+				if method.isGetter || method.isSetter {
+					kind = lspSymbolKindProperty
+				} else if method.functionName.lexeme == "init" && !isClassMethod {
+					kind = lspSymbolKindConstructor
+				}
+				detail := functionSignature(method)
+				if isClassMethod {
+					detail = "class " + detail
+				}
+				methods = append(methods, lspDocumentSymbol{
+					Name:           method.functionName.lexeme,
+					Detail:         detail,
+					Kind:           kind,
+					Range:          tokenRange(method.functionName),
+					SelectionRange: tokenRange(method.functionName),
+				})
+			}
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           s.className.lexeme,
+				Detail:         classSignature(s),
+				Kind:           lspSymbolKindClass,
+				Range:          tokenRange(s.className),
+				SelectionRange: tokenRange(s.className),
+				Children:       methods,
+			})
+		case *TraitStmt:
+			methods := make([]lspDocumentSymbol, 0, len(s.methods))
+			for _, method := range s.methods {
+				kind := lspSymbolKindMethod
+				if method.isGetter || method.isSetter {
+					kind = lspSymbolKindProperty
+				}
+				methods = append(methods, lspDocumentSymbol{
+					Name:           method.functionName.lexeme,
+					Detail:         functionSignature(method),
+					Kind:           kind,
+					Range:          tokenRange(method.functionName),
+					SelectionRange: tokenRange(method.functionName),
+				})
+			}
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           s.traitName.lexeme,
+				Detail:         "trait " + s.traitName.lexeme,
+				Kind:           lspSymbolKindInterface,
+				Range:          tokenRange(s.traitName),
+				SelectionRange: tokenRange(s.traitName),
+				Children:       methods,
+			})
+		case *FunctionStmt:
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           s.functionName.lexeme,
+				Detail:         functionSignature(s),
+				Kind:           lspSymbolKindFunction,
+				Range:          tokenRange(s.functionName),
+				SelectionRange: tokenRange(s.functionName),
+				Children:       declarationSymbols(s.body, false),
+			})
+		case *VarStmt:
+			if includeVariables {
+				symbols = append(symbols, lspDocumentSymbol{
+					Name:           s.variable.lexeme,
+					Kind:           lspSymbolKindVariable,
+					Range:          tokenRange(s.variable),
+					SelectionRange: tokenRange(s.variable),
+				})
+			}
+		}
+	}
+	return symbols
+}
+
+// completions returns the names that can be used at the supplied position in text,
+// the current contents of the document (which may not parse). After a '.', these are
+// the names of methods and fields of classes in the document; otherwise, they're the
+// variables in scope, plus keywords and native functions.
+func (a *documentAnalysis) completions(pos lspPosition, text string) []lspCompletionItem {
+	items := make([]lspCompletionItem, 0)
+	seen := make(map[string]bool)
+	add := func(item lspCompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if isAfterDot(text, pos) {
+		for _, property := range a.propertyNames() {
+			add(property)
+		}
+		return items
+	}
+
+	cursor := tokenPos{pos.Line + 1, pos.Character + 1}
+	for _, decl := range a.symbols.declarations {
+		if a.isVisibleAt(decl, cursor) {
+			kind := lspCompletionKindVariable
+			switch decl.kind {
+			case symbolFunction:
+				kind = lspCompletionKindFunction
+			case symbolClass:
+				kind = lspCompletionKindClass
+			case symbolTrait:
+				kind = lspCompletionKindInterface
+			}
+			add(lspCompletionItem{Label: decl.token.lexeme, Kind: kind, Detail: decl.detail})
+		}
+	}
+
+	natives := make([]string, 0)
+	for name := range NewInterpreter(NewDiagnosticCollector()).globalEnv.values {
+		natives = append(natives, name)
+	}
+	sort.Strings(natives)
+	for _, name := range natives {
+		add(lspCompletionItem{Label: name, Kind: lspCompletionKindFunction, Detail: "native function"})
+	}
+	keywords := make([]string, 0, len(reservedKeyWordMap))
+	for keyword := range reservedKeyWordMap {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		add(lspCompletionItem{Label: keyword, Kind: lspCompletionKindKeyword})
+	}
+	return items
+}
+
+// isAfterDot reports whether the identifier being typed at the supplied position
+// follows a '.' ie it's a property name
+func isAfterDot(text string, pos lspPosition) bool {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return false
+	}
+	line := []rune(lines[pos.Line])
+	i := min(pos.Character, len(line)) - 1
+	scanner := Scanner{}
+	for i >= 0 && scanner.isAlphaNumeric(line[i]) {
+		i--
+	}
+	return i >= 0 && line[i] == '.'
+}
+
+// isVisibleAt reports whether a declaration is in scope at the supplied position.
+// Globals are visible everywhere; locals are visible from their declaration to the
+// end of the enclosing block.
+func (a *documentAnalysis) isVisibleAt(decl *symbolDecl, cursor tokenPos) bool {
+	if decl.global {
+		return true
+	}
+	if !isBefore(positionOf(decl.token), cursor) {
+		return false
+	}
+
+	open, close, ok := a.enclosingBlock(decl)
+	if !ok {
+		return true
+	}
+	return isBefore(positionOf(open), cursor) && isBefore(cursor, positionOf(close))
+}
+
+// enclosingBlock returns the braces delimiting the scope of a local declaration. For
+// parameters, this is the body of the function.
+func (a *documentAnalysis) enclosingBlock(decl *symbolDecl) (Token, Token, bool) {
+	index := -1
+	for i, token := range a.tokens {
+		if positionOf(token) == positionOf(decl.token) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return Token{}, Token{}, false
+	}
+
+	if decl.kind == symbolParameter {
+		for i := index; i < len(a.tokens); i++ {
+			if a.tokens[i].token_type == LEFT_BRACE {
+				return a.matchingBrace(i)
+			}
+		}
+		return Token{}, Token{}, false
+	}
+
+	// Walk backwards to find the innermost unclosed '{'
+	depth := 0
+	for i := index - 1; i >= 0; i-- {
+		switch a.tokens[i].token_type {
+		case RIGHT_BRACE:
+			depth++
+		case LEFT_BRACE:
+			if depth == 0 {
+				return a.matchingBrace(i)
+			}
+			depth--
+		}
+	}
+	return Token{}, Token{}, false
+}
+
+func (a *documentAnalysis) matchingBrace(open int) (Token, Token, bool) {
+	depth := 0
+	for i := open; i < len(a.tokens); i++ {
+		switch a.tokens[i].token_type {
+		case LEFT_BRACE:
+			depth++
+		case RIGHT_BRACE:
+			depth--
+			if depth == 0 {
+				return a.tokens[open], a.tokens[i], true
+			}
+		}
+	}
+	// Unclosed block (eg while the user is typing) extends to the end of the document
+	return a.tokens[open], a.tokens[len(a.tokens)-1], true
+}
+
+// propertyNames returns the names of all methods, and all fields assigned through
+// 'this', in the document
+func (a *documentAnalysis) propertyNames() []lspCompletionItem {
+	items := make([]lspCompletionItem, 0)
+	for _, stmt := range a.statements {
+		switch s := stmt.(type) {
+		case *ClassStmt:
+			for _, method := range slices.Concat(s.methods, s.classMethods) {
+				items = append(items, lspCompletionItem{
+					Label:  method.functionName.lexeme,
+					Kind:   lspCompletionKindMethod,
+					Detail: s.className.lexeme + "." + strings.TrimPrefix(functionSignature(method), "fun "),
+				})
+			}
+		case *TraitStmt:
+			for _, method := range s.methods {
+				items = append(items, lspCompletionItem{
+					Label:  method.functionName.lexeme,
+					Kind:   lspCompletionKindMethod,
+					Detail: s.traitName.lexeme + "." + strings.TrimPrefix(functionSignature(method), "fun "),
+				})
+			}
+		}
+	}
+	for i := 0; i+3 < len(a.tokens); i++ {
+		if a.tokens[i].token_type == THIS && a.tokens[i+1].token_type == DOT &&
+			a.tokens[i+2].token_type == IDENTIFIER && a.tokens[i+3].token_type == EQUAL {
+			items = append(items, lspCompletionItem{Label: a.tokens[i+2].lexeme, Kind: lspCompletionKindField, Detail: "field"})
+		}
+	}
+	return items
+}
+
+func isBefore(a tokenPos, b tokenPos) bool {
+	return a.line < b.line || (a.line == b.line && a.column < b.column)
+}
+
+// ----------------------------------------------------------------------------
+// Server
+// ----------------------------------------------------------------------------
+
+type lspDocument struct {
+	uri      string
+	analysis *documentAnalysis
+	// lastGood is the most recent analysis of the document that parsed, used for
+	// navigation while the user is in the middle of an edit
+	lastGood *documentAnalysis
+}
+
+// LspServer implements a Language Server Protocol server for Lox, communicating over
+// JSON-RPC
+type LspServer struct {
+	reader    *bufio.Reader
+	writer    io.Writer
+	writeLock sync.Mutex
+	documents map[string]*lspDocument
+	shutdown  bool
+}
+
+func NewLspServer(in io.Reader, out io.Writer) *LspServer {
+	return &LspServer{
+		reader:    bufio.NewReader(in),
+		writer:    out,
+		documents: make(map[string]*lspDocument),
+	}
+}
+
+// run processes messages until the client sends 'exit' or closes the connection,
+// returning the process exit code
+func (s *LspServer) run() int {
+	for {
+		body, err := readProtocolMessage(s.reader)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "glox lsp: %v\n", err)
+			}
+			return 1
+		}
+
+		var request lspRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			fmt.Fprintf(os.Stderr, "glox lsp: invalid message: %v\n", err)
+			continue
+		}
+
+		if request.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(request)
+	}
+}
+
+func (s *LspServer) handle(request lspRequest) {
+	var result any
+	var err *lspError
+
+	switch request.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // full document sync
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]any{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]any{"name": "glox"},
+		}
+	case "initialized":
+		return
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if json.Unmarshal(request.Params, &params) == nil {
+			s.updateDocument(params.TextDocument.URI, params.TextDocument.Text)
+		}
+		return
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if json.Unmarshal(request.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			// Full document sync, so the last change holds the whole document
+			s.updateDocument(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+		return
+	case "textDocument/didClose":
+		var params lspDidCloseParams
+		if json.Unmarshal(request.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.publishDiagnostics(params.TextDocument.URI, []lspDiagnostic{})
+		}
+		return
+	case "textDocument/definition":
+		result, err = s.withPosition(request, func(uri string, a *documentAnalysis, pos lspPosition) any {
+			if _, decl, ok := a.symbolAt(pos); ok {
+				return lspLocation{uri, tokenRange(decl.token)}
+			}
+			return nil
+		})
+	case "textDocument/references":
+		var params lspReferenceParams
+		if json.Unmarshal(request.Params, &params) != nil {
+			err = &lspError{lspInvalidParams, "invalid parameters"}
+			break
+		}
+		result, err = s.withPosition(request, func(uri string, a *documentAnalysis, pos lspPosition) any {
+			locations := make([]lspLocation, 0)
+			if _, decl, ok := a.symbolAt(pos); ok {
+				for _, token := range a.symbols.referencesTo(decl) {
+					if params.Context.IncludeDeclaration || positionOf(token) != positionOf(decl.token) {
+						locations = append(locations, lspLocation{uri, tokenRange(token)})
+					}
+				}
+			}
+			return locations
+		})
+	case "textDocument/hover":
+		result, err = s.withPosition(request, func(uri string, a *documentAnalysis, pos lspPosition) any {
+			if hover := a.hover(pos); hover != nil {
+				return hover
+			}
+			return nil
+		})
+	case "textDocument/documentSymbol":
+		result, err = s.withPosition(request, func(uri string, a *documentAnalysis, pos lspPosition) any {
+			return a.documentSymbols()
+		})
+	case "textDocument/completion":
+		result, err = s.withPosition(request, func(uri string, a *documentAnalysis, pos lspPosition) any {
+			return a.completions(pos, s.documents[uri].analysis.text)
+		})
+	default:
+		if request.ID == nil { // unknown notifications are ignored
+			return
+		}
+		err = &lspError{lspMethodNotFound, "method not supported: " + request.Method}
+	}
+
+	if request.ID == nil {
+		return
+	}
+	if err != nil {
+		s.send(lspErrorResponse{"2.0", request.ID, *err})
+	} else {
+		s.send(lspResponse{"2.0", request.ID, result})
+	}
+}
+
+// withPosition decodes the document and position from a request, and calls the
+// handler with the most recent analysis of the document that parsed successfully
+func (s *LspServer) withPosition(request lspRequest,
+	handler func(uri string, a *documentAnalysis, pos lspPosition) any) (any, *lspError) {
+
+	var params lspTextDocumentPositionParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return nil, &lspError{lspInvalidParams, "invalid parameters"}
+	}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	analysis := doc.lastGood
+	if analysis == nil {
+		analysis = doc.analysis
+	}
+	return handler(params.TextDocument.URI, analysis, params.Position), nil
+}
+
+func (s *LspServer) updateDocument(uri string, text string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &lspDocument{uri: uri}
+		s.documents[uri] = doc
+	}
+
+	doc.analysis = analyzeDocument(text, s.lintConfig(uri))
+	if doc.analysis.parsed {
+		doc.lastGood = doc.analysis
+	}
+	s.publishDiagnostics(uri, doc.analysis.diagnostics)
+}
+
+// lintConfig returns the lint rules that apply to a document, taken from the project
+// config file for the document's directory
+func (s *LspServer) lintConfig(uri string) *LintConfig {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		if config, err := loadProjectConfig(filepath.Dir(u.Path)); err == nil {
+			return config.lint
+		}
+	}
+	return nil
+}
+
+func (s *LspServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) {
+	s.send(lspNotification{"2.0", "textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	}})
+}
+
+func (s *LspServer) send(message any) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox lsp: %v\n", err)
+		return
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	writeProtocolMessage(s.writer, body)
+}
+
+// writeProtocolMessage writes a JSON message body to the client, preceded by its
+// headers
+func writeProtocolMessage(writer io.Writer, body []byte) {
+	fmt.Fprintf(writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readProtocolMessage reads a single message, consisting of headers followed by a JSON
+// body, from the client. The same framing is used by both the Language Server Protocol
+// and the Debug Adapter Protocol.
+func readProtocolMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %s", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message has no Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// lspCommand implements 'glox lsp', which runs a language server over stdio
+func lspCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: glox lsp")
+		return 64
+	}
+	return NewLspServer(os.Stdin, os.Stdout).run()
+}
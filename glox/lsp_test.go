@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// LANGUAGE SERVER TESTS
+// ============================================================================
+
+const lspTestProgram = `var greeting = "hi";
+class Greeter {
+    init(name) {
+        this.name = name;
+    }
+    greet() {
+        print greeting + this.name;
+    }
+}
+fun make(name) {
+    var g = Greeter(name);
+    return g;
+}
+make("bob").greet();
+`
+
+func TestLspDiagnostics(t *testing.T) {
+	t.Run("Clean document has no diagnostics", func(t *testing.T) {
+		analysis := analyzeDocument(lspTestProgram, nil)
+		assertEqual(t, 0, len(analysis.diagnostics), "Diagnostic count")
+	})
+
+	t.Run("Scanner error has its column", func(t *testing.T) {
+		analysis := analyzeDocument("var a = 1;\nvar b = @;", nil)
+		if len(analysis.diagnostics) == 0 {
+			t.Fatal("Expected diagnostics")
+		}
+		diag := analysis.diagnostics[0]
+		assertEqual(t, "Unexpected character", diag.Message, "Message")
+		assertEqual(t, lspPosition{1, 8}, diag.Range.Start, "Start")
+		assertEqual(t, lspSeverityError, diag.Severity, "Severity")
+	})
+
+	t.Run("Parse error covers the offending token", func(t *testing.T) {
+		analysis := analyzeDocument("print 1 +;", nil)
+		assertEqual(t, 1, len(analysis.diagnostics), "Diagnostic count")
+		assertEqual(t, lspRange{lspPosition{0, 9}, lspPosition{0, 10}}, analysis.diagnostics[0].Range, "Range")
+		assertEqual(t, false, analysis.parsed, "Parsed")
+	})
+
+	t.Run("Statements that parse are analyzed despite syntax errors", func(t *testing.T) {
+		analysis := analyzeDocument("fun f(a) { return a; }\nprint 1 +;\nvar x = f(1);\nprint y;", nil)
+		assertEqual(t, 1, len(analysis.diagnostics), "Diagnostic count")
+
+		// 'f' in 'f(1)'
+		_, decl, ok := analysis.symbolAt(lspPosition{2, 8})
+		if !ok {
+			t.Fatal("Expected a symbol")
+		}
+		assertEqual(t, 1, decl.token.line, "Declaration line")
+		assertEqual(t, 2, len(analysis.documentSymbols()), "Document symbols")
+		if analysis.hover(lspPosition{2, 8}) == nil {
+			t.Error("Expected hover")
+		}
+	})
+
+	t.Run("Lint warnings have warning severity", func(t *testing.T) {
+		analysis := analyzeDocument("fun f(unused) {}", nil)
+		assertEqual(t, 1, len(analysis.diagnostics), "Diagnostic count")
+		assertEqual(t, lspSeverityWarning, analysis.diagnostics[0].Severity, "Severity")
+		assertContains(t, analysis.diagnostics[0].Message, "unused-parameter")
+	})
+
+	t.Run("Lint config is respected", func(t *testing.T) {
+		config := NewLintConfig()
+		assertNoError(t, config.setSeverity("unused-parameter", "off"), "Set severity")
+		analysis := analyzeDocument("fun f(unused) {}", config)
+		assertEqual(t, 0, len(analysis.diagnostics), "Diagnostic count")
+	})
+}
+
+func TestLspNavigation(t *testing.T) {
+	analysis := analyzeDocument(lspTestProgram, nil)
+
+	t.Run("Definition of a local variable", func(t *testing.T) {
+		// 'g' in 'return g;'
+		_, decl, ok := analysis.symbolAt(lspPosition{11, 11})
+		if !ok {
+			t.Fatal("Expected a symbol")
+		}
+		assertEqual(t, 11, decl.token.line, "Declaration line")
+		assertEqual(t, 9, decl.token.column, "Declaration column")
+	})
+
+	t.Run("Definition of a global referenced inside a method", func(t *testing.T) {
+		// 'greeting' in 'print greeting + this.name;'
+		_, decl, ok := analysis.symbolAt(lspPosition{6, 16})
+		if !ok {
+			t.Fatal("Expected a symbol")
+		}
+		assertEqual(t, 1, decl.token.line, "Declaration line")
+		assertEqual(t, true, decl.global, "Global")
+	})
+
+	t.Run("Parameters and locals with the same name are distinguished", func(t *testing.T) {
+		// 'name' in 'Greeter(name)' refers to the parameter of make
+		_, decl, ok := analysis.symbolAt(lspPosition{10, 20})
+		if !ok {
+			t.Fatal("Expected a symbol")
+		}
+		assertEqual(t, symbolParameter, decl.kind, "Kind")
+		assertEqual(t, 10, decl.token.line, "Declaration line")
+	})
+
+	t.Run("References include the declaration", func(t *testing.T) {
+		_, decl, _ := analysis.symbolAt(lspPosition{9, 5}) // 'make'
+		references := analysis.symbols.referencesTo(decl)
+		assertEqual(t, 2, len(references), "Reference count")
+		assertEqual(t, 10, references[0].line, "Declaration")
+		assertEqual(t, 14, references[1].line, "Call")
+	})
+
+	t.Run("No symbol at whitespace", func(t *testing.T) {
+		_, _, ok := analysis.symbolAt(lspPosition{5, 0})
+		assertEqual(t, false, ok, "Symbol found")
+	})
+
+	t.Run("Hover shows the declaration kind", func(t *testing.T) {
+		hover := analysis.hover(lspPosition{13, 1})
+		if hover == nil {
+			t.Fatal("Expected hover")
+		}
+		assertContains(t, hover.Contents.Value, "fun make(name)")
+		assertContains(t, hover.Contents.Value, "function")
+
+		hover = analysis.hover(lspPosition{11, 11})
+		assertContains(t, hover.Contents.Value, "local variable")
+	})
+}
+
+func TestLspDocumentSymbols(t *testing.T) {
+	analysis := analyzeDocument(lspTestProgram, nil)
+	symbols := analysis.documentSymbols()
+
+	names := make([]string, 0)
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	assertEqual(t, "greeting,Greeter,make", strings.Join(names, ","), "Top-level symbols")
+
+	class := symbols[1]
+	assertEqual(t, lspSymbolKindClass, class.Kind, "Class kind")
+	assertEqual(t, 2, len(class.Children), "Method count")
+	assertEqual(t, lspSymbolKindConstructor, class.Children[0].Kind, "init kind")
+	assertEqual(t, "greet", class.Children[1].Name, "Method name")
+	assertEqual(t, "fun make(name)", symbols[2].Detail, "Function detail")
+}
+
+func TestLspCompletion(t *testing.T) {
+	labels := func(items []lspCompletionItem) map[string]bool {
+		result := make(map[string]bool)
+		for _, item := range items {
+			result[item.Label] = true
+		}
+		return result
+	}
+
+	analysis := analyzeDocument(lspTestProgram, nil)
+
+	t.Run("Names in scope", func(t *testing.T) {
+		// Inside make, after 'var g' is declared
+		items := labels(analysis.completions(lspPosition{11, 4}, analysis.text))
+		for _, name := range []string{"g", "name", "make", "Greeter", "greeting", "clock", "while"} {
+			if !items[name] {
+				t.Errorf("Expected completion %q", name)
+			}
+		}
+	})
+
+	t.Run("Native functions are in a stable order", func(t *testing.T) {
+		natives := make([]string, 0)
+		for _, item := range analysis.completions(lspPosition{13, 0}, analysis.text) {
+			if item.Detail == "native function" {
+				natives = append(natives, item.Label)
+			}
+		}
+		if len(natives) == 0 || !sort.StringsAreSorted(natives) {
+			t.Errorf("Expected sorted native functions, got %v", natives)
+		}
+	})
+
+	t.Run("Locals are out of scope outside their block", func(t *testing.T) {
+		items := labels(analysis.completions(lspPosition{13, 0}, analysis.text))
+		assertEqual(t, false, items["g"], "Local g")
+		assertEqual(t, true, items["make"], "Global make")
+	})
+
+	t.Run("Locals are out of scope before their declaration", func(t *testing.T) {
+		items := labels(analysis.completions(lspPosition{10, 4}, analysis.text))
+		assertEqual(t, false, items["g"], "Local g")
+		assertEqual(t, true, items["name"], "Parameter name")
+	})
+
+	t.Run("Properties after a dot", func(t *testing.T) {
+		// The document being edited doesn't parse, so names come from the last good
+		// analysis
+		text := lspTestProgram + "make(\"x\").gr"
+		items := labels(analysis.completions(lspPosition{14, 12}, text))
+		assertEqual(t, true, items["greet"], "Method")
+		assertEqual(t, true, items["name"], "Field")
+		assertEqual(t, false, items["while"], "Keyword")
+	})
+}
+
+func TestLspServer(t *testing.T) {
+	uri := "file:///tmp/lsp_test/test.lox"
+	var input bytes.Buffer
+	send := func(id int, method string, params any) {
+		message := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+		if id > 0 {
+			message["id"] = id
+		}
+		body, _ := json.Marshal(message)
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	send(1, "initialize", map[string]any{})
+	send(0, "initialized", map[string]any{})
+	send(0, "textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "lox", "version": 1, "text": lspTestProgram},
+	})
+	send(2, "textDocument/definition", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 13, "character": 1},
+	})
+	send(0, "textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": lspTestProgram + "print ;"}},
+	})
+	send(3, "textDocument/references", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 5},
+		"context":      map[string]any{"includeDeclaration": true},
+	})
+	send(4, "workspace/symbol", map[string]any{})
+	send(5, "shutdown", nil)
+	send(0, "exit", nil)
+
+	var output bytes.Buffer
+	server := NewLspServer(&input, &output)
+	code := server.run()
+	assertEqual(t, 0, code, "Exit code")
+
+	messages := make([]map[string]any, 0)
+	reader := bufio.NewReader(&output)
+	for {
+		body, err := readProtocolMessage(reader)
+		if err != nil {
+			break
+		}
+		var message map[string]any
+		assertNoError(t, json.Unmarshal(body, &message), "Decode message")
+		messages = append(messages, message)
+	}
+	assertEqual(t, 7, len(messages), "Message count")
+
+	capabilities := messages[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	assertEqual(t, true, capabilities["definitionProvider"], "Definition capability")
+
+	assertEqual(t, "textDocument/publishDiagnostics", messages[1]["method"], "Diagnostics on open")
+	diagnostics := messages[1]["params"].(map[string]any)["diagnostics"].([]any)
+	assertEqual(t, 0, len(diagnostics), "Diagnostics on open")
+
+	location := messages[2]["result"].(map[string]any)
+	assertEqual(t, uri, location["uri"], "Definition uri")
+	start := location["range"].(map[string]any)["start"].(map[string]any)
+	assertEqual(t, float64(9), start["line"], "Definition line")
+	assertEqual(t, float64(4), start["character"], "Definition character")
+
+	diagnostics = messages[3]["params"].(map[string]any)["diagnostics"].([]any)
+	assertEqual(t, 1, len(diagnostics), "Diagnostics on change")
+
+	// Navigation still works using the last analysis that parsed
+	references := messages[4]["result"].([]any)
+	assertEqual(t, 2, len(references), "Reference count")
+
+	errorObject := messages[5]["error"].(map[string]any)
+	assertEqual(t, float64(lspMethodNotFound), errorObject["code"], "Unknown method error")
+	if _, hasResult := messages[5]["result"]; hasResult {
+		t.Error("Error response should not have a result")
+	}
+
+	if result, hasResult := messages[6]["result"]; !hasResult || result != nil {
+		t.Errorf("Expected null result for shutdown, got %v", messages[6])
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import "fmt"
+
+// Classes can overload operators by defining methods with special names: the
+// operators' method is called when the left (or only) operand is an instance of the
+// class. The comparison operators are all derived from __lt and __eq, with equality
+// falling back on identity if the class has no __eq. Equality is symmetric, so __eq
+// is also called when only the right operand is an instance.
+
+var operatorMethodNames = map[TokenType]string{
+	PLUS:  "__add",
+	MINUS: "__sub",
+	STAR:  "__mul",
+	SLASH: "__div",
+}
+
+// callOperatorMethod calls an instance's method for an operator. use describes what
+// the method is for eg "'+'", for the error if the class doesn't define it.
+func (i *Interpreter) callOperatorMethod(token Token, use string, instance *LoxInstance, name string, arguments ...any) (any, error) {
+	method := instance.class.findMethod(name)
+	if method == nil {
+		return nil, RuntimeError{token, missingOperatorMethod(instance, name, use)}
+	}
+	if method.arity() != len(arguments) {
+		return nil, RuntimeError{token, fmt.Sprintf("%s.%s must take %d arguments but takes %d.",
+			instance.class.name, name, len(arguments), method.arity())}
+	}
+	return method.bindThis(instance).call(i, arguments)
+}
+
+func missingOperatorMethod(instance *LoxInstance, name string, use string) string {
+	return fmt.Sprintf("%s instance has no %s method, so it doesn't support %s.", instance.class.name, name, use)
+}
+
+// binaryOperator applies a binary operator whose left operand is an instance
+func (i *Interpreter) binaryOperator(operator Token, left *LoxInstance, right any) (any, error) {
+	use := "'" + operator.lexeme + "'"
+	switch operator.token_type {
+	case EQUAL_EQUAL:
+		return i.instanceEquals(operator, left, right)
+	case BANG_EQUAL:
+		equal, err := i.instanceEquals(operator, left, right)
+		return !equal, err
+	case LESS:
+		return i.instanceLess(operator, use, left, right)
+	case GREATER_EQUAL:
+		less, err := i.instanceLess(operator, use, left, right)
+		return !less, err
+	case GREATER, LESS_EQUAL:
+		less, err := i.instanceLess(operator, use, left, right)
+		if err != nil {
+			return nil, err
+		}
+		equal, err := i.instanceEquals(operator, left, right)
+		if err != nil {
+			return nil, err
+		}
+		if operator.token_type == GREATER {
+			return !less && !equal, nil
+		}
+		return less || equal, nil
+	}
+	return i.callOperatorMethod(operator, use, left, operatorMethodNames[operator.token_type], right)
+}
+
+// rightOperandOperator applies a binary operator whose right operand is an instance,
+// but whose left operand isn't
+func (i *Interpreter) rightOperandOperator(operator Token, left any, right *LoxInstance) (any, error) {
+	switch operator.token_type {
+	case EQUAL_EQUAL:
+		return i.instanceEquals(operator, right, left)
+	case BANG_EQUAL:
+		equal, err := i.instanceEquals(operator, right, left)
+		return !equal, err
+	}
+	return nil, RuntimeError{operator, fmt.Sprintf("%s instance can't be the right operand of '%s', as operator methods are only called on the left operand.",
+		right.class.name, operator.lexeme)}
+}
+
+func (i *Interpreter) instanceLess(operator Token, use string, left *LoxInstance, right any) (bool, error) {
+	result, err := i.callOperatorMethod(operator, use, left, "__lt", right)
+	return isTruthy(result), err
+}
+
+func (i *Interpreter) instanceEquals(operator Token, left *LoxInstance, right any) (bool, error) {
+	if left.class.findMethod("__eq") == nil {
+		return right == left, nil
+	}
+	result, err := i.callOperatorMethod(operator, "'=='", left, "__eq", right)
+	return isTruthy(result), err
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *IndexExpr) (any, error) {
+	object, err := i.evaluate(expr.object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.index)
+	if err != nil {
+		return nil, err
+	}
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		return nil, RuntimeError{expr.bracket, "Only instances can be indexed."}
+	}
+	return i.callOperatorMethod(expr.bracket, "indexing", instance, "__index", index)
+}
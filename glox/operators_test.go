@@ -0,0 +1,215 @@
+package main
+
+import "testing"
+
+// ============================================================================
+// OPERATOR OVERLOADING TESTS
+// ============================================================================
+
+const vecClass = `
+class Vec {
+  init(x, y) { this.x = x; this.y = y; }
+  __add(other) { return Vec(this.x + other.x, this.y + other.y); }
+  __sub(other) { return Vec(this.x - other.x, this.y - other.y); }
+  __mul(k) { return Vec(this.x * k, this.y * k); }
+  __div(k) { return Vec(this.x / k, this.y / k); }
+  __neg() { return Vec(-this.x, -this.y); }
+  __eq(other) { return other is Vec and this.x == other.x and this.y == other.y; }
+  __lt(other) { return this.length() < other.length(); }
+  __index(i) { if (i == 0) return this.x; return this.y; }
+  length() { return this.x * this.x + this.y * this.y; }
+}
+`
+
+func TestOperatorOverloading(t *testing.T) {
+	t.Run("Arithmetic operators", func(t *testing.T) {
+		program := vecClass + `
+var v = Vec(1, 2) + Vec(3, 4);
+print v.x; print v.y;
+v = v - Vec(1, 1);
+print v.x; print v.y;
+v = v * 3 / 2;
+print v.x; print v.y;
+v = -v;
+print v.x; print v.y;
+`
+		expected := []string{"4", "6", "3", "5", "4.5", "7.5", "-4.5", "-7.5"}
+		runProgramAndCheckOutput(t, program, expected, "Arithmetic operators")
+	})
+
+	t.Run("Comparison operators", func(t *testing.T) {
+		program := vecClass + `
+var small = Vec(1, 1);
+var big = Vec(2, 2);
+print small < big;
+print small > big;
+print small <= Vec(1, 1);
+print big >= small;
+print small == Vec(1, 1);
+print small != Vec(1, 1);
+print small == 1;
+`
+		expected := []string{"true", "false", "true", "true", "true", "false", "false"}
+		runProgramAndCheckOutput(t, program, expected, "Comparison operators")
+	})
+
+	t.Run("Indexing", func(t *testing.T) {
+		program := vecClass + `
+var v = Vec(7, 8);
+print v[0];
+print v[1 + 0];
+print (v + v)[1];
+`
+		expected := []string{"7", "8", "16"}
+		runProgramAndCheckOutput(t, program, expected, "Indexing")
+	})
+
+	t.Run("Equality is symmetric", func(t *testing.T) {
+		program := `
+class V {
+  __eq(other) { return true; }
+}
+print V() == nil;
+print nil == V();
+print nil != V();
+print 1 == V();
+`
+		expected := []string{"true", "true", "false", "true"}
+		runProgramAndCheckOutput(t, program, expected, "Equality is symmetric")
+	})
+
+	t.Run("Calling instances", func(t *testing.T) {
+		program := `
+class Adder {
+  init(n) { this.n = n; }
+  __call(x) { return x + this.n; }
+}
+var add2 = Adder(2);
+print add2(3);
+print Adder(10)(5);
+`
+		expected := []string{"5", "15"}
+		runProgramAndCheckOutput(t, program, expected, "Calling instances")
+	})
+
+	t.Run("Instances are callable values", func(t *testing.T) {
+		program := `
+class Twice {
+  __call(f, x) { return f(f(x)); }
+}
+fun inc(x) { return x + 1; }
+fun apply(fn, a, b) { return fn(a, b); }
+var twice = Twice();
+print apply(twice, inc, 1);
+print arity(twice);
+print type(twice);
+`
+		expected := []string{"3", "2", "instance"}
+		runProgramAndCheckOutput(t, program, expected, "Instances are callable values")
+	})
+
+	t.Run("Inherited operator methods", func(t *testing.T) {
+		program := vecClass + `
+class NamedVec < Vec {}
+var v = NamedVec(1, 2) + Vec(1, 1);
+print v.x;
+print NamedVec(1, 2) == Vec(1, 2);
+`
+		expected := []string{"2", "true"}
+		runProgramAndCheckOutput(t, program, expected, "Inherited operator methods")
+	})
+
+	t.Run("Equality without __eq is identity", func(t *testing.T) {
+		program := `
+class Thing {}
+var a = Thing();
+var b = a;
+print a == b;
+print a != b;
+class D { init() { this.v = 1; } }
+print D() == D();
+print D() != D();
+`
+		expected := []string{"true", "false", "false", "true"}
+		runProgramAndCheckOutput(t, program, expected, "Equality without __eq is identity")
+	})
+}
+
+func TestOperatorOverloadingErrors(t *testing.T) {
+	t.Run("Missing operator method", func(t *testing.T) {
+		program := `
+class Thing {}
+Thing() + 1;
+`
+		runProgramAndExpectError(t, program, "Thing instance has no __add method, so it doesn't support '+'.", "Missing operator method")
+	})
+
+	t.Run("Missing comparison method", func(t *testing.T) {
+		program := `
+class Thing {}
+Thing() < Thing();
+`
+		runProgramAndExpectError(t, program, "Thing instance has no __lt method, so it doesn't support '<'.", "Missing comparison method")
+	})
+
+	t.Run("Instance as the right operand", func(t *testing.T) {
+		program := `
+class V {
+  __add(other) { return 1; }
+}
+1 + V();
+`
+		runProgramAndExpectError(t, program, "V instance can't be the right operand of '+', as operator methods are only called on the left operand.", "Instance as the right operand")
+	})
+
+	t.Run("Indexing an instance without __index", func(t *testing.T) {
+		program := `
+class Thing {}
+Thing()[0];
+`
+		runProgramAndExpectError(t, program, "Thing instance has no __index method, so it doesn't support indexing.", "Indexing an instance without __index")
+	})
+
+	t.Run("Indexing a value that isn't an instance", func(t *testing.T) {
+		program := `
+var s = "abc";
+s[0];
+`
+		runProgramAndExpectError(t, program, "Only instances can be indexed.", "Indexing a value that isn't an instance")
+	})
+
+	t.Run("Calling an instance without __call", func(t *testing.T) {
+		program := `
+class Thing {}
+Thing()();
+`
+		runProgramAndExpectError(t, program, "Thing instance has no __call method, so it doesn't support calls.", "Calling an instance without __call")
+	})
+
+	t.Run("Calling an instance with the wrong number of arguments", func(t *testing.T) {
+		program := `
+class Thing {
+  __call(x) { return x; }
+}
+Thing()(1, 2);
+`
+		runProgramAndExpectError(t, program, "Expected 1 arguments but got 2", "Calling an instance with the wrong number of arguments")
+	})
+
+	t.Run("Operator method with the wrong arity", func(t *testing.T) {
+		program := `
+class Thing {
+  __add() { return 1; }
+}
+Thing() + 1;
+`
+		runProgramAndExpectError(t, program, "Thing.__add must take 1 arguments but takes 0.", "Operator method with the wrong arity")
+	})
+
+	t.Run("Unterminated index", func(t *testing.T) {
+		program := `
+var x = a[1;
+`
+		runProgramAndExpectError(t, program, "Expect ']' after index.", "Unterminated index")
+	})
+}
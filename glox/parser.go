@@ -2,15 +2,19 @@ package main
 
 import (
 	"fmt"
+	"slices"
 )
 
 // Parser implements a recursive descent parser for the following grammar. Note that
 // this is (roughly) in order of *increasing* precedence.
 //
 // program        → declaration* EOF;
-// declaration    → classDecl | funDecl | varDecl | statement ;
-// classDecl      → "class" IDENTIFIER ( "<" IDENTIFIER)? "(" function* ")" ;
+// declaration    → classDecl | traitDecl | funDecl | varDecl | statement ;
+// classDecl      → ( "sealed" | "abstract" )* "class" IDENTIFIER ( "<" IDENTIFIER)? ( "with" IDENTIFIER ( "," IDENTIFIER )* )?
+//                  "(" ( "class"? method | varDecl )* ")" ;
+// traitDecl      → "trait" IDENTIFIER "{" method* "}" ;
 // funDecl        → "fun" function;
+// method         → "abstract" "set"? functionHeader ";" | "set"? function ;
 // function       → IDENTIFIER ("(" parameters? ")")? block ;
 // parameters     → IDENTIFIER ("," IDENTIFIER)* ;
 // varDecl        → "var" IDENTIFIER ("=" expression)? ";" ;
@@ -29,11 +33,11 @@ import (
 // logic_or       → logic_and ( "or" logic_and )* ;
 // logic_and      → equality ( "and" equality )* ;
 // equality       → comparison ( ( "!=" | "==" ) comparison )*;
-// comparison     → term ( ( ">" | ">=" | "<" | "<=" ) term )*;
+// comparison     → term ( ( ">" | ">=" | "<" | "<=" | "is" ) term )*;
 // term           → factor ( ( "-" | "+" ) factor )*;
 // factor         → unary ( ( "/" | "*" ) unary )*;
 // unary          → ( "!" | "-" ) unary | | call
-// call           → primary ( "(" arguments? ")" | "." IDENTIFIER )*;
+// call           → ( primary | "inner" "(" arguments? ")" ) ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )*;
 // arguments      → expression ( "," expression )* ;
 // primary        → "true" | "false" | "nil" | "this" | NUMBER | STRING | "(" expression ")" | IDENTIFIER | "super" "." IDENTIFIER
 //
@@ -45,11 +49,26 @@ import (
 // 4. Factor: *, /
 // 5. Unary: !, -
 // 6. Primary: literals, grouping
+//
+// 'set' and 'abstract' are only special before a method name, 'sealed' and 'abstract'
+// before 'class', 'trait' before a trait name and 'with' after a class's name or
+// superclass, so they can still be used as names.
 
 type Parser struct {
 	lox     LoxRuntime
 	tokens  []Token
 	current int
+	// stmtLines maps each statement to the line it starts on, for use by tools such
+	// as the debugger
+	stmtLines map[Stmt]int
+	// stmtEndLines maps each statement to the line it ends on, so that lint directives
+	// can apply to whole statements
+	stmtEndLines map[Stmt]int
+	// inClassBody is set while parsing the body of a class or trait, where 'inner'
+	// followed by arguments calls the refinement of the current method
+	inClassBody bool
+	// usesInner is set when inner() is called in the function being parsed
+	usesInner bool
 }
 
 func NewParser(lox LoxRuntime, tokens []Token) *Parser {
@@ -57,6 +76,8 @@ func NewParser(lox LoxRuntime, tokens []Token) *Parser {
 		lox:     lox,
 		tokens:  append([]Token(nil), tokens...),
 		current: 0,
+		stmtLines: make(map[Stmt]int),
+		stmtEndLines: make(map[Stmt]int),
 	}
 }
 
@@ -73,13 +94,36 @@ func (p *Parser) parse() ([]Stmt, error) {
 	return statements, nil
 }
 
+// parseRecovering parses as many statements as it can, skipping those with syntax
+// errors, for tools that work with code that's in the middle of being edited
+func (p *Parser) parseRecovering() []Stmt {
+	statements := make([]Stmt, 0)
+	for !p.isAtEnd() {
+		if stmt, err := p.declaration(); err == nil {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
+
 // declaration -> classDecl | funDecl | varDecl | statement
 func (p *Parser) declaration() (Stmt, error) {
 	var stmt Stmt
 	var err error
 
-	if p.matches(CLASS) {
+	line := p.peek().line
+	if modifiers := p.classModifiers(); modifiers != nil {
+		p.current += len(modifiers) + 1
+		stmt, err = p.classDeclaration()
+		if class, ok := stmt.(*ClassStmt); ok {
+			class.sealed = slices.Contains(modifiers, "sealed")
+			class.isAbstract = slices.Contains(modifiers, "abstract")
+		}
+	} else if p.matches(CLASS) {
 		stmt, err = p.classDeclaration()
+	} else if p.isTrait() {
+		p.advance()
+		stmt, err = p.traitDeclaration()
 	} else if p.matches(FUN) {
 		stmt, err = p.function("function")
 	} else if p.matches(VAR) {
@@ -95,14 +139,70 @@ func (p *Parser) declaration() (Stmt, error) {
 		return nil, err
 	}
 
+	p.stmtLines[stmt] = line
+	p.stmtEndLines[stmt] = p.previous().line
 	return stmt, nil
 }
 
-// class → "class" IDENTIFIER ("<" IDENTIFIER )? "{" function* "}";
+// classModifiers returns the modifiers, such as 'sealed', that start a class
+// declaration, or nil if the next tokens aren't a class declaration with modifiers
+func (p *Parser) classModifiers() []string {
+	modifiers := make([]string, 0)
+	for n := p.current; n < len(p.tokens); n++ {
+		token := p.tokens[n]
+		if token.token_type == CLASS && len(modifiers) > 0 {
+			return modifiers
+		}
+		if token.token_type != IDENTIFIER || (token.lexeme != "sealed" && token.lexeme != "abstract") ||
+			slices.Contains(modifiers, token.lexeme) {
+			return nil
+		}
+		modifiers = append(modifiers, token.lexeme)
+	}
+	return nil
+}
+
+// isTrait reports whether the next tokens start a trait declaration
+func (p *Parser) isTrait() bool {
+	return p.nextTokenTypeIs(IDENTIFIER) && p.peek().lexeme == "trait" &&
+		p.current+1 < len(p.tokens) && p.tokens[p.current+1].token_type == IDENTIFIER
+}
+
+// trait → "trait" IDENTIFIER "{" method* "}" ;
+func (p *Parser) traitDeclaration() (Stmt, error) {
+	traitName, err := p.consume(IDENTIFIER, "Expect trait name")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LEFT_BRACE, "Expect '{' after trait name"); err != nil {
+		return nil, err
+	}
+	enclosingClassBody := p.inClassBody
+	p.inClassBody = true
+	defer func() { p.inClassBody = enclosingClassBody }()
+
+	methods := make([]*FunctionStmt, 0)
+	for !p.nextTokenTypeIs(RIGHT_BRACE) && !p.isAtEnd() {
+		method, err := p.method("method")
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	if _, err := p.consume(RIGHT_BRACE, "Expect '}' after trait body"); err != nil {
+		return nil, err
+	}
+	return &TraitStmt{traitName: traitName, methods: methods}, nil
+}
+
+// class → "class" IDENTIFIER ("<" IDENTIFIER )? "{" ("class"? method | varDecl)* "}";
 func (p *Parser) classDeclaration() (Stmt, error) {
 	var err error
 	var className Token
 	methods := make([]*FunctionStmt, 0)
+	classMethods := make([]*FunctionStmt, 0)
+	fields := make([]*VarStmt, 0)
 
 	if className, err = p.consume(IDENTIFIER, "Expect class name"); err != nil {
 		return nil, err
@@ -117,30 +217,131 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 		superclass = &VariableExpr{p.previous() }
 	}
 
+	// Parse traits, if there are any
+	traits := make([]*VariableExpr, 0)
+	if p.nextTokenTypeIs(IDENTIFIER) && p.peek().lexeme == "with" {
+		p.advance()
+		for {
+			if _, err = p.consume(IDENTIFIER, "Expect trait name"); err != nil {
+				return nil, err
+			}
+			traits = append(traits, &VariableExpr{p.previous()})
+			if !p.matches(COMMA) {
+				break
+			}
+		}
+	}
+
 
 	// Parse class methods
 	if _, err := p.consume(LEFT_BRACE, "Expect '{' after class name"); err != nil {
 		return nil, err
 	}
+	enclosingClassBody := p.inClassBody
+	p.inClassBody = true
+	defer func() { p.inClassBody = enclosingClassBody }()
 
 	for !p.nextTokenTypeIs(RIGHT_BRACE) && !p.isAtEnd() {
+		// Field declarations look like variable declarations
+		if p.matches(VAR) {
+			field, err := p.varDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field.(*VarStmt))
+			continue
+		}
+
 		var method *FunctionStmt
-		if method, err = p.function("method"); err != nil {
+		// Methods prefixed with 'class' belong to the class rather than its instances
+		isClassMethod := p.matches(CLASS)
+		kind := "method"
+		if isClassMethod {
+			kind = "class method"
+		}
+		if method, err = p.method(kind); err != nil {
 			return nil, err
 		}
-		methods = append(methods, method)
+		if isClassMethod {
+			classMethods = append(classMethods, method)
+		} else {
+			methods = append(methods, method)
+		}
 	}
 
 	if _, err := p.consume(RIGHT_BRACE, "Expect '}' after class body"); err != nil {
 		return nil, err
 	}
 
-	return &ClassStmt{className: className, superclass: superclass, methods: methods}, nil
+	return &ClassStmt{className: className, superclass: superclass, traits: traits, methods: methods,
+		classMethods: classMethods, fields: fields}, nil
 }
 
-// function       → IDENTIFIER ("(" parameters? ")")? block ;
-// parameters     → IDENTIFIER ("," IDENTIFIER)* ;
+// method → "abstract" "set"? functionHeader ";" | "set"? function ;
+func (p *Parser) method(kind string) (*FunctionStmt, error) {
+	isAbstract := p.isModifier("abstract")
+	if isAbstract {
+		p.advance()
+	}
+
+	isSetter := p.isModifier("set")
+	if isSetter {
+		p.advance()
+		if !p.nextTokenTypeIs(IDENTIFIER) || p.current+1 >= len(p.tokens) || p.tokens[p.current+1].token_type != LEFT_PAREN {
+			return nil, p.constructError(p.peek(), "Expect '(' after setter name.")
+		}
+		kind = "setter"
+	}
+
+	var method *FunctionStmt
+	var err error
+	if isAbstract {
+		// An abstract method has no body
+		if method, err = p.functionHeader(kind); err != nil {
+			return nil, err
+		}
+		if _, err = p.consume(SEMICOLON, "Expect ';' after abstract "+kind+" declaration."); err != nil {
+			return nil, err
+		}
+	} else if method, err = p.function(kind); err != nil {
+		return nil, err
+	}
+	method.isSetter = isSetter
+	method.isAbstract = isAbstract
+	return method, nil
+}
+
+// isModifier reports whether the next token is the supplied word, used to modify
+// the method name that follows it
+func (p *Parser) isModifier(word string) bool {
+	return p.nextTokenTypeIs(IDENTIFIER) && p.peek().lexeme == word &&
+		p.current+1 < len(p.tokens) && p.tokens[p.current+1].token_type == IDENTIFIER
+}
+
+// function       → functionHeader block ;
 func (p *Parser) function(kind string) (*FunctionStmt, error) {
+	function, err := p.functionHeader(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse function body
+	if _, err = p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body."); err != nil {
+		return nil, err
+	}
+	enclosingUsesInner := p.usesInner
+	p.usesInner = false
+	defer func() { p.usesInner = enclosingUsesInner }()
+	if function.body, err = p.blockStatement(); err != nil {
+		return nil, err
+	}
+	function.usesInner = p.usesInner
+	return function, nil
+}
+
+// functionHeader → IDENTIFIER ("(" parameters? ")")? ;
+// parameters     → IDENTIFIER ("," IDENTIFIER)* ;
+func (p *Parser) functionHeader(kind string) (*FunctionStmt, error) {
 	var err error
 	var fnName Token
 	var fnParams []Token
@@ -190,17 +391,7 @@ func (p *Parser) function(kind string) (*FunctionStmt, error) {
 		return nil, p.constructError(p.previous(),"init function must have parameter list")
 	}
 
-	// Parse function body
-	if _, err = p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body."); err != nil {
-		return nil, err
-	}
-
-	var fnBody []Stmt
-	if fnBody, err = p.blockStatement(); err != nil {
-		return nil, err
-	}
-
-	return &FunctionStmt{fnName, isGetter, fnParams, fnBody}, nil
+	return &FunctionStmt{functionName: fnName, isGetter: isGetter, params: fnParams}, nil
 }
 
 // varDecl → "var" IDENTIFIER ("=" expression)? ";" ;
@@ -227,8 +418,19 @@ func (p *Parser) varDeclaration() (Stmt, error) {
 	return &VarStmt{varName, initExpression}, nil
 }
 
-// statemenent → printStmt | ifStmt | exprStmt | block
+// statement parses any statement other than a declaration, recording the line it
+// starts on
 func (p *Parser) statement() (Stmt, error) {
+	line := p.peek().line
+	stmt, err := p.nonDeclarationStatement()
+	if err == nil {
+		p.stmtLines[stmt] = line
+		p.stmtEndLines[stmt] = p.previous().line
+	}
+	return stmt, err
+}
+
+func (p *Parser) nonDeclarationStatement() (Stmt, error) {
 	if p.matches(IF) {
 		return p.ifStatement()
 	}
@@ -351,6 +553,7 @@ func (p *Parser) forStatement() (Stmt, error) {
 
 	// 'for' keyword has already been consumed, so start parsing what's supposed to come
 	// next
+	forLine := p.previous().line
 	if _, err = p.consume(LEFT_PAREN, "Expect '(' after 'for'"); err != nil {
 		return nil, err
 	}
@@ -362,10 +565,12 @@ func (p *Parser) forStatement() (Stmt, error) {
 		if loopVarInit, err = p.varDeclaration(); err != nil {
 			return nil, err
 		}
+		p.stmtLines[loopVarInit] = forLine
 	} else {
 		if loopVarInit, err = p.expressionStatement(); err != nil {
 			return nil, err
 		}
+		p.stmtLines[loopVarInit] = forLine
 	}
 
 	// Parse the loop condition
@@ -395,9 +600,11 @@ func (p *Parser) forStatement() (Stmt, error) {
 
 	// Insert loop variable update, if there is one, as last statement in loop body
 	if loopVarUpdate != nil {
+		update := &ExpressionStmt{loopVarUpdate}
+		p.stmtLines[update] = forLine
 		body = &BlockStmt{[]Stmt{
 			body,
-			&ExpressionStmt{loopVarUpdate},
+			update,
 		},
 		}
 	}
@@ -407,6 +614,7 @@ func (p *Parser) forStatement() (Stmt, error) {
 		loopCondition = &LiteralExpr{true}
 	}
 	body = &WhileStmt{loopCondition, body}
+	p.stmtLines[body] = forLine
 
 	// Insert loop variable initialization before while loop
 	if loopVarInit != nil {
@@ -580,8 +788,11 @@ func (p *Parser) comparison() (Expr, error) {
 		return nil, err
 	}
 
-	for p.matches(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
+	for p.matches(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) || p.matchesIs() {
 		operator := p.previous()
+		if operator.token_type == IDENTIFIER {
+			operator.token_type = IS
+		}
 		right, err := p.term()
 		if err != nil {
 			return nil, err
@@ -644,7 +855,7 @@ func (p *Parser) unary() (Expr, error) {
 	}
 }
 
-// call → primary ( "(" arguments? ")" | "." IDENTIFIER )* ;
+// call → primary ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )* ;
 func (p *Parser) call() (Expr, error) {
 	var expr Expr
 	var err error
@@ -653,6 +864,18 @@ func (p *Parser) call() (Expr, error) {
 		return nil, err
 	}
 
+	// 'inner' is only special when it's called in a class body, so it can still be
+	// used as a name
+	if variable, ok := expr.(*VariableExpr); ok && p.inClassBody && variable.variable.lexeme == "inner" &&
+		p.matches(LEFT_PAREN) {
+		if expr, err = p.callArguments(expr); err != nil {
+			return nil, err
+		}
+		call := expr.(*CallExpr)
+		expr = &InnerExpr{keyword: variable.variable, paren: call.Paren, arguments: call.Arguments}
+		p.usesInner = true
+	}
+
 	for {
 		if p.matches(LEFT_PAREN) {
 			if expr, err = p.callArguments(expr); err != nil {
@@ -665,6 +888,16 @@ func (p *Parser) call() (Expr, error) {
 			}
 
 			expr = &PropGetExpr{object: expr, propName: propName}
+		} else if p.matches(LEFT_BRACKET) {
+			var index Expr
+			if index, err = p.expression(); err != nil {
+				return nil, err
+			}
+			var bracket Token
+			if bracket, err = p.consume(RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+				return nil, err
+			}
+			expr = &IndexExpr{object: expr, bracket: bracket, index: index}
 		} else {
 			break
 		}
@@ -759,6 +992,16 @@ func (p *Parser) primary() (Expr, error) {
 	return nil, p.constructError(p.peek(), "Expected expression")
 }
 
+// matchesIs matches the 'is' operator, which is only special between two operands, so
+// it can still be used as a name
+func (p *Parser) matchesIs() bool {
+	if p.nextTokenTypeIs(IDENTIFIER) && p.peek().lexeme == "is" {
+		p.advance()
+		return true
+	}
+	return false
+}
+
 func (p *Parser) matches(tokenTypes ...TokenType) bool {
 	for _, tokenType := range tokenTypes {
 		if p.nextTokenTypeIs(tokenType) {
@@ -440,3 +440,26 @@ func TestParserReturnStatement(t *testing.T) {
 		})
 	}
 }
+
+func TestParserStatementLines(t *testing.T) {
+	lox := NewTestGLox()
+	scanner := NewScanner(lox, "var a = 1;\nif (a)\n    print a;\nfor (var i = 0; i < 2; i = i + 1) {\n    print i;\n}")
+	parser := NewParser(lox, scanner.scanTokens())
+	statements, err := parser.parse()
+	assertNoError(t, err, "Parse")
+
+	assertEqual(t, 1, parser.stmtLines[statements[0]], "Var statement")
+	ifStmt := statements[1].(*IfStmt)
+	assertEqual(t, 2, parser.stmtLines[ifStmt], "If statement")
+	assertEqual(t, 3, parser.stmtLines[ifStmt.thenBranch], "Then branch")
+
+	// The statements a 'for' loop is desugared into are on the line of the 'for'
+	loop := statements[2].(*BlockStmt)
+	assertEqual(t, 4, parser.stmtLines[loop.statements[0]], "Loop initializer")
+	while := loop.statements[1].(*WhileStmt)
+	assertEqual(t, 4, parser.stmtLines[while], "Loop condition")
+	body := while.body.(*BlockStmt)
+	assertEqual(t, 4, parser.stmtLines[body.statements[1]], "Loop update")
+	print := body.statements[0].(*BlockStmt).statements[0]
+	assertEqual(t, 5, parser.stmtLines[print], "Loop body")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Private members are fields and methods whose names start with '#'. They can only
+// be accessed through 'this', in the body of the class that declares them: the
+// resolver checks this, and records the class body each access is in. Each class in
+// a hierarchy has its own private members, so a subclass can't see its superclass's
+// private members, and can use the same names for its own.
+
+// privateKey identifies a private field: its name, and the class that declared it
+type privateKey struct {
+	owner *ClassStmt
+	name  string
+}
+
+// privateFields holds the private fields of an instance, or the private static
+// fields of a class
+type privateFields map[privateKey]any
+
+func isPrivateName(name string) bool {
+	return strings.HasPrefix(name, "#")
+}
+
+func (li *LoxInstance) getPrivate(accessor *ClassStmt, token Token) (any, error) {
+	return getPrivateMember(li.interpreter, li, li.private, li.class, func(c *LoxClass) *LoxClass { return c }, accessor, token)
+}
+
+func (li *LoxInstance) setPrivate(accessor *ClassStmt, token Token, value any, inSetter bool) error {
+	if li.private == nil {
+		li.private = make(privateFields)
+	}
+	return setPrivateMember(li.interpreter, li, li.private, li.class, func(c *LoxClass) *LoxClass { return c }, accessor, token, value, inSetter)
+}
+
+// getPrivate retrieves a private static field or class method of a class
+func (lc *LoxClass) getPrivate(i *Interpreter, accessor *ClassStmt, token Token) (any, error) {
+	return getPrivateMember(i, lc, lc.private, lc, func(c *LoxClass) *LoxClass { return c.metaclass }, accessor, token)
+}
+
+func (lc *LoxClass) setPrivate(i *Interpreter, accessor *ClassStmt, token Token, value any, inSetter bool) error {
+	if lc.private == nil {
+		lc.private = make(privateFields)
+	}
+	return setPrivateMember(i, lc, lc.private, lc, func(c *LoxClass) *LoxClass { return c.metaclass }, accessor, token, value, inSetter)
+}
+
+// privateOwner finds the class, out of a class and its superclasses, whose body an
+// access to a private member is in
+func privateOwner(class *LoxClass, accessor *ClassStmt, token Token) (*LoxClass, error) {
+	for c := class; c != nil; c = c.superclass {
+		if accessor != nil && c.declaration == accessor {
+			return c, nil
+		}
+	}
+	return nil, RuntimeError{token, fmt.Sprintf("Can't access private member '%s' outside of its class.", token.lexeme)}
+}
+
+// getPrivateMember looks up a private member of an object. Methods are looked up in
+// the class returned by methodsOf, which is the class itself for instances, and the
+// metaclass for classes.
+func getPrivateMember(i *Interpreter, object any, fields privateFields, class *LoxClass,
+	methodsOf func(*LoxClass) *LoxClass, accessor *ClassStmt, token Token) (any, error) {
+	owner, err := privateOwner(class, accessor, token)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := fields[privateKey{owner.declaration, token.lexeme}]; ok {
+		return value, nil
+	}
+
+	// Private methods aren't inherited, so only the owner's own methods are searched
+	if methods := methodsOf(owner); methods != nil {
+		if method, ok := methods.methods[token.lexeme]; ok {
+			if method.declaration.isGetter {
+				return method.bindThis(object).call(i, nil)
+			}
+			return &LoxBoundMethod{object, method}, nil
+		}
+	}
+	return nil, undefinedPrivateMember(fields, owner, methodsOf, token)
+}
+
+func setPrivateMember(i *Interpreter, object any, fields privateFields, class *LoxClass,
+	methodsOf func(*LoxClass) *LoxClass, accessor *ClassStmt, token Token, value any, inSetter bool) error {
+	owner, err := privateOwner(class, accessor, token)
+	if err != nil {
+		return err
+	}
+
+	if methods := methodsOf(owner); methods != nil && !inSetter {
+		if setter, ok := methods.setters[token.lexeme]; ok {
+			_, err := setter.bindThis(object).call(i, []any{value})
+			return err
+		}
+		if method, ok := methods.methods[token.lexeme]; ok && method.declaration.isGetter {
+			return RuntimeError{token, fmt.Sprintf("Can't assign to read-only property '%s'.", token.lexeme)}
+		}
+	}
+	if instance, ok := object.(*LoxInstance); ok {
+		if err := instance.class.checkDeclaredField(owner, token); err != nil {
+			return err
+		}
+	}
+	fields[privateKey{owner.declaration, token.lexeme}] = value
+	return nil
+}
+
+// undefinedPrivateMember returns the error for a private member that the owner
+// doesn't have. If a superclass has a member with the name, the error says so, since
+// the likely mistake is expecting it to be inherited.
+func undefinedPrivateMember(fields privateFields, owner *LoxClass, methodsOf func(*LoxClass) *LoxClass, token Token) error {
+	for c := owner.superclass; c != nil; c = c.superclass {
+		_, hasField := fields[privateKey{c.declaration, token.lexeme}]
+		hasMethod := false
+		if methods := methodsOf(c); methods != nil {
+			_, hasMethod = methods.methods[token.lexeme]
+		}
+		if hasField || hasMethod {
+			return RuntimeError{token, fmt.Sprintf("Can't access private member '%s' of class %s from subclass %s.",
+				token.lexeme, c.name, owner.name)}
+		}
+	}
+	return RuntimeError{token, fmt.Sprintf("Undefined private member '%s'.", token.lexeme)}
+}
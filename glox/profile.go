@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profiler is an execution hook that records how many times each function is
+// called, the time spent in each (both including and excluding the functions it
+// calls), the time spent in each distinct call stack and how many times each line is
+// executed
+type Profiler struct {
+	file      string   // name of the script, used in reports
+	source    []string // lines of the script
+	stmtLines map[Stmt]int
+	clock     func() time.Time
+
+	start     time.Time
+	elapsed   time.Duration // total run time, set by finish
+	stack     []*profileEntry
+	functions map[string]*functionProfile
+	stacks    map[string]time.Duration // exclusive time, keyed by folded stack
+	lineHits  map[int]int
+}
+
+// profileEntry records a call that's currently running
+type profileEntry struct {
+	function  *functionProfile
+	start     time.Time
+	childTime time.Duration // time spent in functions it called
+}
+
+// functionProfile holds the totals recorded for one function. Methods are recorded
+// separately for each class eg "Counter.add".
+type functionProfile struct {
+	name      string
+	line      int // line the function is declared on
+	calls     int
+	inclusive time.Duration
+	exclusive time.Duration
+	active    int // number of calls currently running, for recursive functions
+}
+
+func NewProfiler(file string, source string, stmtLines map[Stmt]int) *Profiler {
+	return &Profiler{
+		file:      file,
+		source:    strings.Split(source, "\n"),
+		stmtLines: stmtLines,
+		clock:     time.Now,
+		functions: make(map[string]*functionProfile),
+		stacks:    make(map[string]time.Duration),
+		lineHits:  make(map[int]int),
+	}
+}
+
+// begin starts timing the top-level script. It's called just before the script is
+// run.
+func (p *Profiler) begin() {
+	p.start = p.clock()
+	p.stack = []*profileEntry{{function: p.function("<script>", 1), start: p.start}}
+	p.stack[0].function.calls = 1
+	p.stack[0].function.active = 1
+}
+
+// finish stops timing the script, once it has run. Any calls still running, because
+// the script was aborted, are closed off.
+func (p *Profiler) finish() {
+	for len(p.stack) > 0 {
+		p.exit()
+	}
+	p.elapsed = p.functions["<script>"].inclusive
+}
+
+func (p *Profiler) beforeStatement(stmt Stmt) error {
+	if _, isBlock := stmt.(*BlockStmt); !isBlock {
+		if line, ok := p.stmtLines[stmt]; ok {
+			p.lineHits[line]++
+		}
+	}
+	return nil
+}
+
+func (p *Profiler) enteredCall(frame *callFrame, arguments []any) {
+	function := p.function(frame.displayName(), frame.function.declaration.functionName.line)
+	function.calls++
+	function.active++
+	p.stack = append(p.stack, &profileEntry{function: function, start: p.clock()})
+}
+
+func (p *Profiler) returnedFromCall(frame *callFrame, result any, err error) {
+	if len(p.stack) > 1 {
+		p.exit()
+	}
+}
+
+// exit records the time spent in the innermost running call and removes it from the
+// stack
+func (p *Profiler) exit() {
+	entry := p.stack[len(p.stack)-1]
+	elapsed := p.clock().Sub(entry.start)
+	exclusive := elapsed - entry.childTime
+
+	names := make([]string, len(p.stack))
+	for i, e := range p.stack {
+		names[i] = e.function.name
+	}
+	p.stacks[strings.Join(names, ";")] += exclusive
+
+	p.stack = p.stack[:len(p.stack)-1]
+	entry.function.active--
+	entry.function.exclusive += exclusive
+	// Only the outermost call to a recursive function counts towards its inclusive
+	// time, otherwise the time would be counted more than once
+	if entry.function.active == 0 {
+		entry.function.inclusive += elapsed
+	}
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].childTime += elapsed
+	}
+}
+
+func (p *Profiler) function(name string, line int) *functionProfile {
+	function, ok := p.functions[name]
+	if !ok {
+		function = &functionProfile{name: name, line: line}
+		p.functions[name] = function
+	}
+	return function
+}
+
+// sortedFunctions returns the recorded functions, with the most time spent in the
+// function itself first
+func (p *Profiler) sortedFunctions() []*functionProfile {
+	functions := make([]*functionProfile, 0, len(p.functions))
+	for _, function := range p.functions {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].exclusive != functions[j].exclusive {
+			return functions[i].exclusive > functions[j].exclusive
+		}
+		return functions[i].name < functions[j].name
+	})
+	return functions
+}
+
+// writeText writes a human-readable summary of the profile
+func (p *Profiler) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Profile of %s (total %s)\n\n", p.file, formatProfileTime(p.elapsed))
+
+	b.WriteString("Functions:\n")
+	fmt.Fprintf(&b, "%8s %12s %12s  %s\n", "calls", "inclusive", "exclusive", "function")
+	for _, function := range p.sortedFunctions() {
+		fmt.Fprintf(&b, "%8d %12s %12s  %s\n", function.calls, formatProfileTime(function.inclusive),
+			formatProfileTime(function.exclusive), function.name)
+	}
+
+	b.WriteString("\nLines:\n")
+	fmt.Fprintf(&b, "%8s %6s  %s\n", "hits", "line", "source")
+	lines := make([]int, 0, len(p.lineHits))
+	for line := range p.lineHits {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		text := ""
+		if line <= len(p.source) {
+			text = strings.TrimSpace(p.source[line-1])
+		}
+		fmt.Fprintf(&b, "%8d %6d  %s\n", p.lineHits[line], line, text)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFolded writes the time spent in each call stack, in microseconds, in the
+// "folded stacks" format read by flame graph tools eg "<script>;run;fib 1234"
+func (p *Profiler) writeFolded(w io.Writer) error {
+	stacks := make([]string, 0, len(p.stacks))
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	var b strings.Builder
+	for _, stack := range stacks {
+		fmt.Fprintf(&b, "%s %d\n", stack, p.stacks[stack].Microseconds())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writePprof writes the profile as a gzipped pprof protocol buffer, with one sample
+// for each call stack. See https://github.com/google/pprof/blob/main/proto/profile.proto
+func (p *Profiler) writePprof(w io.Writer) error {
+	strs := []string{""}
+	stringIndex := map[string]int{"": 0}
+	str := func(s string) uint64 {
+		if index, ok := stringIndex[s]; ok {
+			return uint64(index)
+		}
+		stringIndex[s] = len(strs)
+		strs = append(strs, s)
+		return uint64(len(strs) - 1)
+	}
+
+	var profile protoBuffer
+	var valueType protoBuffer
+	valueType.uint(1, str("time"))
+	valueType.uint(2, str("nanoseconds"))
+	profile.message(1, &valueType) // sample_type
+
+	// Every function gets a function record and a location record with the same ID
+	ids := make(map[string]uint64)
+	functions := p.sortedFunctions()
+	sort.Slice(functions, func(i, j int) bool { return functions[i].name < functions[j].name })
+	for _, function := range functions {
+		id := uint64(len(ids) + 1)
+		ids[function.name] = id
+
+		var line, location, fn protoBuffer
+		line.uint(1, id)
+		line.uint(2, uint64(function.line))
+		location.uint(1, id)
+		location.message(4, &line)
+		profile.message(4, &location)
+
+		// pprof drops names in angle brackets, so the top-level script would be shown
+		// as "<unknown>"
+		name := function.name
+		if name == "<script>" {
+			name = "script"
+		}
+		fn.uint(1, id)
+		fn.uint(2, str(name))
+		fn.uint(3, str(name))
+		fn.uint(4, str(p.file))
+		fn.uint(5, uint64(function.line))
+		profile.message(5, &fn)
+	}
+
+	stacks := make([]string, 0, len(p.stacks))
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		var sample protoBuffer
+		// Locations are listed from the innermost call outwards
+		names := strings.Split(stack, ";")
+		for i := len(names) - 1; i >= 0; i-- {
+			sample.uint(1, ids[names[i]])
+		}
+		sample.uint(2, uint64(p.stacks[stack].Nanoseconds()))
+		profile.message(2, &sample)
+	}
+
+	for _, s := range strs {
+		profile.bytes(6, []byte(s)) // string_table
+	}
+	profile.uint(9, uint64(p.start.UnixNano()))
+	profile.uint(10, uint64(p.elapsed.Nanoseconds()))
+	profile.message(11, &valueType) // period_type
+	profile.uint(12, 1)             // period
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(profile.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// formatProfileTime formats a duration as milliseconds
+func formatProfileTime(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d.Nanoseconds())/1e6)
+}
+
+// protoBuffer is just enough of a protocol buffer encoder to write pprof profiles
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	b.WriteByte(byte(v))
+}
+
+func (b *protoBuffer) uint(field int, v uint64) {
+	b.varint(uint64(field) << 3) // wire type 0: varint
+	b.varint(v)
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2) // wire type 2: length-delimited
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protoBuffer) message(field int, message *protoBuffer) {
+	b.bytes(field, message.Bytes())
+}
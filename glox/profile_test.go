@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// PROFILER TESTS
+// ============================================================================
+
+// runProfiled runs a program with a profiler whose clock advances by a millisecond
+// every time it's read, so that the times recorded are predictable
+func runProfiled(t *testing.T, program string) *Profiler {
+	t.Helper()
+	lox := &GLox{stmtLines: make(map[Stmt]int)}
+	lox.interpreter = NewInterpreter(lox)
+	lox.interpreter.stdout = io.Discard
+
+	profiler := NewProfiler("test.lox", program, lox.stmtLines)
+	now := time.Unix(0, 0)
+	profiler.clock = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	lox.interpreter.hook = profiler
+
+	profiler.begin()
+	lox.run(program, false)
+	profiler.finish()
+	return profiler
+}
+
+func TestProfiler(t *testing.T) {
+	program := "fun f() {}\nfun g() {\n  f();\n}\ng();\n"
+
+	t.Run("Text summary", func(t *testing.T) {
+		var out strings.Builder
+		assertNoError(t, runProfiled(t, program).writeText(&out), "writeText")
+		expected := `Profile of test.lox (total 5.000ms)
+
+Functions:
+   calls    inclusive    exclusive  function
+       1      5.000ms      2.000ms  <script>
+       1      3.000ms      2.000ms  g
+       1      1.000ms      1.000ms  f
+
+Lines:
+    hits   line  source
+       1      1  fun f() {}
+       1      2  fun g() {
+       1      3  f();
+       1      5  g();
+`
+		assertEqual(t, expected, out.String(), "Summary")
+	})
+
+	t.Run("Folded stacks", func(t *testing.T) {
+		var out strings.Builder
+		assertNoError(t, runProfiled(t, program).writeFolded(&out), "writeFolded")
+		assertEqual(t, "<script> 2000\n<script>;g 2000\n<script>;g;f 1000\n", out.String(), "Folded stacks")
+	})
+
+	t.Run("Recursive calls", func(t *testing.T) {
+		profiler := runProfiled(t, "fun r(n) {\n  if (n > 0) r(n - 1);\n}\nr(2);\n")
+		r := profiler.functions["r"]
+		assertEqual(t, 3, r.calls, "Calls")
+		assertEqual(t, 5*time.Millisecond, r.inclusive, "Inclusive time counted once")
+		assertEqual(t, 5*time.Millisecond, r.exclusive, "Exclusive time")
+		// The if statement and the call it contains are both on line 2
+		assertEqual(t, 5, profiler.lineHits[2], "Line hits")
+	})
+
+	t.Run("Methods and runtime errors", func(t *testing.T) {
+		profiler := runProfiled(t, "class A {\n  m() { return -\"x\"; }\n}\nA().m();\n")
+		assertEqual(t, 1, profiler.functions["A.m"].calls, "Method calls")
+		assertEqual(t, 0, len(profiler.stack), "Stack unwound")
+	})
+
+	t.Run("pprof", func(t *testing.T) {
+		var out bytes.Buffer
+		assertNoError(t, runProfiled(t, program).writePprof(&out), "writePprof")
+		reader, err := gzip.NewReader(&out)
+		assertNoError(t, err, "gzip")
+		data, err := io.ReadAll(reader)
+		assertNoError(t, err, "Read profile")
+		for _, s := range []string{"time", "nanoseconds", "script", "test.lox"} {
+			assertContains(t, string(data), s)
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.lox")
+		writeTestFile(t, path, program+"print 1;\n")
+
+		folded := filepath.Join(dir, "out.folded")
+		stdout, code := captureCommandOutput(t, func() int { return runCommand([]string{"--profile=" + folded, path}) })
+		assertEqual(t, 0, code, "Exit code")
+		assertEqual(t, "1\n", stdout, "Program output")
+		data, err := os.ReadFile(folded)
+		assertNoError(t, err, "Read profile")
+		assertContains(t, string(data), "<script>;g;f ")
+
+		text := filepath.Join(dir, "out.prof")
+		_, code = captureCommandOutput(t, func() int { return runCommand([]string{"--profile", text, path}) })
+		assertEqual(t, 0, code, "Exit code")
+		data, _ = os.ReadFile(text)
+		assertContains(t, string(data), "Profile of test.lox")
+
+		_, code = captureCommandOutput(t, func() int {
+			return runCommand([]string{"--profile=" + text, "--profile-format=svg", path})
+		})
+		assertEqual(t, 64, code, "Unknown format")
+	})
+}
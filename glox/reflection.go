@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Natives for asking what a value is, and for accessing fields by name, along with
+// the 'is' operator
+
+// nativeError is returned by a native function called with the wrong kind of
+// argument. The interpreter reports it as a runtime error at the call.
+type nativeError string
+
+func (e nativeError) Error() string {
+	return string(e)
+}
+
+// nativeFn is a native function implemented by a Go function
+type nativeFn struct {
+	name   string
+	params int
+	fn     func(i *Interpreter, arguments []any) (any, error)
+}
+
+func (n nativeFn) arity() int {
+	return n.params
+}
+
+func (n nativeFn) call(i *Interpreter, arguments []any) (any, error) {
+	return n.fn(i, arguments)
+}
+
+var reflectionFns = []nativeFn{
+	{"type", 1, typeOf},
+	{"classOf", 1, classOf},
+	{"fields", 1, fieldNames},
+	{"methods", 1, methodNames},
+	{"hasField", 2, hasField},
+	{"getField", 2, getField},
+	{"setField", 3, setField},
+	{"superclassOf", 1, superclassOf},
+	{"arity", 1, arityOf},
+	{"name", 1, nameOf},
+}
+
+// isInstanceOf implements 'value is class', which is true if the value is an instance
+// of the class, a subclass of it, or a class that uses it if it's a trait
+func isInstanceOf(operator Token, value any, classOrTrait any) (bool, error) {
+	switch classOrTrait.(type) {
+	case *LoxClass, *LoxTrait:
+	default:
+		return false, RuntimeError{operator, "Right operand of 'is' must be a class or trait."}
+	}
+
+	instance, ok := value.(*LoxInstance)
+	if !ok {
+		return false, nil
+	}
+	for class := instance.class; class != nil; class = class.superclass {
+		if class == classOrTrait {
+			return true, nil
+		}
+		for _, trait := range class.traits {
+			if trait == classOrTrait {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// newLoxList returns the values as an object with a length field and a get(index)
+// function, which returns nil for an index that's out of range, since Lox has no
+// lists
+func newLoxList(i *Interpreter, className string, values []any) *LoxInstance {
+	instance := NewLoxInstance(i, NewLoxClass(className, nil, map[string]*LoxFunction{}))
+	instance.fields["length"] = float64(len(values))
+	instance.fields["get"] = nativeFn{"get", 1, func(i *Interpreter, arguments []any) (any, error) {
+		index, ok := arguments[0].(float64)
+		if !ok || index != float64(int(index)) || index < 0 || int(index) >= len(values) {
+			return nil, nil
+		}
+		return values[int(index)], nil
+	}}
+	return instance
+}
+
+// typeOf returns the name of the type of a value eg "number"
+func typeOf(i *Interpreter, arguments []any) (any, error) {
+	switch arguments[0].(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return "boolean", nil
+	case float64:
+		return "number", nil
+	case string:
+		return "string", nil
+	case *LoxClass:
+		return "class", nil
+	case *LoxTrait:
+		return "trait", nil
+	case *LoxInstance:
+		return "instance", nil
+	case LoxCallable:
+		return "function", nil
+	}
+	return "unknown", nil
+}
+
+func classOf(i *Interpreter, arguments []any) (any, error) {
+	instance, err := instanceArgument("classOf", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	return instance.class, nil
+}
+
+// fieldNames returns the names of an instance's public fields, in alphabetical order
+func fieldNames(i *Interpreter, arguments []any) (any, error) {
+	instance, err := instanceArgument("fields", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(instance.fields))
+	for name := range instance.fields {
+		names = append(names, name)
+	}
+	return newLoxList(i, "Fields", sortedValues(names)), nil
+}
+
+// methodNames returns the names of the public methods of a class, including those
+// it inherits, in alphabetical order
+func methodNames(i *Interpreter, arguments []any) (any, error) {
+	class, ok := arguments[0].(*LoxClass)
+	if !ok {
+		return nil, nativeError("methods() expects a class.")
+	}
+	names := make([]string, 0)
+	for c := class; c != nil; c = c.superclass {
+		for name := range c.methods {
+			if !isPrivateName(name) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return newLoxList(i, "Methods", sortedValues(names)), nil
+}
+
+func hasField(i *Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("hasField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := instance.fields[name]
+	return ok, nil
+}
+
+func getField(i *Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("getField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := instance.fields[name]
+	if !ok {
+		return nil, nativeError(fmt.Sprintf("Undefined field '%s'.", name))
+	}
+	return value, nil
+}
+
+// setField sets a field, without calling a setter for it, and returns the value
+func setField(i *Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("setField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	if _, isClass := arguments[2].(*LoxClass); isClass {
+		return nil, nativeError("Can't set a field to be a class")
+	}
+	token := Token{IDENTIFIER, name, nil, 0, 0, 0}
+	if err := instance.class.checkDeclaredField(instance.class, token); err != nil {
+		return nil, nativeError(err.Error())
+	}
+	instance.set(token, arguments[2])
+	return arguments[2], nil
+}
+
+func superclassOf(i *Interpreter, arguments []any) (any, error) {
+	class, ok := arguments[0].(*LoxClass)
+	if !ok {
+		return nil, nativeError("superclassOf() expects a class.")
+	}
+	if class.superclass == nil {
+		return nil, nil
+	}
+	return class.superclass, nil
+}
+
+func arityOf(i *Interpreter, arguments []any) (any, error) {
+	callable, ok := asCallable(arguments[0])
+	if !ok {
+		return nil, nativeError("arity() expects a function or class.")
+	}
+	return float64(callable.arity()), nil
+}
+
+// nameOf returns the name of a function, class or trait
+func nameOf(i *Interpreter, arguments []any) (any, error) {
+	switch value := arguments[0].(type) {
+	case *LoxFunction:
+		return value.declaration.functionName.lexeme, nil
+	case *LoxBoundMethod:
+		return value.name(), nil
+	case *LoxClass:
+		return value.name, nil
+	case *LoxTrait:
+		return value.name, nil
+	case nativeFn:
+		return value.name, nil
+	case clockFn:
+		return "clock", nil
+	case argsFn:
+		return "args", nil
+	}
+	return nil, nativeError("name() expects a function, class or trait.")
+}
+
+func instanceArgument(function string, value any) (*LoxInstance, error) {
+	instance, ok := value.(*LoxInstance)
+	if !ok {
+		return nil, nativeError(function + "() expects an instance.")
+	}
+	return instance, nil
+}
+
+// fieldArguments checks the instance and field name arguments of the functions that
+// access fields by name. Private fields can't be accessed this way.
+func fieldArguments(function string, arguments []any) (*LoxInstance, string, error) {
+	instance, err := instanceArgument(function, arguments[0])
+	if err != nil {
+		return nil, "", err
+	}
+	name, ok := arguments[1].(string)
+	if !ok {
+		return nil, "", nativeError(function + "() expects a field name.")
+	}
+	if isPrivateName(name) {
+		return nil, "", nativeError(fmt.Sprintf("Can't access private member '%s' outside of its class.", name))
+	}
+	return instance, name, nil
+}
+
+func sortedValues(names []string) []any {
+	slices.Sort(names)
+	values := make([]any, 0, len(names))
+	for _, name := range names {
+		values = append(values, name)
+	}
+	return values
+}
@@ -0,0 +1,213 @@
+package main
+
+import "testing"
+
+// ============================================================================
+// REFLECTION TESTS
+// ============================================================================
+
+func TestIsOperator(t *testing.T) {
+	t.Run("Instances of a class and its subclasses", func(t *testing.T) {
+		program := `
+class Shape {}
+class Circle < Shape {}
+class Square < Shape {}
+var c = Circle();
+print c is Circle;
+print c is Shape;
+print c is Square;
+print Shape() is Circle;
+`
+		expected := []string{"true", "true", "false", "false"}
+		runProgramAndCheckOutput(t, program, expected, "Instances of a class and its subclasses")
+	})
+
+	t.Run("Values that aren't instances", func(t *testing.T) {
+		program := `
+class Point {}
+print 1 is Point;
+print nil is Point;
+print "point" is Point;
+print Point is Point;
+`
+		expected := []string{"false", "false", "false", "false"}
+		runProgramAndCheckOutput(t, program, expected, "Values that aren't instances")
+	})
+
+	t.Run("Traits", func(t *testing.T) {
+		program := `
+trait Printable {
+    show() {}
+}
+class Base with Printable {}
+class Derived < Base {}
+class Other {}
+print Derived() is Printable;
+print Other() is Printable;
+`
+		expected := []string{"true", "false"}
+		runProgramAndCheckOutput(t, program, expected, "Traits")
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		program := `
+class A {}
+var a = A();
+print a is A == true;
+print !(a is A) or a is A;
+`
+		expected := []string{"true", "true"}
+		runProgramAndCheckOutput(t, program, expected, "Precedence")
+	})
+
+	t.Run("Is can be used as a name", func(t *testing.T) {
+		program := `
+var is = 1;
+print is + 1;
+`
+		expected := []string{"2"}
+		runProgramAndCheckOutput(t, program, expected, "Is can be used as a name")
+	})
+
+	t.Run("Right operand must be a class", func(t *testing.T) {
+		program := `
+print 1 is 2;
+`
+		runProgramAndExpectError(t, program, "Right operand of 'is' must be a class or trait.", "Right operand must be a class")
+	})
+}
+
+func TestReflectionFunctions(t *testing.T) {
+	t.Run("Type", func(t *testing.T) {
+		program := `
+class A {
+    m() {}
+}
+trait T {}
+fun f() {}
+print type(nil);
+print type(true);
+print type(1);
+print type("s");
+print type(f);
+print type(clock);
+print type(A);
+print type(A());
+print type(A().m);
+print type(T);
+`
+		expected := []string{"nil", "boolean", "number", "string", "function", "function", "class", "instance", "function", "trait"}
+		runProgramAndCheckOutput(t, program, expected, "Type")
+	})
+
+	t.Run("Classes", func(t *testing.T) {
+		program := `
+class Base {
+    init(a, b) {}
+    greet() {}
+    #hidden() {}
+}
+class Derived < Base {
+    wave() {}
+}
+var d = Derived(1, 2);
+print classOf(d) == Derived;
+print superclassOf(Derived) == Base;
+print superclassOf(Base);
+var m = methods(Derived);
+print m.length;
+print m.get(0);
+print m.get(1);
+print m.get(2);
+`
+		expected := []string{"true", "true", "<nil>", "3", "greet", "init", "wave"}
+		runProgramAndCheckOutput(t, program, expected, "Classes")
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		program := `
+class Point {
+    init() {
+        this.y = 2;
+        this.x = 1;
+        this.#secret = 3;
+    }
+}
+var p = Point();
+var f = fields(p);
+print f.length;
+print f.get(0);
+print f.get(1);
+print hasField(p, "x");
+print hasField(p, "z");
+print getField(p, "y");
+print setField(p, "z", 3);
+print p.z;
+`
+		expected := []string{"2", "x", "y", "true", "false", "2", "3", "3"}
+		runProgramAndCheckOutput(t, program, expected, "Fields")
+	})
+
+	t.Run("Functions", func(t *testing.T) {
+		program := `
+fun add(a, b) {
+    return a + b;
+}
+class Counter {
+    init(start) {}
+    increment() {}
+}
+print arity(add);
+print arity(Counter);
+print arity(clock);
+print name(add);
+print name(Counter);
+print name(Counter(0).increment);
+print name(clock);
+`
+		expected := []string{"2", "1", "0", "add", "Counter", "increment", "clock"}
+		runProgramAndCheckOutput(t, program, expected, "Functions")
+	})
+}
+
+func TestReflectionErrors(t *testing.T) {
+	t.Run("Fields of a value that isn't an instance", func(t *testing.T) {
+		runProgramAndExpectError(t, "fields(1);", "fields() expects an instance.", "Fields of a value that isn't an instance")
+	})
+
+	t.Run("Methods of a value that isn't a class", func(t *testing.T) {
+		runProgramAndExpectError(t, "methods(nil);", "methods() expects a class.", "Methods of a value that isn't a class")
+	})
+
+	t.Run("Undefined field", func(t *testing.T) {
+		program := `
+class A {}
+getField(A(), "missing");
+`
+		runProgramAndExpectError(t, program, "Undefined field 'missing'.", "Undefined field")
+	})
+
+	t.Run("Private field", func(t *testing.T) {
+		program := `
+class A {}
+getField(A(), "#secret");
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#secret' outside of its class.", "Private field")
+	})
+
+	t.Run("Undeclared field of a sealed class", func(t *testing.T) {
+		program := `
+sealed class A {}
+setField(A(), "x", 1);
+`
+		runProgramAndExpectError(t, program, "Can't assign undeclared field 'x' on instance of sealed class A.", "Undeclared field of a sealed class")
+	})
+
+	t.Run("Arity of a value that isn't callable", func(t *testing.T) {
+		runProgramAndExpectError(t, `arity("f");`, "arity() expects a function or class.", "Arity of a value that isn't callable")
+	})
+
+	t.Run("Arity of an instance without __call", func(t *testing.T) {
+		runProgramAndExpectError(t, "class P {}\narity(P());", "arity() expects a function or class.", "Arity of an instance without __call")
+	})
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Maximum number of entries kept in the REPL history
+const replHistorySize = 1000
+
+// Repl is the interactive prompt. Input is read an entry at a time: an entry is one
+// or more lines, continuing until all brackets and strings are closed. When reading
+// from a terminal, lines can be edited and entries from earlier sessions recalled.
+type Repl struct {
+	lox    *GLox
+	out    io.Writer
+	editor *lineEditor    // used to read lines from a terminal
+	lines  *bufio.Scanner // used to read lines otherwise
+	// history holds previous entries, oldest first. If historyFile is set, entries
+	// are loaded from it at startup and appended to it as they're entered.
+	history     []string
+	historyFile string
+	// session holds the entries that have been run since the session started or was
+	// reset, so that it can be saved
+	session []string
+}
+
+func NewRepl(lox *GLox, in io.Reader, out io.Writer) *Repl {
+	lox.interpreter.stdout = out
+	return &Repl{lox: lox, out: out, lines: bufio.NewScanner(in)}
+}
+
+// useTerminal switches the REPL to reading input from a terminal, with line editing
+// and a history file
+func (r *Repl) useTerminal(terminal *os.File) {
+	r.editor = NewLineEditor(terminal, r.out)
+	r.editor.rawMode = func() (func(), error) { return enableRawMode(terminal.Fd()) }
+	r.editor.complete = r.complete
+
+	if path := os.Getenv("GLOX_HISTORY"); path != "" {
+		r.historyFile = path
+	} else if home, err := os.UserHomeDir(); err == nil {
+		r.historyFile = filepath.Join(home, ".glox_history")
+	}
+	r.loadHistory()
+}
+
+// run reads and runs entries until the input ends
+func (r *Repl) run() {
+	for {
+		entry, err := r.readEntry()
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if strings.TrimSpace(entry) != "" {
+			r.addHistory(entry)
+			if isColonCommand(entry) {
+				if !r.runColonCommand(entry) {
+					return
+				}
+			} else {
+				r.runSource(entry, true)
+			}
+		}
+		if err != nil {
+			if r.editor != nil {
+				fmt.Fprintln(r.out)
+			}
+			return
+		}
+	}
+}
+
+// runSource runs an entry, or a file loaded into the session
+func (r *Repl) runSource(source string, printResults bool) {
+	r.lox.run(source, printResults)
+	if !r.lox.hadError {
+		r.session = append(r.session, strings.TrimSuffix(source, "\n"))
+	}
+
+	// Errors only affect the entry they're in; anything defined before the error is
+	// still available
+	r.lox.hadError = false
+	r.lox.hadRuntimeError = false
+}
+
+// readEntry reads lines until they form a complete entry. If the input ends part way
+// through an entry, the lines read so far are returned along with the error.
+func (r *Repl) readEntry() (string, error) {
+	var lines []string
+	prompt := "> "
+	for {
+		line, err := r.readLine(prompt)
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		lines = append(lines, line)
+		entry := strings.Join(lines, "\n")
+		if isCompleteInput(entry) || (len(lines) == 1 && isColonCommand(line)) {
+			return entry, nil
+		}
+		prompt = "... "
+	}
+}
+
+func (r *Repl) readLine(prompt string) (string, error) {
+	if r.editor != nil {
+		return r.editor.readLine(prompt, r.history)
+	}
+
+	fmt.Fprint(r.out, prompt)
+	if !r.lines.Scan() {
+		if err := r.lines.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.lines.Text(), nil
+}
+
+// isCompleteInput reports whether some source is complete, or whether more input is
+// needed to close a bracket or string
+func isCompleteInput(source string) bool {
+	scanner := NewScanner(NewDiagnosticCollector(), source)
+	tokens := scanner.scanTokens()
+	for _, err := range scanner.errors {
+		if err.message == "Unterminated string" {
+			return false
+		}
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.token_type {
+		case LEFT_PAREN, LEFT_BRACE, LEFT_BRACKET:
+			depth++
+		case RIGHT_PAREN, RIGHT_BRACE, RIGHT_BRACKET:
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// addHistory records an entry in the history, unless it repeats the previous one
+func (r *Repl) addHistory(entry string) {
+	if len(r.history) > 0 && r.history[len(r.history)-1] == entry {
+		return
+	}
+	r.history = append(r.history, entry)
+	if len(r.history) > replHistorySize {
+		r.history = r.history[len(r.history)-replHistorySize:]
+	}
+
+	if r.historyFile == "" {
+		return
+	}
+	file, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return // the history is a convenience, so it not being saved isn't an error
+	}
+	defer file.Close()
+	fmt.Fprintln(file, escapeHistoryEntry(entry))
+}
+
+// loadHistory reads the entries from the history file. If the file has grown too
+// large, it's rewritten with just the most recent entries.
+func (r *Repl) loadHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.historyFile)
+	if err != nil {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for _, line := range lines {
+		if line != "" {
+			r.history = append(r.history, unescapeHistoryEntry(line))
+		}
+	}
+	if len(r.history) > replHistorySize {
+		r.history = r.history[len(r.history)-replHistorySize:]
+		escaped := make([]string, len(r.history))
+		for i, entry := range r.history {
+			escaped[i] = escapeHistoryEntry(entry)
+		}
+		os.WriteFile(r.historyFile, []byte(strings.Join(escaped, "\n")+"\n"), 0600)
+	}
+}
+
+// escapeHistoryEntry encodes an entry as a single line of the history file
+func escapeHistoryEntry(entry string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(entry)
+}
+
+func unescapeHistoryEntry(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			i++
+			if line[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// colonCommand is a command that's entered at the REPL prompt starting with a colon,
+// rather than being run as Lox
+type colonCommand struct {
+	name        string
+	args        string // how the argument is shown in the help
+	description string
+	run         func(r *Repl, arg string) bool // returns false to end the session
+}
+
+var colonCommands []colonCommand
+
+func init() {
+	colonCommands = []colonCommand{
+		{"load", "file.lox", "run a file in this session", (*Repl).loadCommand},
+		{"reset", "", "discard everything defined in this session", (*Repl).resetCommand},
+		{"env", "", "list the global variables and their values", (*Repl).envCommand},
+		{"type", "expr", "show the type of an expression", (*Repl).typeCommand},
+		{"ast", "expr", "show the syntax tree of an expression", (*Repl).astCommand},
+		{"tokens", "expr", "show the tokens of an expression", (*Repl).tokensCommand},
+		{"time", "expr", "evaluate an expression and show how long it took", (*Repl).timeCommand},
+		{"save", "file.lox", "save the entries from this session to a file", (*Repl).saveCommand},
+		{"help", "", "list the commands", (*Repl).helpCommand},
+		{"quit", "", "end the session", func(r *Repl, arg string) bool { return false }},
+	}
+}
+
+// isColonCommand reports whether an entry is a command rather than Lox source
+func isColonCommand(entry string) bool {
+	return strings.HasPrefix(strings.TrimSpace(entry), ":")
+}
+
+// runColonCommand runs a colon command, returning false if the session should end
+func (r *Repl) runColonCommand(entry string) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(entry), ":"), " ")
+	arg = strings.TrimSpace(arg)
+	for _, command := range colonCommands {
+		if command.name == name {
+			if command.args != "" && arg == "" {
+				fmt.Fprintf(r.out, "Usage: :%s %s\n", command.name, command.args)
+				return true
+			}
+			return command.run(r, arg)
+		}
+	}
+	fmt.Fprintf(r.out, "Unknown command ':%s'. Type :help for a list of commands.\n", name)
+	return true
+}
+
+func (r *Repl) helpCommand(arg string) bool {
+	for _, command := range colonCommands {
+		usage := ":" + command.name
+		if command.args != "" {
+			usage += " " + command.args
+		}
+		fmt.Fprintf(r.out, "  %-16s %s\n", usage, command.description)
+	}
+	return true
+}
+
+func (r *Repl) loadCommand(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(r.out, "Can't load %s: %v\n", path, err)
+		return true
+	}
+	r.runSource(string(data), false)
+	return true
+}
+
+func (r *Repl) resetCommand(arg string) bool {
+	r.lox.interpreter = NewInterpreter(r.lox)
+	r.lox.interpreter.stdout = r.out
+	r.session = nil
+	return true
+}
+
+func (r *Repl) envCommand(arg string) bool {
+	globals := r.lox.interpreter.globalEnv.values
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%s = %s\n", name, formatDebugValue(globals[name]))
+	}
+	return true
+}
+
+func (r *Repl) typeCommand(source string) bool {
+	value, ok := r.evaluate(source)
+	if !ok {
+		return true
+	}
+	_, kind := describeValue(value)
+	if instance, isInstance := value.(*LoxInstance); isInstance {
+		kind = "instance of " + instance.class.name
+	}
+	fmt.Fprintln(r.out, kind)
+	return true
+}
+
+func (r *Repl) astCommand(source string) bool {
+	expr, err := parseDebugExpression(source)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return true
+	}
+	fmt.Fprint(r.out, sexpr([]*astNode{NewAstPrinter(nil).exprNode(expr)}))
+	return true
+}
+
+func (r *Repl) tokensCommand(source string) bool {
+	entries, _ := dumpTokens(source, false)
+	fmt.Fprint(r.out, formatTokenDump(entries))
+	return true
+}
+
+func (r *Repl) timeCommand(source string) bool {
+	start := time.Now()
+	value, ok := r.evaluate(source)
+	elapsed := time.Since(start)
+	if ok {
+		description, _ := describeValue(value)
+		fmt.Fprintln(r.out, description)
+	}
+	fmt.Fprintf(r.out, "(%v)\n", elapsed)
+	return true
+}
+
+func (r *Repl) saveCommand(path string) bool {
+	source := strings.Join(r.session, "\n")
+	if source != "" {
+		source += "\n"
+	}
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		fmt.Fprintf(r.out, "Can't save %s: %v\n", path, err)
+		return true
+	}
+	fmt.Fprintf(r.out, "Saved %d entries to %s\n", len(r.session), path)
+	return true
+}
+
+// evaluate evaluates an expression in the global scope. Errors are reported the
+// same way as for an entry, and false is returned.
+func (r *Repl) evaluate(source string) (any, bool) {
+	expr, err := parseDebugExpression(source)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return nil, false
+	}
+	defer func() {
+		r.lox.hadError = false
+		r.lox.hadRuntimeError = false
+	}()
+	NewResolver(r.lox, r.lox.interpreter).resolveExpr(expr)
+	if r.lox.hadError {
+		return nil, false
+	}
+	value, err := r.lox.interpreter.evaluate(expr)
+	if err != nil {
+		r.lox.runtimeError(err)
+		return nil, false
+	}
+	return value, true
+}
+
+// complete returns the words that could complete the one before the cursor, and
+// where that word starts. After a '.', the fields and methods of the instance
+// before it are completed; otherwise keywords and global names are, or command
+// names at the start of the line.
+func (r *Repl) complete(line string, pos int) (int, []string) {
+	text := []rune(line)[:pos]
+	start := pos
+	for start > 0 && isIdentifierRune(text[start-1]) {
+		start--
+	}
+	prefix := string(text[start:])
+
+	var names []string
+	switch {
+	case start == 1 && text[0] == ':':
+		start, prefix = 0, ":"+prefix
+		for _, command := range colonCommands {
+			names = append(names, ":"+command.name)
+		}
+	case start > 0 && text[start-1] == '.':
+		names = memberNames(r.propertyTarget(text[:start-1]))
+	default:
+		for keyword := range reservedKeyWordMap {
+			names = append(names, keyword)
+		}
+		for name := range r.lox.interpreter.globalEnv.values {
+			names = append(names, name)
+		}
+	}
+
+	matches := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return start, matches
+}
+
+// propertyTarget finds the value of a chain of property accesses, eg 'a.b.c', that
+// ends at the end of the text. Only global variables and fields are looked up, so
+// completion never runs any code.
+func (r *Repl) propertyTarget(text []rune) any {
+	start := len(text)
+	for start > 0 && (isIdentifierRune(text[start-1]) || text[start-1] == '.') {
+		start--
+	}
+	path := strings.Split(string(text[start:]), ".")
+	if path[0] == "" || unicode.IsDigit([]rune(path[0])[0]) {
+		return nil
+	}
+
+	value, ok := r.lox.interpreter.globalEnv.values[path[0]]
+	if !ok {
+		return nil
+	}
+	for _, name := range path[1:] {
+		switch object := value.(type) {
+		case *LoxInstance:
+			value, ok = object.fields[name]
+		case *LoxClass:
+			value, ok = object.fields[name]
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return value
+}
+
+// memberNames returns the names of the fields and methods of an instance, or the
+// static fields and class methods of a class
+func memberNames(value any) []string {
+	names := make([]string, 0)
+	var methodsFrom *LoxClass
+	switch object := value.(type) {
+	case *LoxInstance:
+		for name := range object.fields {
+			names = append(names, name)
+		}
+		methodsFrom = object.class
+	case *LoxClass:
+		for class := object; class != nil; class = class.superclass {
+			for name := range class.fields {
+				names = append(names, name)
+			}
+		}
+		methodsFrom = object.metaclass
+	}
+	for class := methodsFrom; class != nil; class = class.superclass {
+		for name := range class.methods {
+			// init is called by constructing an instance, not as a method
+			if _, isInstance := value.(*LoxInstance); name != "init" || !isInstance {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// REPL TESTS
+// ============================================================================
+
+// runReplSession runs the REPL on some input, returning what it printed
+func runReplSession(t *testing.T, repl *Repl, input string) string {
+	t.Helper()
+	var out strings.Builder
+	repl.out = &out
+	repl.lox.interpreter.stdout = &out
+	repl.lines = NewRepl(repl.lox, strings.NewReader(input), &out).lines
+
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	originalStderr := os.Stderr
+	os.Stderr = devNull
+	defer func() {
+		os.Stderr = originalStderr
+		devNull.Close()
+	}()
+
+	repl.run()
+	return out.String()
+}
+
+func newTestRepl() *Repl {
+	lox := &GLox{}
+	lox.interpreter = NewInterpreter(lox)
+	return NewRepl(lox, strings.NewReader(""), io.Discard)
+}
+
+func TestIsCompleteInput(t *testing.T) {
+	complete := []string{"print 1;", "fun f() { return 1; }", "print (1 + 2);", `print "a";`, "}", "print 1 // {"}
+	for _, source := range complete {
+		assertTruthy(t, isCompleteInput(source), source)
+	}
+	incomplete := []string{"fun f() {", "class A {\n  m() {\n  }", "print (1 +", `print "open`, "{ print \"}\";"}
+	for _, source := range incomplete {
+		assertFalsy(t, isCompleteInput(source), source)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	t.Run("Multi-line entries", func(t *testing.T) {
+		input := "class Counter {\n  init() {\n    this.n = 0;\n  }\n}\nvar c = Counter();\nprint c.n;\nprint \"a\nb\";\n"
+		output := runReplSession(t, newTestRepl(), input)
+		assertEqual(t, "> ... ... ... ... > > 0\n> ... a\nb\n> ", output, "Output")
+	})
+
+	t.Run("Errors don't stop the session", func(t *testing.T) {
+		repl := newTestRepl()
+		output := runReplSession(t, repl, "var a = 1;\nprint -\"x\";\nprint a;\nprint ;\nprint a + 1;\n")
+		assertEqual(t, "> > > 1\n> > 2\n> ", output, "Output")
+		assertFalsy(t, repl.lox.hadError, "hadError")
+		assertFalsy(t, repl.lox.hadRuntimeError, "hadRuntimeError")
+	})
+
+	t.Run("Runtime error inside a function", func(t *testing.T) {
+		input := "fun f() {\n  { var x = -\"a\"; print x; }\n}\nf();\nvar y = 2;\nprint y;\n"
+		output := runReplSession(t, newTestRepl(), input)
+		assertContains(t, output, "> 2\n")
+	})
+
+	t.Run("Class with an undefined trait", func(t *testing.T) {
+		input := "class B {}\nclass A < B with Nope {}\nfun f() { print \"f\"; }\nf();\n"
+		output := runReplSession(t, newTestRepl(), input)
+		assertContains(t, output, "> f\n")
+	})
+
+	t.Run("Input ending mid-entry is still run", func(t *testing.T) {
+		repl := newTestRepl()
+		output := runReplSession(t, repl, "print 1;\n{\nprint 2;")
+		assertEqual(t, "> 1\n> ... ... ", output, "Output")
+		assertEqual(t, []string{"print 1;", "{\nprint 2;"}, repl.history, "History")
+	})
+}
+
+func TestReplHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	repl := newTestRepl()
+	repl.historyFile = path
+	runReplSession(t, repl, "var a = 1;\nvar a = 1;\nfun f() {\n  return \"\\\\n\";\n}\n\n")
+	assertEqual(t, []string{"var a = 1;", "fun f() {\n  return \"\\\\n\";\n}"}, repl.history, "History")
+
+	data, err := os.ReadFile(path)
+	assertNoError(t, err, "Read history")
+	assertEqual(t, "var a = 1;\nfun f() {\\n  return \"\\\\\\\\n\";\\n}\n", string(data), "History file")
+
+	// A new session picks up the history
+	next := newTestRepl()
+	next.historyFile = path
+	next.loadHistory()
+	assertEqual(t, repl.history, next.history, "Loaded history")
+
+	// The file is trimmed once it has too many entries
+	lines := make([]string, replHistorySize+5)
+	for i := range lines {
+		lines[i] = "print " + string(rune('a'+i%26)) + ";"
+	}
+	writeTestFile(t, path, strings.Join(lines, "\n")+"\n")
+	trimmed := newTestRepl()
+	trimmed.historyFile = path
+	trimmed.loadHistory()
+	assertEqual(t, replHistorySize, len(trimmed.history), "Trimmed history")
+	data, _ = os.ReadFile(path)
+	assertEqual(t, replHistorySize, strings.Count(string(data), "\n"), "Trimmed history file")
+}
+
+func TestLineEditor(t *testing.T) {
+	readLine := func(input string, history ...string) (string, error) {
+		editor := NewLineEditor(strings.NewReader(input), io.Discard)
+		return editor.readLine("> ", history)
+	}
+	check := func(input string, expected string, history ...string) {
+		t.Helper()
+		line, err := readLine(input, history...)
+		assertNoError(t, err, "readLine")
+		assertEqual(t, expected, line, "Line for "+strings.ReplaceAll(input, "\x1b", "ESC"))
+	}
+
+	check("print 1;\r", "print 1;")
+	check("print 1\x1b[D\x1b[D\x1b[C+\r", "print +1")
+	check("abc\x01X\x05Y\r", "XabcY")
+	check("abcdef\x02\x02\x02\x0b\r", "abc")
+	check("abc def\x17\r", "abc ")
+	check("abc def\x02\x02\x15\r", "ef")
+	check("abc\x7f\x08\r", "a")
+	check("abc\x01\x1b[3~\x04\r", "c")
+	check("ab\x1b[H1\x1b[F2\r", "1ab2")
+	check("héllo\x1b[D\x1b[D\x7f\r", "hélo")
+	check("\x1b[A\r", "second", "first", "second")
+	check("\x1b[A\x1b[A\r", "first", "first", "second")
+	check("new\x1b[A\x1b[B\r", "new", "first")
+	check("\x10x\r", "firstx", "first")
+	check("unterminated", "unterminated")
+
+	_, err := readLine("abc\x03")
+	assertEqual(t, errInterrupted, err, "Ctrl-C")
+	_, err = readLine("\x04")
+	assertEqual(t, io.EOF, err, "Ctrl-D")
+	_, err = readLine("")
+	assertEqual(t, io.EOF, err, "End of input")
+}
+
+func TestReplCommands(t *testing.T) {
+	t.Run("Environment and reset", func(t *testing.T) {
+		repl := newTestRepl()
+		output := runReplSession(t, repl, "var a = 1;\nclass P { init() { this.x = 2; } }\nvar p = P();\n:env\n:reset\n:env\nprint a;\n")
+		assertContains(t, output, "a = 1\nargs = <native fn>\narity = <native fn>\nclassOf = <native fn>\nclock = <native fn>\nfields = <native fn>\ngetField = <native fn>\nhasField = <native fn>\nmethods = <native fn>\nname = <native fn>\np = P instance {x = 2}\nsetField = <native fn>\nsuperclassOf = <native fn>\ntype = <native fn>\n")
+		assertContains(t, output, "> args = <native fn>\narity = <native fn>\nclassOf = <native fn>\nclock = <native fn>\nfields = <native fn>\ngetField = <native fn>\nhasField = <native fn>\nmethods = <native fn>\nname = <native fn>\nsetField = <native fn>\nsuperclassOf = <native fn>\ntype = <native fn>\n> > ")
+	})
+
+	t.Run("Type, AST and tokens", func(t *testing.T) {
+		repl := newTestRepl()
+		output := runReplSession(t, repl, "class P {}\n:type 1 + 2\n:type P\n:type P()\n:type \"a\" + \"b\"\n:ast 1 + 2\n:tokens a.b\n:type )\n")
+		assertContains(t, output, "> number\n> class\n> instance of P\n> string\n")
+		assertContains(t, output, "(binary +@1:3 (literal 1) (literal 2))")
+		assertContains(t, output, "IDENTIFIER")
+		assertContains(t, output, "Error: ")
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		output := runReplSession(t, newTestRepl(), "fun f() { return 42; }\n:time f()\n:time f\n")
+		assertContains(t, output, "> 42\n(")
+		assertContains(t, output, "> <fn f>\n(")
+	})
+
+	t.Run("Load and save", func(t *testing.T) {
+		dir := t.TempDir()
+		library := filepath.Join(dir, "library.lox")
+		writeTestFile(t, library, "fun double(n) { return 2 * n; }\n")
+		saved := filepath.Join(dir, "session.lox")
+
+		repl := newTestRepl()
+		output := runReplSession(t, repl, ":load "+library+"\nprint double(4);\nprint ;\n:save "+saved+"\n:load missing.lox\n")
+		assertContains(t, output, "> 8\n")
+		assertContains(t, output, "Saved 2 entries to "+saved)
+		assertContains(t, output, "Can't load missing.lox")
+
+		data, err := os.ReadFile(saved)
+		assertNoError(t, err, "Read saved session")
+		assertEqual(t, "fun double(n) { return 2 * n; }\nprint double(4);\n", string(data), "Saved session")
+	})
+
+	t.Run("Help, usage and quit", func(t *testing.T) {
+		output := runReplSession(t, newTestRepl(), ":help\n:load\n:nope\n:quit\nprint 1;\n")
+		assertContains(t, output, ":tokens expr")
+		assertContains(t, output, "Usage: :load file.lox\n")
+		assertContains(t, output, "Unknown command ':nope'")
+		assertFalsy(t, strings.Contains(output, "1\n"), "Ran an entry after :quit")
+	})
+}
+
+func TestReplCompletion(t *testing.T) {
+	repl := newTestRepl()
+	runReplSession(t, repl, "class Base { hello() {} }\nclass P < Base { init() { this.inner = P2(); } greet() {} class make() { return P(); } }\nP.origin = 0;\nclass P2 { init() { this.value = 1; } }\nvar point = P();\nvar pointer = 1;\n")
+
+	check := func(line string, expectedStart int, expected ...string) {
+		t.Helper()
+		start, candidates := repl.complete(line, len([]rune(line)))
+		assertEqual(t, expectedStart, start, "Start for "+line)
+		if expected == nil {
+			expected = []string{}
+		}
+		assertEqual(t, expected, candidates, "Candidates for "+line)
+	}
+
+	check("wh", 0, "while")
+	check("print poi", 6, "point", "pointer")
+	check("point.", 6, "greet", "hello", "inner")
+	check("point.g", 6, "greet")
+	check("point.inner.v", 12, "value")
+	check("pointer.", 8)
+	check("P.", 2, "make", "origin")
+	check("(1).", 4)
+	check(":lo", 0, ":load")
+	check("x", 0)
+
+	editor := NewLineEditor(strings.NewReader("print poi\te\t\r"), io.Discard)
+	editor.complete = repl.complete
+	line, err := editor.readLine("> ", nil)
+	assertNoError(t, err, "readLine")
+	assertEqual(t, "print pointer", line, "Completed line")
+
+	var out strings.Builder
+	editor = NewLineEditor(strings.NewReader("point\t\tx\r"), &out)
+	editor.complete = repl.complete
+	line, _ = editor.readLine("> ", nil)
+	assertEqual(t, "pointx", line, "Ambiguous completion")
+	assertContains(t, out.String(), "\r\npoint  pointer\r\n")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 )
 
 type functionType int
@@ -19,6 +20,7 @@ const (
 	classTypeNone classType = iota
 	classTypeClass
 	classTypeSubclass
+	classTypeTrait
 )
 
 type variableStatus int
@@ -30,8 +32,9 @@ const (
 )
 
 type varDecl struct {
-	token  Token
-	status variableStatus
+	token       Token
+	status      variableStatus
+	isParameter bool
 }
 
 type Resolver struct {
@@ -40,6 +43,20 @@ type Resolver struct {
 	scopes          []map[string]*varDecl
 	currentFunctionType functionType
 	currentClassType classType
+	// currentClass is the class whose body is being resolved, which is the only place
+	// its private members can be accessed
+	currentClass    *ClassStmt
+	linter          *Linter
+	// globals holds the names of all variables declared in the global scope, used
+	// to check assignments to undeclared globals
+	globals         map[string]bool
+	// If symbols is set, declarations and the references to them are recorded in it,
+	// for use by editor tooling
+	symbols         *SymbolTable
+	// classes and traits hold the class and trait declarations resolved so far, by
+	// name, used to check that concrete classes implement inherited abstract methods
+	classes         map[string]*ClassStmt
+	traits          map[string]*TraitStmt
 }
 
 func NewResolver(runtime LoxRuntime, interpreter *Interpreter) *Resolver {
@@ -49,15 +66,30 @@ func NewResolver(runtime LoxRuntime, interpreter *Interpreter) *Resolver {
 		scopes:          make([]map[string]*varDecl, 0),
 		currentFunctionType: functionTypeNone,
 		currentClassType: classTypeNone,
+		linter:          NewLinter(runtime, nil, nil, nil),
+		globals:         make(map[string]bool),
+		classes:         make(map[string]*ClassStmt),
+		traits:          make(map[string]*TraitStmt),
 	}
 }
 
 func (r *Resolver) resolveStmts(statements []Stmt) error {
+	// Globals can be referenced before they're declared (eg inside a function body), so
+	// collect all global declarations up-front
+	isTopLevel := len(r.scopes) == 0
+	if isTopLevel {
+		r.collectGlobals(statements)
+	}
+
 	for _, stmt := range statements {
 		if err := r.resolveStmt(stmt); err != nil {
 			return err
 		}
 	}
+
+	if isTopLevel && r.symbols != nil {
+		r.symbols.resolveGlobals()
+	}
 	return nil
 }
 
@@ -82,12 +114,16 @@ func (r *Resolver) VisitClassStmt(stmt *ClassStmt) error {
 
 	enclosingClass := r.currentClassType
 	r.currentClassType = classTypeClass 
+	enclosingClassStmt := r.currentClass
+	r.currentClass = stmt
+	defer func() { r.currentClass = enclosingClassStmt }()
 
 	// Declare and define class itself 
 	if err := r.declare(stmt.className); err != nil {
 		return err
 	}
 	r.define(stmt.className)
+	r.recordDeclaration(stmt.className, symbolClass, classSignature(stmt))
 
 	// Handle superclass, if any
 	if stmt.superclass != nil {
@@ -108,21 +144,47 @@ func (r *Resolver) VisitClassStmt(stmt *ClassStmt) error {
 		r.injectSuper()
 	}
 
+	for _, trait := range stmt.traits {
+		if err := r.resolveExpr(trait); err != nil {
+			return err
+		}
+	}
+
 	// Start a new scope for class methods, and inject 'this', so class methods 
 	// have access to it 
 	r.beginScope()
 	r.injectThis()
 
+	// Field initializers are evaluated with 'this' bound to the new instance, so
+	// they're resolved in the same scope as the methods
+	if err := r.resolveFields(stmt); err != nil {
+		r.endScope()
+		return err
+	}
+
 	// Declare and define class methods
 	methodNames := make(map[string]bool)
 	for _, method := range stmt.methods {
-		// Prevent multiple declarations of methods with the same name 
-		if _, ok := methodNames[method.functionName.lexeme]; ok {
-			_ = r.endScope()
+		// Prevent multiple declarations of methods with the same name. A property can
+		// have a setter as well as a getter.
+		if _, ok := methodNames[methodKey(method)]; ok {
+			r.endScope()
 			r.runtime.parseError(method.functionName,"method with this name already exists")
 			return fmt.Errorf("method with name %s already exists", method.functionName.lexeme)
 		} else {
-			methodNames[method.functionName.lexeme] = true 
+			methodNames[methodKey(method)] = true 
+		}
+		if err := r.checkSetter(method); err != nil {
+			r.endScope()
+			return err
+		}
+		// Abstract methods have no body to resolve
+		if method.isAbstract {
+			if err := r.checkAbstract(stmt, method); err != nil {
+				r.endScope()
+				return err
+			}
+			continue
 		}
 
 		fnType := functionTypeMethod 
@@ -134,22 +196,145 @@ func (r *Resolver) VisitClassStmt(stmt *ClassStmt) error {
 		}
 	}
 
-	// End method scope 
-	if err := r.endScope(); err != nil {
-		return err 
+	// Class methods are resolved the same way, with 'this' referring to the class.
+	// They can have the same names as instance methods.
+	classMethodNames := make(map[string]bool)
+	for _, method := range stmt.classMethods {
+		if classMethodNames[methodKey(method)] {
+			r.endScope()
+			r.runtime.parseError(method.functionName, "class method with this name already exists")
+			return fmt.Errorf("class method with name %s already exists", method.functionName.lexeme)
+		}
+		classMethodNames[methodKey(method)] = true
+		if err := r.checkSetter(method); err != nil {
+			r.endScope()
+			return err
+		}
+		if method.isAbstract {
+			r.endScope()
+			r.runtime.parseError(method.functionName, "Class methods can't be abstract.")
+			return fmt.Errorf("class method %s can't be abstract", method.functionName.lexeme)
+		}
+		if err := r.resolveFunction(method, functionTypeMethod); err != nil {
+			return err
+		}
 	}
 
+	// End method scope 
+	r.endScope()
+
 	// End 'super' scope, if necessary
 	if stmt.superclass != nil {
-		if err := r.endScope(); err != nil {
-			return err 
-		}
+		r.endScope()
 	}
 
+	r.classes[stmt.className.lexeme] = stmt
+	r.checkAbstractImplemented(stmt)
+
 	r.currentClassType = enclosingClass
 	return nil
 }
 
+func (r *Resolver) VisitTraitStmt(stmt *TraitStmt) error {
+	enclosingClass := r.currentClassType
+	r.currentClassType = classTypeTrait
+	enclosingClassStmt := r.currentClass
+	r.currentClass = nil
+	defer func() {
+		r.currentClassType = enclosingClass
+		r.currentClass = enclosingClassStmt
+	}()
+
+	if err := r.declare(stmt.traitName); err != nil {
+		return err
+	}
+	r.define(stmt.traitName)
+	r.recordDeclaration(stmt.traitName, symbolTrait, "trait "+stmt.traitName.lexeme)
+	r.traits[stmt.traitName.lexeme] = stmt
+
+	// Trait methods are resolved like the methods of a subclass: 'super' refers to
+	// the superclass of whichever class the trait is used by
+	r.beginScope()
+	r.injectSuper()
+	r.beginScope()
+	r.injectThis()
+	defer func() {
+		r.endScope()
+		r.endScope()
+	}()
+
+	methodNames := make(map[string]bool)
+	for _, method := range stmt.methods {
+		if methodNames[methodKey(method)] {
+			r.runtime.parseError(method.functionName, "method with this name already exists")
+			return fmt.Errorf("method with name %s already exists", method.functionName.lexeme)
+		}
+		methodNames[methodKey(method)] = true
+		if err := r.checkSetter(method); err != nil {
+			return err
+		}
+		if method.isAbstract {
+			r.runtime.parseError(method.functionName, "Trait methods can't be abstract.")
+			return fmt.Errorf("trait method %s can't be abstract", method.functionName.lexeme)
+		}
+
+		fnType := functionTypeMethod
+		if method.functionName.lexeme == "init" {
+			fnType = functionTypeInitializer
+		}
+		if err := r.resolveFunction(method, fnType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveFields checks that each declared field has a unique name that isn't also
+// the name of a method, and resolves the field initializers
+func (r *Resolver) resolveFields(stmt *ClassStmt) error {
+	methodNames := make(map[string]bool)
+	for _, method := range stmt.methods {
+		methodNames[method.functionName.lexeme] = true
+	}
+	fieldNames := make(map[string]bool)
+	for _, field := range stmt.fields {
+		name := field.variable.lexeme
+		if fieldNames[name] {
+			r.runtime.parseError(field.variable, "field with this name already exists")
+			return fmt.Errorf("field with name %s already exists", name)
+		}
+		fieldNames[name] = true
+		if methodNames[name] {
+			r.runtime.parseError(field.variable, "A field can't have the same name as a method.")
+			return fmt.Errorf("field %s has the same name as a method", name)
+		}
+		if field.initializer != nil {
+			if err := r.resolveExpr(field.initializer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// methodKey is the name a method is declared under: setters are kept apart from
+// other methods
+func methodKey(method *FunctionStmt) string {
+	if method.isSetter {
+		return "set " + method.functionName.lexeme
+	}
+	return method.functionName.lexeme
+}
+
+// checkSetter checks that a setter takes the value being assigned, and nothing else
+func (r *Resolver) checkSetter(method *FunctionStmt) error {
+	if method.isSetter && len(method.params) != 1 {
+		r.runtime.parseError(method.functionName, "A setter must have exactly one parameter.")
+		return fmt.Errorf("setter %s must have exactly one parameter", method.functionName.lexeme)
+	}
+	return nil
+}
+
 func (r *Resolver) VisitExpressionStmt(stmt *ExpressionStmt) error {
 	return r.resolveExpr(stmt.expression)
 }
@@ -203,6 +388,7 @@ func (r *Resolver) VisitVarStmt(stmt *VarStmt) error {
 	if err := r.declare(stmt.variable); err != nil {
 		return err
 	}
+	r.recordDeclaration(stmt.variable, symbolVariable, "var "+stmt.variable.lexeme)
 
 	if stmt.initializer != nil {
 		if err := r.resolveExpr(stmt.initializer); err != nil {
@@ -230,7 +416,13 @@ func (r *Resolver) VisitAssignExpr(expr *AssignExpr) (any, error) {
 	if err := r.resolveExpr(expr.value); err != nil {
 		return nil, err
 	}
-	r.resolveLocal(expr, expr.variable)
+	if err := r.checkNotPrivate(expr.variable); err != nil {
+		return nil, err
+	}
+	if !r.resolveLocal(expr, expr.variable) && !r.isGlobal(expr.variable.lexeme) {
+		r.linter.report(lintUndeclaredGlobal, expr.variable,
+			fmt.Sprintf("Assignment to undeclared variable '%s'", expr.variable.lexeme))
+	}
 	return nil, nil
 }
 
@@ -241,6 +433,13 @@ func (r *Resolver) VisitBinaryExpr(expr *BinaryExpr) (any, error) {
 	if err := r.resolveExpr(expr.Right); err != nil {
 		return nil, err
 	}
+
+	switch expr.Operator.token_type {
+	case EQUAL_EQUAL, BANG_EQUAL, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		if sameOperand(expr.Left, expr.Right) {
+			r.linter.report(lintSelfComparison, expr.Operator, "Comparison of an expression with itself")
+		}
+	}
 	return nil, nil
 }
 
@@ -258,11 +457,33 @@ func (r *Resolver) VisitCallExpr(expr *CallExpr) (any, error) {
 	return nil, nil
 }
 
+func (r *Resolver) VisitInnerExpr(expr *InnerExpr) (any, error) {
+	// inner() refines the method it's called in, so it can't be used in a function
+	// nested in a method, or in a field initializer
+	if r.currentFunctionType != functionTypeMethod && r.currentFunctionType != functionTypeInitializer {
+		r.runtime.parseError(expr.keyword, "Can't use 'inner' outside of a method.")
+		return nil, fmt.Errorf("can't use 'inner' outside of a method")
+	}
+	for _, arg := range expr.arguments {
+		if err := r.resolveExpr(arg); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *IndexExpr) (any, error) {
+	if err := r.resolveExpr(expr.object); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveExpr(expr.index)
+}
+
 func (r *Resolver) VisitPropGetExpr(p *PropGetExpr) (any, error) {
 	if err := r.resolveExpr(p.object); err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return nil, r.resolvePrivateAccess(p, p.object, p.propName)
 }
 
 func (r *Resolver) VisitPropSetExpr(p *PropSetExpr) (any, error) {
@@ -274,7 +495,39 @@ func (r *Resolver) VisitPropSetExpr(p *PropSetExpr) (any, error) {
 		return nil, err
 	}
 
-	return nil, nil
+	return nil, r.resolvePrivateAccess(p, p.object, p.propName)
+}
+
+// resolvePrivateAccess checks that a private member is only accessed through 'this'
+// inside a class body, and tells the interpreter which class the access is in
+func (r *Resolver) resolvePrivateAccess(expr Expr, object Expr, name Token) error {
+	if !isPrivateName(name.lexeme) {
+		return nil
+	}
+	if r.currentClassType == classTypeTrait {
+		r.runtime.parseError(name, fmt.Sprintf("Can't access private member '%s' in a trait.", name.lexeme))
+		return fmt.Errorf("private member %s accessed in a trait", name.lexeme)
+	}
+	if r.currentClass == nil {
+		r.runtime.parseError(name, fmt.Sprintf("Can't access private member '%s' outside of a class.", name.lexeme))
+		return fmt.Errorf("private member %s accessed outside of a class", name.lexeme)
+	}
+	if _, isThis := object.(*ThisExpr); !isThis {
+		r.runtime.parseError(name, fmt.Sprintf("Private member '%s' can only be accessed through 'this'.", name.lexeme))
+		return fmt.Errorf("private member %s accessed through something other than this", name.lexeme)
+	}
+	r.interpreter.privateAccess[expr] = r.currentClass
+	return nil
+}
+
+// checkNotPrivate reports an error if a private name is used for a variable,
+// function, class or parameter
+func (r *Resolver) checkNotPrivate(name Token) error {
+	if isPrivateName(name.lexeme) {
+		r.runtime.parseError(name, "Private names can only be used for class members.")
+		return fmt.Errorf("private name %s used outside a class", name.lexeme)
+	}
+	return nil
 }
 
 func (r *Resolver) VisitThisExpr(t *ThisExpr) (any, error) {
@@ -299,6 +552,10 @@ func (r *Resolver) VisitSuperExpr(s *SuperExpr) (any, error) {
 		return nil, fmt.Errorf("Can't use 'super' in a class with no superclass")
 
 	}
+	if isPrivateName(s.method.lexeme) {
+		r.runtime.parseError(s.method, fmt.Sprintf("Private member '%s' can't be accessed through 'super'.", s.method.lexeme))
+		return nil, fmt.Errorf("private member %s accessed through super", s.method.lexeme)
+	}
 
 	r.resolveLocal(s, s.keyword)
 	return nil, nil 
@@ -327,6 +584,9 @@ func (r *Resolver) VisitUnaryExpr(expr *UnaryExpr) (any, error) {
 }
 
 func (r *Resolver) VisitVariableExpr(expr *VariableExpr) (any, error) {
+	if err := r.checkNotPrivate(expr.variable); err != nil {
+		return nil, err
+	}
 
 	// Check that variable isn't being referenced while still in its initializer ie
 	// while it's been declared, but not yet defined
@@ -351,6 +611,7 @@ func (r *Resolver) VisitFunctionStmt(stmt *FunctionStmt) error {
 		return err
 	}
 	r.define(stmt.functionName)
+	r.recordDeclaration(stmt.functionName, symbolFunction, functionSignature(stmt))
 	return r.resolveFunction(stmt, functionTypeFunction)
 }
 
@@ -365,44 +626,66 @@ func (r *Resolver) resolveFunction(function *FunctionStmt, fnType functionType)
 
 	// If it's a getter function, need to be inside a class
 	if function.isGetter && r.currentClassType == classTypeNone {
-		_ = r.endScope() // might return error, but already in error case
+		r.endScope()
 		r.runtime.parseError(function.functionName,"getter function has to be inside a class")
 		return fmt.Errorf("getter function has to be inside a class")
 	}
 
 	for _, param := range function.params {
 		if err = r.declare(param); err != nil {
-			_ = r.endScope() // might return error, but already in error case
+			r.endScope()
 			return err
 		}
 		r.define(param)
+		r.scopes[len(r.scopes)-1][param.lexeme].isParameter = true
+		r.recordDeclaration(param, symbolParameter, param.lexeme+" (parameter of "+function.functionName.lexeme+")")
 	}
 	if err = r.resolveStmts(function.body); err != nil {
-		_ = r.endScope() // might return error, but already in error case
+		r.endScope()
 		return err
 	}
-	err = r.endScope()
+	r.endScope()
 
 	r.currentFunctionType = enclosingFunction
-	return err
+	return nil
 }
 
-func (r *Resolver) resolveLocal(expr Expr, token Token) {
+func (r *Resolver) resolveLocal(expr Expr, token Token) bool {
 	// Figure out distance from currently-active scope to scope where 
 	// the supplied Expr is defined, and communicate this distance to the interpreter,
-	// for use at execution time
+	// for use at execution time. Returns false if the Expr isn't a local ie it's
+	// assumed to be a global.
 	for i := len(r.scopes) - 1; i >= 0; i-- {
 		if variable, ok := r.scopes[i][token.lexeme]; ok {
 			variable.status = isUsed // to keep track of used/unused variables
 			r.interpreter.resolve(expr, len(r.scopes)-1-i)
-			return
+			if r.symbols != nil {
+				r.symbols.reference(token, variable.token)
+			}
+			return true
 		}
 	}
+
+	if r.symbols != nil {
+		r.symbols.unresolvedReference(token)
+	}
+	return false
+}
+
+// recordDeclaration adds a declaration to the symbol table, if one is being built
+func (r *Resolver) recordDeclaration(token Token, kind symbolKind, detail string) {
+	if r.symbols != nil {
+		r.symbols.declare(token, kind, detail, len(r.scopes) == 0)
+	}
 }
 
 func (r *Resolver) declare(token Token) error {
+	if err := r.checkNotPrivate(token); err != nil {
+		return err
+	}
 
 	if len(r.scopes) == 0 { // currently in global scope, don't need to declare it
+		r.globals[token.lexeme] = true
 		return nil
 	}
 
@@ -415,6 +698,13 @@ func (r *Resolver) declare(token Token) error {
 		return fmt.Errorf("resolver error")
 	}
 
+	// Declaring a variable with the same name as one in an enclosing scope is legal,
+	// but potentially confusing
+	if r.isShadowing(token.lexeme) {
+		r.linter.report(lintShadowing, token,
+			fmt.Sprintf("Declaration of '%s' shadows a variable in an enclosing scope", token.lexeme))
+	}
+
 	current_scope[token.lexeme] = &varDecl{token: token, status: isDeclared}
 
 	return nil
@@ -433,33 +723,82 @@ func (r *Resolver) beginScope() {
 	r.scopes = append(r.scopes, make(map[string]*varDecl))
 }
 
-func (r *Resolver) endScope() error {
+func (r *Resolver) endScope() {
 
 	if len(r.scopes) > 0 {
-		// Check that all variables defined in this scope were actually used
+		// Check that all variables defined in this scope were actually used, reporting
+		// them in source order
+		unused := make([]*varDecl, 0)
 		for _, v := range r.scopes[len(r.scopes)-1] {
 			if v.status != isUsed {
-				r.runtime.parseError(v.token, "Unused variable")
-				return fmt.Errorf("unused variable")
+				unused = append(unused, v)
+			}
+		}
+		sort.Slice(unused, func(a, b int) bool {
+			return unused[a].token.line < unused[b].token.line ||
+				(unused[a].token.line == unused[b].token.line && unused[a].token.lexeme < unused[b].token.lexeme)
+		})
+		for _, v := range unused {
+			if v.isParameter {
+				r.linter.report(lintUnusedParameter, v.token,
+					fmt.Sprintf("Unused variable '%s' (function parameter)", v.token.lexeme))
+			} else {
+				r.linter.report(lintUnusedLocal, v.token,
+					fmt.Sprintf("Unused variable '%s'", v.token.lexeme))
 			}
 		}
 
 		r.scopes = r.scopes[:len(r.scopes)-1] // Pop top scope off the stack
 	}
+}
 
-	return nil
+// collectGlobals records the names of all variables, functions and classes declared
+// at the top level of a program
+func (r *Resolver) collectGlobals(statements []Stmt) {
+	for _, stmt := range statements {
+		switch s := stmt.(type) {
+		case *VarStmt:
+			r.globals[s.variable.lexeme] = true
+		case *FunctionStmt:
+			r.globals[s.functionName.lexeme] = true
+		case *ClassStmt:
+			r.globals[s.className.lexeme] = true
+		}
+	}
+}
+
+// isGlobal reports whether there's a global variable with the supplied name, either
+// declared in the code being resolved or already defined in the interpreter (eg
+// native functions, or variables declared earlier in a REPL session)
+func (r *Resolver) isGlobal(name string) bool {
+	if r.globals[name] {
+		return true
+	}
+	_, ok := r.interpreter.globalEnv.values[name]
+	return ok
+}
+
+// isShadowing reports whether a variable being declared in the current scope hides
+// a variable with the same name in an enclosing scope
+func (r *Resolver) isShadowing(name string) bool {
+	for i := len(r.scopes) - 2; i >= 0; i-- {
+		if _, ok := r.scopes[i][name]; ok {
+			return true
+		}
+	}
+	return r.isGlobal(name)
 }
 
 // injectThis defines 'this' as a local variable in the current scope
 func (r *Resolver) injectThis() {
 	currentScope := r.scopes[len(r.scopes) - 1]
-	dummyThisToken := Token{THIS, "this", nil, 0}
-	currentScope["this"] = &varDecl{dummyThisToken, isUsed}
+	dummyThisToken := Token{THIS, "this", nil, 0, 0, 0}
+	currentScope["this"] = &varDecl{token: dummyThisToken, status: isUsed}
 }
 
 // injectSuper defines 'super' as a local variable in the current scope
 func (r *Resolver) injectSuper() {
 	currentScope := r.scopes[len(r.scopes) - 1]
-	dummySuperToken := Token{SUPER, "super", nil, 0}
-	currentScope["super"] = &varDecl{dummySuperToken, isUsed}
+	dummySuperToken := Token{SUPER, "super", nil, 0, 0, 0}
+	currentScope["super"] = &varDecl{token: dummySuperToken, status: isUsed}
 }
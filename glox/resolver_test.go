@@ -87,22 +87,22 @@ fun test(a) {
 	t.Run("Unused local variable in block", func(t *testing.T) {
 		program := `
 {
-  var unused = "value"; // Error: Unused variable
+  var unused = "value"; // Warning: Unused variable
 }
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Unused local variable in block")
+		runProgramAndExpectWarning(t, program, "Unused variable", "Unused local variable in block")
 	})
 
 	t.Run("Unused local variable in function", func(t *testing.T) {
 		program := `
 fun test() {
-  var unused = "value"; // Error: Unused variable
+  var unused = "value"; // Warning: Unused variable
 }
 test();
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Unused local variable in function")
+		runProgramAndExpectWarning(t, program, "Unused variable", "Unused local variable in function")
 	})
 
 	t.Run("Unused variable in nested block", func(t *testing.T) {
@@ -110,46 +110,47 @@ test();
 {
   var used = "outer";
   {
-    var unused = "inner"; // Error: Unused variable
+    var unused = "inner"; // Warning: Unused variable
     print used;
   }
 }
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Unused variable in nested block")
+		runProgramAndExpectWarning(t, program, "Unused variable", "Unused variable in nested block")
 	})
 
 	t.Run("Multiple unused variables", func(t *testing.T) {
 		program := `
 {
-  var unused1 = "first"; // Error: Unused variable
-  var unused2 = "second"; // Error: Unused variable
+  var unused1 = "first"; // Warning: Unused variable
+  var unused2 = "second"; // Warning: Unused variable
 }
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Multiple unused variables")
+		runProgramAndExpectWarning(t, program, "Unused variable", "Multiple unused variables")
 	})
 
-	t.Run("Unused function parameter", func(t *testing.T) {
+	t.Run("Unused function parameter is only a warning", func(t *testing.T) {
 		program := `
-fun test(param) { // Error: Unused variable
+fun test(param) { // Warning: Unused variable
   print "hello";
 }
 test("arg");
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Unused function parameter")
+		expected := []string{"hello"}
+		runProgramAndCheckOutput(t, program, expected, "Unused function parameter")
 	})
 
 	t.Run("Unused variable that shadows global", func(t *testing.T) {
 		program := `
 var global = "global";
 {
-  var global = "local"; // Error: Unused variable (even though it shadows)
+  var global = "local"; // Warning: Unused variable (even though it shadows)
 }
 `
 
-		runProgramAndExpectError(t, program, "Unused variable", "Unused variable that shadows global")
+		runProgramAndExpectWarning(t, program, "Unused variable", "Unused variable that shadows global")
 	})
 }
 
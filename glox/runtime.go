@@ -12,4 +12,8 @@ type LoxRuntime interface {
 
 	// runtimeError reports a runtime error that occurred during execution
 	runtimeError(err error)
+
+	// warning reports a problem at the specified token that doesn't prevent
+	// the program from running
+	warning(token Token, message string)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -32,6 +33,27 @@ type Scanner struct {
 	start        int
 	current      int
 	line         int
+	lineStart    int // offset of the first character of the current line
+	startLine    int // line of the token currently being scanned
+	startColumn  int // column of the token currently being scanned
+	// lintDirectives maps source lines to the rules named in 'lox:ignore' comments
+	// on those lines
+	lintDirectives map[int]lintDirective
+	// If keepComments is set, comments are collected in comments rather than being
+	// discarded. They are never added to the token stream.
+	keepComments bool
+	comments     []Token
+	// errors records scanning errors, along with their position in the token stream
+	errors       []scanError
+}
+
+// scanError is an error found while scanning, such as an unexpected character
+type scanError struct {
+	line       int
+	column     int
+	lexeme     string // source text that caused the error
+	message    string
+	tokenIndex int // number of tokens scanned before the error was found
 }
 
 func NewScanner(lox LoxRuntime, source string) Scanner {
@@ -40,17 +62,31 @@ func NewScanner(lox LoxRuntime, source string) Scanner {
 		source:       source,
 		source_runes: []rune(source),
 		line:         1,
+		lintDirectives: make(map[int]lintDirective),
 	}
 	return s
 }
 
 func (s *Scanner) scanTokens() []Token {
+	// A script can start with a #! line, so that it can be run directly on Unix. The
+	// line is treated as a comment.
+	if strings.HasPrefix(s.source, "#!") {
+		for s.peek() != '\n' && !s.isAtEnd() {
+			s.advance()
+		}
+		if s.keepComments {
+			s.comments = append(s.comments, Token{COMMENT, string(s.source_runes[:s.current]), nil, 1, 1, 1})
+		}
+	}
+
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.startColumn = s.current - s.lineStart + 1
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, Token{EOF, "", nil, s.line})
+	s.tokens = append(s.tokens, Token{EOF, "", nil, s.line, s.current - s.lineStart + 1, s.line})
 	return s.tokens
 }
 
@@ -65,6 +101,10 @@ func (s *Scanner) scanToken() {
 		s.addToken(LEFT_BRACE)
 	case '}':
 		s.addToken(RIGHT_BRACE)
+	case '[':
+		s.addToken(LEFT_BRACKET)
+	case ']':
+		s.addToken(RIGHT_BRACKET)
 	case ',':
 		s.addToken(COMMA)
 	case '.':
@@ -107,6 +147,17 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+
+			// Comments can carry directives to suppress lint warnings, which
+			// need to be kept around for the resolver
+			comment := string(s.source_runes[s.start:s.current])
+			if rules, ok := parseLintDirective(comment); ok {
+				trailing := len(s.tokens) > 0 && s.tokens[len(s.tokens)-1].line == s.line
+				s.lintDirectives[s.line] = lintDirective{rules, trailing}
+			}
+			if s.keepComments {
+				s.comments = append(s.comments, Token{COMMENT, comment, nil, s.line, s.startColumn, s.startLine})
+			}
 		} else {
 			s.addToken(SLASH)
 		}
@@ -119,17 +170,25 @@ func (s *Scanner) scanToken() {
 		// skip whitespace
 	case '\n':
 		s.line += 1
+		s.lineStart = s.current
 
 	case '"': // start of a string
 		s.scanString()
 
+	case '#': // start of a private member name eg #count
+		if s.isAlpha(s.peek()) {
+			s.scanIdentifier()
+		} else {
+			s.reportError("Unexpected character")
+		}
+
 	default:
 		if s.isDigit(c) {
 			s.scanNumber()
 		} else if s.isAlpha(c) {
 			s.scanIdentifier()
 		} else {
-			s.lox.error(s.line, "Unexpected character")
+			s.reportError("Unexpected character")
 		}
 	}
 }
@@ -176,12 +235,13 @@ func (s *Scanner) scanString() {
 	for s.peek() != '"' && !s.isAtEnd() {
 		if s.peek() == '\n' { // multi-line strings are ok
 			s.line++
+			s.lineStart = s.current + 1
 		}
 		s.advance()
 	}
 
 	if s.isAtEnd() {
-		s.lox.error(s.line, "Unterminated string")
+		s.reportError("Unterminated string")
 		return
 	}
 
@@ -193,6 +253,17 @@ func (s *Scanner) scanString() {
 	s.addLiteralToken(STRING, value)
 }
 
+func (s *Scanner) reportError(message string) {
+	s.errors = append(s.errors, scanError{
+		line:       s.startLine,
+		column:     s.startColumn,
+		lexeme:     string(s.source_runes[s.start:s.current]),
+		message:    message,
+		tokenIndex: len(s.tokens),
+	})
+	s.lox.error(s.line, message)
+}
+
 func (s *Scanner) match(expected rune) bool {
 	if s.isAtEnd() {
 		return false
@@ -244,10 +315,10 @@ func (s *Scanner) advance() rune {
 
 func (s *Scanner) addToken(tokenType TokenType) {
 	text := string(s.source_runes[s.start:s.current])
-	s.tokens = append(s.tokens, Token{tokenType, text, nil, s.line})
+	s.tokens = append(s.tokens, Token{tokenType, text, nil, s.line, s.startColumn, s.startLine})
 }
 
 func (s *Scanner) addLiteralToken(tokenType TokenType, literal any) {
 	text := string(s.source_runes[s.start:s.current])
-	s.tokens = append(s.tokens, Token{tokenType, text, literal, s.line})
+	s.tokens = append(s.tokens, Token{tokenType, text, literal, s.line, s.startColumn, s.startLine})
 }
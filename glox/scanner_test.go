@@ -24,6 +24,8 @@ func TestScannerSingleCharacterTokens(t *testing.T) {
 		{")", RIGHT_PAREN, ")"},
 		{"{", LEFT_BRACE, "{"},
 		{"}", RIGHT_BRACE, "}"},
+		{"[", LEFT_BRACKET, "["},
+		{"]", RIGHT_BRACKET, "]"},
 		{",", COMMA, ","},
 		{".", DOT, "."},
 		{"-", MINUS, "-"},
@@ -252,6 +254,18 @@ world"`
 	compareTokens(t, expected, tokens, "Multi-line string")
 }
 
+func TestScannerStartLines(t *testing.T) {
+	scanner := NewScanner(NewTestGLox(), "var a = \"x\nyy\";")
+	tokens := scanner.scanTokens()
+
+	str := tokens[3]
+	assertEqual(t, STRING, str.token_type, "Token type")
+	assertEqual(t, 2, str.line, "Line")
+	assertEqual(t, 1, str.startLine, "Start line")
+	assertEqual(t, 9, str.column, "Column")
+	assertEqual(t, 2, tokens[4].startLine, "Start line of ;")
+}
+
 func TestScannerIdentifiers(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -343,6 +357,13 @@ func TestScannerComments(t *testing.T) {
 				createEOFToken(2),
 			},
 		},
+		{
+			"#!/usr/bin/env glox\n123",
+			[]Token{
+				createNumberToken(123.0, 2),
+				createEOFToken(2),
+			},
+		},
 	}
 
 	for i, test := range tests {
@@ -511,3 +532,24 @@ func TestScannerLineNumberTracking(t *testing.T) {
 	}
 	compareTokens(t, expected, tokens, "Line number tracking")
 }
+
+func TestScannerColumns(t *testing.T) {
+	scanner := NewScanner(NewTestGLox(), "var a = 1;\n  print \"x\ny\" + a;")
+	tokens := scanner.scanTokens()
+
+	expected := []struct {
+		lexeme string
+		column int
+	}{
+		{"var", 1}, {"a", 5}, {"=", 7}, {"1", 9}, {";", 10},
+		{"print", 3}, {"\"x\ny\"", 9}, {"+", 4}, {"a", 6}, {";", 7},
+		{"", 8},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, exp := range expected {
+		assertEqual(t, exp.lexeme, tokens[i].lexeme, "Lexeme")
+		assertEqual(t, exp.column, tokens[i].column, "Column of "+exp.lexeme)
+	}
+}
@@ -8,6 +8,7 @@ type StmtVisitor interface {
 	VisitExpressionStmt(stmt *ExpressionStmt) error
 	VisitFunctionStmt(stmt *FunctionStmt) error
 	VisitClassStmt(stmt *ClassStmt) error 
+	VisitTraitStmt(stmt *TraitStmt) error
 	VisitIfStmt(stmt *IfStmt) error
 	VisitPrintStmt(stmt *PrintStmt) error
 	VisitWhileStmt(stmt *WhileStmt) error
@@ -27,13 +28,27 @@ func (e *ExpressionStmt) Accept(visitor StmtVisitor) error {
 type ClassStmt struct {
 	className Token
 	superclass *VariableExpr
+	traits []*VariableExpr // traits listed after 'with', whose methods are copied into the class
 	methods []*FunctionStmt
+	classMethods []*FunctionStmt // methods declared with 'class', which are called on the class itself
+	fields []*VarStmt // fields declared with 'var', which are initialized on each new instance
+	sealed bool // instances of a sealed class can only have declared fields
+	isAbstract bool // an abstract class can't be instantiated
 }
 
 func (c *ClassStmt) Accept(visitor StmtVisitor) error {
 	return visitor.VisitClassStmt(c)
 }
 
+type TraitStmt struct {
+	traitName Token
+	methods []*FunctionStmt
+}
+
+func (t *TraitStmt) Accept(visitor StmtVisitor) error {
+	return visitor.VisitTraitStmt(t)
+}
+
 
 type IfStmt struct {
 	condition  Expr
@@ -44,6 +59,9 @@ type IfStmt struct {
 type FunctionStmt struct {
 	functionName Token
 	isGetter bool 
+	isSetter bool // a 'set name(value)' method, called when the property is assigned
+	isAbstract bool // an 'abstract' method, which has no body and must be implemented by subclasses
+	usesInner bool // a method that calls inner(), which subclasses refine rather than override
 	params       []Token
 	body         []Stmt
 }
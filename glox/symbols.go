@@ -0,0 +1,182 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+type symbolKind int
+
+const (
+	symbolVariable symbolKind = iota
+	symbolParameter
+	symbolFunction
+	symbolClass
+	symbolTrait
+)
+
+func (k symbolKind) String() string {
+	switch k {
+	case symbolParameter:
+		return "parameter"
+	case symbolFunction:
+		return "function"
+	case symbolClass:
+		return "class"
+	case symbolTrait:
+		return "trait"
+	default:
+		return "variable"
+	}
+}
+
+// symbolDecl is the declaration of a named variable, parameter, function, class or trait
+type symbolDecl struct {
+	token  Token
+	kind   symbolKind
+	detail string // short description of the declaration eg "fun add(a, b)"
+	global bool
+}
+
+// tokenPos identifies a token by its position in the source
+type tokenPos struct {
+	line   int
+	column int
+}
+
+func positionOf(token Token) tokenPos {
+	return tokenPos{token.line, token.column}
+}
+
+// SymbolTable records the declarations found by the Resolver, and which declaration
+// each variable reference resolves to. It's used by editor tooling eg for
+// go-to-definition.
+type SymbolTable struct {
+	declarations []*symbolDecl
+	// references maps the position of every reference to a variable (and of every
+	// declaration) to the declaration it refers to
+	references map[tokenPos]*symbolDecl
+	// unresolved holds references that aren't to local variables, which are matched
+	// against global declarations once the whole program has been resolved
+	unresolved      []Token
+	referenceTokens map[tokenPos]Token
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		declarations:    make([]*symbolDecl, 0),
+		references:      make(map[tokenPos]*symbolDecl),
+		unresolved:      make([]Token, 0),
+		referenceTokens: make(map[tokenPos]Token),
+	}
+}
+
+func (st *SymbolTable) declare(token Token, kind symbolKind, detail string, global bool) {
+	decl := &symbolDecl{token: token, kind: kind, detail: detail, global: global}
+	st.declarations = append(st.declarations, decl)
+	st.references[positionOf(token)] = decl
+	st.referenceTokens[positionOf(token)] = token
+}
+
+func (st *SymbolTable) reference(token Token, declaration Token) {
+	if declaration.line == 0 { // implicit declaration, ie 'this' or 'super'
+		return
+	}
+	if decl, ok := st.references[positionOf(declaration)]; ok {
+		st.references[positionOf(token)] = decl
+		st.referenceTokens[positionOf(token)] = token
+	}
+}
+
+func (st *SymbolTable) unresolvedReference(token Token) {
+	st.unresolved = append(st.unresolved, token)
+}
+
+// resolveGlobals matches references to non-local variables with the global
+// declaration of the same name, if there is one
+func (st *SymbolTable) resolveGlobals() {
+	globals := make(map[string]*symbolDecl)
+	for _, decl := range st.declarations {
+		if _, exists := globals[decl.token.lexeme]; decl.global && !exists {
+			globals[decl.token.lexeme] = decl
+		}
+	}
+
+	for _, token := range st.unresolved {
+		if decl, ok := globals[token.lexeme]; ok {
+			st.references[positionOf(token)] = decl
+			st.referenceTokens[positionOf(token)] = token
+		}
+	}
+	st.unresolved = st.unresolved[:0]
+}
+
+// symbolAt returns the token at the supplied position, if it's a declaration of, or
+// reference to, a known symbol, along with the symbol's declaration
+func (st *SymbolTable) symbolAt(line int, column int) (Token, *symbolDecl, bool) {
+	for pos, decl := range st.references {
+		token := st.referenceTokens[pos]
+		if token.line == line && column >= token.column && column <= token.column+len([]rune(token.lexeme)) {
+			return token, decl, true
+		}
+	}
+	return Token{}, nil, false
+}
+
+// referencesTo returns all the tokens that refer to the supplied declaration,
+// including the declaration itself, in source order
+func (st *SymbolTable) referencesTo(decl *symbolDecl) []Token {
+	tokens := make([]Token, 0)
+	for pos, d := range st.references {
+		if d == decl {
+			tokens = append(tokens, st.referenceTokens[pos])
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].line < tokens[j].line || (tokens[i].line == tokens[j].line && tokens[i].column < tokens[j].column)
+	})
+	return tokens
+}
+
+// functionSignature describes a function declaration eg "fun add(a, b)", or a
+// setter eg "set name(value)"
+func functionSignature(stmt *FunctionStmt) string {
+	prefix := ""
+	if stmt.isAbstract {
+		prefix = "abstract "
+	}
+	if stmt.isGetter {
+		return prefix + "fun " + stmt.functionName.lexeme
+	}
+	params := make([]string, 0, len(stmt.params))
+	for _, param := range stmt.params {
+		params = append(params, param.lexeme)
+	}
+	keyword := "fun "
+	if stmt.isSetter {
+		keyword = "set "
+	}
+	return prefix + keyword + stmt.functionName.lexeme + "(" + strings.Join(params, ", ") + ")"
+}
+
+// classSignature describes a class declaration eg "class B < A"
+func classSignature(stmt *ClassStmt) string {
+	signature := "class " + stmt.className.lexeme
+	if stmt.sealed {
+		signature = "sealed " + signature
+	}
+	if stmt.isAbstract {
+		signature = "abstract " + signature
+	}
+	if stmt.superclass != nil {
+		signature += " < " + stmt.superclass.variable.lexeme
+	}
+	if len(stmt.traits) > 0 {
+		traits := make([]string, 0, len(stmt.traits))
+		for _, trait := range stmt.traits {
+			traits = append(traits, trait.variable.lexeme)
+		}
+		signature += " with " + strings.Join(traits, ", ")
+	}
+	return signature
+}
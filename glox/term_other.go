@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// Line editing isn't supported on this platform, so the REPL reads plain lines
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func enableRawMode(fd uintptr) (func(), error) {
+	return nil, errors.New("raw mode isn't supported on this platform")
+}
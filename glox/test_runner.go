@@ -0,0 +1,400 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The test runner runs unit tests written in Lox. Tests are top-level functions named
+// test_* in files named *_test.lox. Each test is run in a fresh interpreter: the
+// file's top-level code is run, then the test function is called. A test fails if
+// it raises a runtime error, including by failing one of the assertion functions
+// that the runner defines.
+
+// testFile is a *_test.lox file, parsed and ready for its tests to be run
+type testFile struct {
+	path        string
+	source      string
+	statements  []Stmt
+	stmtLines   map[Stmt]int
+	diagnostics []diagnostic // errors that prevented the file from being parsed
+	tests       []*testCase
+	coverage    *fileCoverage // coverage hook, if coverage is being recorded
+}
+
+// testCase is a single test function, and the result of running it
+type testCase struct {
+	file     *testFile
+	name     string
+	declared Token // name of the test function where it's declared
+	passed   bool
+	failure  string // file:line and message of the error that failed the test
+	output   string // anything the test printed
+	duration time.Duration
+}
+
+// testRuntime records the first runtime error raised by a test
+type testRuntime struct {
+	*diagnosticCollector
+	err error
+}
+
+func (r *testRuntime) runtimeError(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// testHook keeps track of the line each frame is executing, so that a failed
+// assertion can report where it was
+type testHook struct {
+	interpreter *Interpreter
+	stmtLines   map[Stmt]int
+}
+
+func (h *testHook) beforeStatement(stmt Stmt) error {
+	if line, ok := h.stmtLines[stmt]; ok {
+		h.interpreter.frames[len(h.interpreter.frames)-1].line = line
+	}
+	return nil
+}
+
+// loadTestFile parses a test file and finds the tests in it
+func loadTestFile(path string) (*testFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &testFile{path: path, source: string(data)}
+	collector := NewDiagnosticCollector()
+	scanner := NewScanner(collector, file.source)
+	tokens := scanner.scanTokens()
+	parser := NewParser(collector, tokens)
+	file.statements, _ = parser.parse()
+	file.stmtLines = parser.stmtLines
+	if !collector.hadError() {
+		NewResolver(collector, newTestInterpreter(collector)).resolveStmts(file.statements)
+	}
+	if collector.hadError() {
+		for _, diag := range collector.diagnostics {
+			if diag.kind != diagnosticWarning {
+				file.diagnostics = append(file.diagnostics, diag)
+			}
+		}
+		return file, nil
+	}
+
+	for _, stmt := range file.statements {
+		if function, ok := stmt.(*FunctionStmt); ok &&
+			strings.HasPrefix(function.functionName.lexeme, "test_") && len(function.params) == 0 {
+			file.tests = append(file.tests, &testCase{file: file, name: function.functionName.lexeme, declared: function.functionName})
+		}
+	}
+	return file, nil
+}
+
+// newTestInterpreter creates an interpreter with the assertion functions defined
+func newTestInterpreter(runtime LoxRuntime) *Interpreter {
+	interpreter := NewInterpreter(runtime)
+	interpreter.globalEnv.defineVarValue("assert", assertFn{})
+	interpreter.globalEnv.defineVarValue("assertEqual", assertEqualFn{})
+	interpreter.globalEnv.defineVarValue("assertThrows", assertThrowsFn{})
+	return interpreter
+}
+
+// run runs a single test in a fresh interpreter
+func (t *testCase) run() {
+	start := time.Now()
+	defer func() { t.duration = time.Since(start) }()
+
+	runtime := &testRuntime{diagnosticCollector: NewDiagnosticCollector()}
+	interpreter := newTestInterpreter(runtime)
+	var output strings.Builder
+	interpreter.stdout = &output
+	var hook executionHook = &testHook{interpreter, t.file.stmtLines}
+	if t.file.coverage != nil {
+		hook = hookChain{hook, t.file.coverage}
+	}
+	interpreter.hook = hook
+
+	// The file has already been resolved once, so this can't fail
+	NewResolver(runtime.diagnosticCollector, interpreter).resolveStmts(t.file.statements)
+	interpreter.interpret(t.file.statements)
+	if runtime.err == nil {
+		// The script can assign something else to the test function's name
+		if function, ok := interpreter.globalEnv.values[t.name].(*LoxFunction); !ok {
+			runtime.err = RuntimeError{t.declared, fmt.Sprintf("%s is no longer a function, so can't be run.", t.name)}
+		} else if _, err := function.call(interpreter, nil); err != nil {
+			runtime.err = err
+		}
+	}
+
+	t.output = output.String()
+	t.passed = runtime.err == nil
+	if !t.passed {
+		line := 0
+		if runtimeErr, ok := runtime.err.(RuntimeError); ok {
+			line = runtimeErr.token.line
+		}
+		t.failure = fmt.Sprintf("%s:%d: %s", t.file.path, line, runtime.err.Error())
+	}
+}
+
+// assertionError returns the error raised by a failed assertion, at the line that is
+// currently executing
+func assertionError(i *Interpreter, function string, message string) error {
+	token := Token{token_type: IDENTIFIER, lexeme: function, line: i.frames[len(i.frames)-1].line}
+	return RuntimeError{token, message}
+}
+
+// assert(condition) fails the test if the condition isn't truthy
+type assertFn struct{}
+
+func (a assertFn) arity() int {
+	return 1
+}
+
+func (a assertFn) call(i *Interpreter, arguments []any) (any, error) {
+	if !isTruthy(arguments[0]) {
+		description, _ := describeValue(arguments[0])
+		return nil, assertionError(i, "assert", "assertion failed: got "+description)
+	}
+	return nil, nil
+}
+
+// assertEqual(expected, actual) fails the test if the values aren't equal
+type assertEqualFn struct{}
+
+func (a assertEqualFn) arity() int {
+	return 2
+}
+
+func (a assertEqualFn) call(i *Interpreter, arguments []any) (any, error) {
+	if !isEqual(arguments[0], arguments[1]) {
+		expected, _ := describeValue(arguments[0])
+		actual, _ := describeValue(arguments[1])
+		return nil, assertionError(i, "assertEqual",
+			fmt.Sprintf("assertEqual failed: expected %s, got %s", expected, actual))
+	}
+	return nil, nil
+}
+
+// assertThrows(function) calls a function that takes no arguments, failing the test
+// if it doesn't raise a runtime error
+type assertThrowsFn struct{}
+
+func (a assertThrowsFn) arity() int {
+	return 1
+}
+
+func (a assertThrowsFn) call(i *Interpreter, arguments []any) (any, error) {
+	function, ok := asCallable(arguments[0])
+	if !ok || function.arity() != 0 {
+		return nil, assertionError(i, "assertThrows", "assertThrows needs a function with no parameters")
+	}
+
+	if _, err := function.call(i, nil); err != nil {
+		if _, isRuntimeErr := err.(RuntimeError); isRuntimeErr {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return nil, assertionError(i, "assertThrows", "assertThrows failed: no error was raised")
+}
+
+// findTestFiles expands the supplied paths into a list of test files. Files that are
+// named explicitly are included whatever they're called.
+func findTestFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		found, err := collectLoxFiles([]string{path})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range found {
+			if strings.HasSuffix(file, "_test.lox") {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// runTests runs the supplied tests, using up to the supplied number of goroutines
+func runTests(tests []*testCase, parallel int) {
+	queue := make(chan *testCase)
+	var wg sync.WaitGroup
+	for range max(parallel, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for test := range queue {
+				test.run()
+			}
+		}()
+	}
+	for _, test := range tests {
+		queue <- test
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// reportTests prints the results of the tests in each file, returning whether they
+// all passed
+func reportTests(out io.Writer, files []*testFile, verbose bool) bool {
+	passed, failed := 0, 0
+	for _, file := range files {
+		if len(file.diagnostics) > 0 {
+			for _, diag := range file.diagnostics {
+				fmt.Fprintf(out, "%s: %s\n", file.path, diag)
+			}
+			fmt.Fprintf(out, "FAIL\t%s [build failed]\n", file.path)
+			failed++
+			continue
+		}
+
+		fileFailed := false
+		var duration time.Duration
+		for _, test := range file.tests {
+			duration += test.duration
+			if test.passed {
+				passed++
+				if !verbose {
+					continue
+				}
+				fmt.Fprintf(out, "--- PASS: %s (%.2fs)\n", test.name, test.duration.Seconds())
+			} else {
+				failed++
+				fileFailed = true
+				fmt.Fprintf(out, "--- FAIL: %s (%.2fs)\n", test.name, test.duration.Seconds())
+				fmt.Fprintf(out, "    %s\n", test.failure)
+			}
+			if test.output != "" && (verbose || !test.passed) {
+				for _, line := range strings.Split(strings.TrimSuffix(test.output, "\n"), "\n") {
+					fmt.Fprintf(out, "    %s\n", line)
+				}
+			}
+		}
+
+		switch {
+		case fileFailed:
+			fmt.Fprintf(out, "FAIL\t%s\t%.3fs\n", file.path, duration.Seconds())
+		case len(file.tests) == 0:
+			fmt.Fprintf(out, "?   \t%s\t[no tests]\n", file.path)
+		default:
+			fmt.Fprintf(out, "ok  \t%s\t%.3fs\n", file.path, duration.Seconds())
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(out, "FAIL: %d passed, %d failed\n", passed, failed)
+		return false
+	}
+	fmt.Fprintf(out, "PASS: %d passed\n", passed)
+	return true
+}
+
+// testCommand implements 'glox test', which runs the tests in the supplied files and
+// directories
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "only run tests whose names match this regular expression")
+	parallel := flags.Int("parallel", runtime.NumCPU(), "maximum number of tests to run at once")
+	verbose := flags.Bool("v", false, "list every test, and show the output of passing tests")
+	cover := flags.Bool("cover", false, "report the coverage of the test files")
+	coverProfile := flags.String("coverprofile", "", "write coverage to a file in LCOV format")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox test [-run regexp] [-parallel n] [-v] [-cover] [-coverprofile=path] [path...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "glox test: invalid -run pattern: %v\n", err)
+			return 64
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filePaths, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox test: %v\n", err)
+		return 66
+	}
+	if len(filePaths) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	var coverage *Coverage
+	if *cover || *coverProfile != "" {
+		coverage = NewCoverage()
+	}
+	files := make([]*testFile, 0, len(filePaths))
+	tests := make([]*testCase, 0)
+	for _, path := range filePaths {
+		file, err := loadTestFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox test: %v\n", err)
+			return 66
+		}
+		if filter != nil {
+			matching := file.tests[:0]
+			for _, test := range file.tests {
+				if filter.MatchString(test.name) {
+					matching = append(matching, test)
+				}
+			}
+			file.tests = matching
+		}
+		if coverage != nil && len(file.diagnostics) == 0 {
+			file.coverage = coverage.addFile(filepath.Clean(path), file.source, file.stmtLines)
+		}
+		files = append(files, file)
+		tests = append(tests, file.tests...)
+	}
+
+	runTests(tests, *parallel)
+	ok := reportTests(os.Stdout, files, *verbose)
+
+	if coverage != nil {
+		fmt.Printf("coverage: %s\n", coverage.summary())
+		if *coverProfile != "" {
+			if err := writeCoverage(coverage, *coverProfile, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "glox test: %v\n", err)
+				return 73
+			}
+		}
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
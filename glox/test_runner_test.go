@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// LOX TEST RUNNER TESTS
+// ============================================================================
+
+const loxTestFile = `var counter = 0;
+
+fun increment() {
+  counter = counter + 1;
+  return counter;
+}
+
+fun test_passes() {
+  assertEqual(1, increment());
+  assert(counter == 1);
+}
+
+fun test_globals_are_fresh() {
+  assertEqual(1, increment());
+}
+
+fun test_fails() {
+  print "some output";
+  assertEqual("a", "b");
+}
+
+fun test_runtime_error() {
+  return nil + 1;
+}
+
+fun test_throws() {
+  fun bad() { return -"x"; }
+  assertThrows(bad);
+  assertThrows(clock);
+}
+
+fun test_with_parameter(x) {}
+`
+
+// runLoxTests runs 'glox test' with the supplied arguments in a directory holding
+// the supplied files, returning its output and exit code
+func runLoxTests(t *testing.T, files map[string]string, args ...string) (string, int) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, path, contents)
+	}
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	return captureCommandOutput(t, func() int { return testCommand(args) })
+}
+
+func TestLoxTestRunner(t *testing.T) {
+	t.Run("Reports passes and failures", func(t *testing.T) {
+		output, code := runLoxTests(t, map[string]string{"counter_test.lox": loxTestFile})
+		assertEqual(t, 1, code, "Exit code")
+		assertContains(t, output, "--- FAIL: test_fails (")
+		assertContains(t, output, "    counter_test.lox:19: assertEqual failed: expected \"a\", got \"b\"\n    some output\n")
+		assertContains(t, output, "--- FAIL: test_runtime_error (")
+		assertContains(t, output, "    counter_test.lox:23: operands to operator + must be")
+		assertContains(t, output, "--- FAIL: test_throws (")
+		assertContains(t, output, "    counter_test.lox:29: assertThrows failed: no error was raised\n")
+		assertContains(t, output, "FAIL\tcounter_test.lox\t")
+		assertContains(t, output, "FAIL: 2 passed, 3 failed\n")
+		if strings.Contains(output, "test_passes") || strings.Contains(output, "test_with_parameter") {
+			t.Errorf("Unexpected test in output:\n%s", output)
+		}
+	})
+
+	t.Run("Verbose", func(t *testing.T) {
+		output, code := runLoxTests(t, map[string]string{"counter_test.lox": loxTestFile}, "-v", "-parallel=1")
+		assertEqual(t, 1, code, "Exit code")
+		assertContains(t, output, "--- PASS: test_passes (")
+		assertContains(t, output, "--- PASS: test_globals_are_fresh (")
+	})
+
+	t.Run("Filtering", func(t *testing.T) {
+		output, code := runLoxTests(t, map[string]string{"counter_test.lox": loxTestFile}, "-run", "passes|fresh")
+		assertEqual(t, 0, code, "Exit code")
+		assertContains(t, output, "ok  \tcounter_test.lox\t")
+		assertContains(t, output, "PASS: 2 passed\n")
+
+		_, code = runLoxTests(t, map[string]string{"counter_test.lox": loxTestFile}, "-run", "(")
+		assertEqual(t, 64, code, "Invalid pattern")
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		files := map[string]string{
+			"a_test.lox":     "fun test_a() { assert(true); }\n",
+			"sub/b_test.lox": "fun test_b() { assert(true); }\n",
+			"sub/c_test.lox": "fun helper() {}\n",
+			"lib.lox":        "fun test_not_a_test() { assert(false); }\n",
+		}
+		output, code := runLoxTests(t, files)
+		assertEqual(t, 0, code, "Exit code")
+		assertContains(t, output, "ok  \ta_test.lox\t")
+		assertContains(t, output, "ok  \t"+filepath.Join("sub", "b_test.lox")+"\t")
+		assertContains(t, output, "?   \t"+filepath.Join("sub", "c_test.lox")+"\t[no tests]\n")
+		assertContains(t, output, "PASS: 2 passed\n")
+
+		output, code = runLoxTests(t, files, "lib.lox")
+		assertEqual(t, 1, code, "Explicitly named file")
+		assertContains(t, output, "lib.lox:1: assertion failed: got false\n")
+
+		output, code = runLoxTests(t, map[string]string{"lib.lox": ""})
+		assertEqual(t, 0, code, "No test files")
+		assertEqual(t, "no test files\n", output, "Output")
+	})
+
+	t.Run("Files with errors", func(t *testing.T) {
+		output, code := runLoxTests(t, map[string]string{"bad_test.lox": "fun test_x() {\n  print ;\n}\n"})
+		assertEqual(t, 1, code, "Exit code")
+		assertContains(t, output, "bad_test.lox: [line 2] Error at ; : Expected expression\n")
+		assertContains(t, output, "FAIL\tbad_test.lox [build failed]\n")
+	})
+
+	t.Run("Reassigned test function", func(t *testing.T) {
+		program := "fun test_x() { assert(true); }\ntest_x = 5;\n"
+		output, code := runLoxTests(t, map[string]string{"reassigned_test.lox": program})
+		assertEqual(t, 1, code, "Exit code")
+		assertContains(t, output, "--- FAIL: test_x (")
+		assertContains(t, output, "reassigned_test.lox:1: test_x is no longer a function, so can't be run.\n")
+	})
+
+	t.Run("Callable instances", func(t *testing.T) {
+		program := "class Bad { __call() { return -\"x\"; } }\nclass Plain {}\n" +
+			"fun test_callable() {\n  assertThrows(Bad());\n}\nfun test_plain() {\n  assertThrows(Plain());\n}\n"
+		output, code := runLoxTests(t, map[string]string{"call_test.lox": program}, "-v")
+		assertEqual(t, 1, code, "Exit code")
+		assertContains(t, output, "--- PASS: test_callable (")
+		assertContains(t, output, "call_test.lox:7: assertThrows needs a function with no parameters\n")
+	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		program := "fun sign(n) {\n  if (n < 0)\n    return -1;\n  return 1;\n}\nfun test_sign() {\n  assertEqual(1, sign(5));\n}\n"
+		output, code := runLoxTests(t, map[string]string{"sign_test.lox": program}, "-cover", "-coverprofile=lcov.info")
+		assertEqual(t, 0, code, "Exit code")
+		assertContains(t, output, "coverage: 83.3% of lines, 50.0% of branches, 100.0% of functions\n")
+	})
+}
@@ -12,12 +12,14 @@ type TestGLox struct {
 	hadRuntimeError bool
 	errors          []string
 	runtimeErrors   []string
+	warnings        []string
 }
 
 func NewTestGLox() *TestGLox {
 	return &TestGLox{
 		errors:        make([]string, 0),
 		runtimeErrors: make([]string, 0),
+		warnings:      make([]string, 0),
 	}
 }
 
@@ -40,6 +42,16 @@ func (l *TestGLox) runtimeError(err error) {
 	l.hadRuntimeError = true
 }
 
+func (l *TestGLox) warning(token Token, message string) {
+	var warningMsg string
+	if token.token_type == EOF {
+		warningMsg = fmt.Sprintf("[line %d] Warning at end: %s", token.line, message)
+	} else {
+		warningMsg = fmt.Sprintf("[line %d] Warning at %s : %s", token.line, token.lexeme, message)
+	}
+	l.warnings = append(l.warnings, warningMsg)
+}
+
 func (l *TestGLox) report(line int, where string, message string) {
 	errorMsg := fmt.Sprintf("[line %d] Error%s: %s", line, where, message)
 	l.errors = append(l.errors, errorMsg)
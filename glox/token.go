@@ -7,6 +7,8 @@ type Token struct {
 	lexeme     string // string representation 
 	literal    any // actual value, for numbers and strings
 	line       int // line of code where token was found
+	column     int // column (1-based, in characters) at which the token starts
+	startLine  int // line on which the token starts, which is only different from line for multi-line strings
 }
 
 func (t Token) String() string {
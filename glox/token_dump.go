@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tokenDumpEntry is a single entry in a dump of the token stream: a token, a comment,
+// or an error found by the scanner
+type tokenDumpEntry struct {
+	kind    string // token type, or "COMMENT" or "ERROR"
+	lexeme  string
+	literal any
+	line    int
+	column  int
+	message string // only set for errors
+}
+
+// dumpTokens scans the supplied source, returning the resulting tokens with any
+// scanning errors (and optionally comments) inserted at the point they occurred
+func dumpTokens(source string, includeComments bool) ([]tokenDumpEntry, bool) {
+	scanner := NewScanner(NewDiagnosticCollector(), source)
+	scanner.keepComments = includeComments
+	tokens := scanner.scanTokens()
+
+	entries := make([]tokenDumpEntry, 0, len(tokens))
+	nextError, nextComment := 0, 0
+	for i, token := range tokens {
+		for nextError < len(scanner.errors) && scanner.errors[nextError].tokenIndex <= i {
+			err := scanner.errors[nextError]
+			entries = append(entries, tokenDumpEntry{
+				kind: "ERROR", lexeme: err.lexeme, line: err.line, column: err.column, message: err.message,
+			})
+			nextError++
+		}
+
+		// A comment runs to the end of its line, so it comes after all the tokens on
+		// that line
+		for nextComment < len(scanner.comments) &&
+			(token.token_type == EOF || scanner.comments[nextComment].line < token.startLine) {
+			comment := scanner.comments[nextComment]
+			entries = append(entries, tokenDumpEntry{
+				kind: COMMENT.String(), lexeme: comment.lexeme, line: comment.line, column: comment.column,
+			})
+			nextComment++
+		}
+
+		entries = append(entries, tokenDumpEntry{
+			kind: token.token_type.String(), lexeme: token.lexeme, literal: token.literal,
+			line: token.startLine, column: token.column,
+		})
+	}
+
+	return entries, len(scanner.errors) == 0
+}
+
+// formatTokenDump renders the token stream as a table, one entry per line
+func formatTokenDump(entries []tokenDumpEntry) string {
+	var sb strings.Builder
+	for _, entry := range entries {
+		position := fmt.Sprintf("%d:%d", entry.line, entry.column)
+		if entry.kind == "ERROR" {
+			fmt.Fprintf(&sb, "%-8s %-14s %s: %s\n", position, entry.kind, entry.message, escapeLexeme(entry.lexeme))
+			continue
+		}
+
+		line := fmt.Sprintf("%-8s %-14s %s", position, entry.kind, escapeLexeme(entry.lexeme))
+		switch literal := entry.literal.(type) {
+		case string:
+			line = fmt.Sprintf("%-40s %q", line, literal)
+		case float64:
+			line = fmt.Sprintf("%-40s %v", line, literal)
+		}
+		sb.WriteString(strings.TrimRight(line, " "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// escapeLexeme makes whitespace in a lexeme (eg a multi-line string) visible, so
+// each token is printed on a single line
+func escapeLexeme(lexeme string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(lexeme)
+}
+
+func tokenDumpJSON(entries []tokenDumpEntry) (string, error) {
+	values := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		object := orderedJSONObject{
+			{"type", entry.kind},
+			{"lexeme", entry.lexeme},
+		}
+		if entry.kind == "ERROR" {
+			object = append(object, orderedJSONField{"message", entry.message})
+		} else {
+			object = append(object, orderedJSONField{"literal", entry.literal})
+		}
+		object = append(object, orderedJSONField{"line", entry.line}, orderedJSONField{"column", entry.column})
+		values = append(values, object)
+	}
+
+	data, err := marshalJSON(values, "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// tokensCommand implements 'glox tokens', which prints the tokens the scanner
+// produces for a file
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print tokens as JSON")
+	comments := flags.Bool("comments", false, "include comments in the token stream")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox tokens [--json] [--comments] file.lox")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 64
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glox tokens: %v\n", err)
+		return 66
+	}
+
+	entries, ok := dumpTokens(string(data), *comments)
+	if *asJSON {
+		output, err := tokenDumpJSON(entries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glox tokens: %v\n", err)
+			return 70
+		}
+		fmt.Print(output)
+	} else {
+		fmt.Print(formatTokenDump(entries))
+	}
+
+	if !ok {
+		return 65
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// TOKEN DUMP TESTS
+// ============================================================================
+
+func TestTokenDump(t *testing.T) {
+	t.Run("Tokens with positions and literals", func(t *testing.T) {
+		entries, ok := dumpTokens("var s = \"hi\";\nprint 2.5;", false)
+		if !ok {
+			t.Fatalf("Unexpected scanning errors")
+		}
+
+		expected := `1:1      VAR            var
+1:5      IDENTIFIER     s
+1:7      EQUAL          =
+1:9      STRING         "hi"             "hi"
+1:13     SEMICOLON      ;
+2:1      PRINT          print
+2:7      NUMBER         2.5              2.5
+2:10     SEMICOLON      ;
+2:11     EOF
+`
+		assertEqual(t, expected, formatTokenDump(entries), "Token dump")
+	})
+
+	t.Run("Scanner errors appear inline", func(t *testing.T) {
+		entries, ok := dumpTokens("a # b;\nvar s = \"open", false)
+		if ok {
+			t.Errorf("Expected scanning errors")
+		}
+
+		kinds := make([]string, 0)
+		for _, entry := range entries {
+			kinds = append(kinds, entry.kind)
+		}
+		assertEqual(t, "IDENTIFIER ERROR IDENTIFIER SEMICOLON VAR IDENTIFIER EQUAL ERROR EOF", strings.Join(kinds, " "), "Entry order")
+
+		output := formatTokenDump(entries)
+		assertContains(t, output, "1:3      ERROR          Unexpected character: #")
+		assertContains(t, output, "2:9      ERROR          Unterminated string: \"open")
+	})
+
+	t.Run("Comments are included on request", func(t *testing.T) {
+		entries, _ := dumpTokens("// first\nvar a; // second\n// last", true)
+		kinds := make([]string, 0)
+		for _, entry := range entries {
+			kinds = append(kinds, entry.kind)
+		}
+		assertEqual(t, "COMMENT VAR IDENTIFIER SEMICOLON COMMENT COMMENT EOF", strings.Join(kinds, " "), "Entry order")
+	})
+
+	t.Run("Multi-line strings are printed on one line", func(t *testing.T) {
+		entries, _ := dumpTokens("\"a\nb\"", false)
+		assertContains(t, formatTokenDump(entries), `"a\nb"`)
+	})
+
+	t.Run("Multi-line strings are positioned where they start", func(t *testing.T) {
+		entries, ok := dumpTokens("var a = \"x\nyy\";\nprint a;", false)
+		if !ok {
+			t.Fatalf("Unexpected scanning errors")
+		}
+
+		expected := `1:1      VAR            var
+1:5      IDENTIFIER     a
+1:7      EQUAL          =
+1:9      STRING         "x\nyy"          "x\nyy"
+2:4      SEMICOLON      ;
+3:1      PRINT          print
+3:7      IDENTIFIER     a
+3:8      SEMICOLON      ;
+3:9      EOF
+`
+		assertEqual(t, expected, formatTokenDump(entries), "Token dump")
+
+		entries, _ = dumpTokens("print \"open\nstill open", false)
+		assertContains(t, formatTokenDump(entries), "1:7      ERROR          Unterminated string")
+	})
+
+	t.Run("JSON output doesn't escape operators", func(t *testing.T) {
+		entries, _ := dumpTokens("a <= b;", false)
+		output, err := tokenDumpJSON(entries)
+		assertNoError(t, err, "Marshal JSON")
+		assertContains(t, output, `"lexeme": "<="`)
+	})
+
+	t.Run("JSON output", func(t *testing.T) {
+		entries, _ := dumpTokens("x = 1; @", false)
+		output, err := tokenDumpJSON(entries)
+		assertNoError(t, err, "Marshal JSON")
+
+		var decoded []map[string]any
+		if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+			t.Fatalf("Output isn't valid JSON: %v", err)
+		}
+		assertEqual(t, 6, len(decoded), "Entry count")
+		assertEqual(t, "NUMBER", decoded[2]["type"], "Number type")
+		assertEqual(t, float64(1), decoded[2]["literal"], "Number literal")
+		assertEqual(t, float64(5), decoded[2]["column"], "Number column")
+		assertEqual(t, "ERROR", decoded[4]["type"], "Error type")
+		assertEqual(t, "Unexpected character", decoded[4]["message"], "Error message")
+	})
+}
+
+func TestTokensCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lox")
+	writeTestFile(t, path, "print 1;")
+	stdout, code := captureCommandOutput(t, func() int { return tokensCommand([]string{path}) })
+	assertEqual(t, 0, code, "Exit code")
+	assertContains(t, stdout, "1:1      PRINT          print")
+
+	writeTestFile(t, path, "print $;")
+	_, code = captureCommandOutput(t, func() int { return tokensCommand([]string{path}) })
+	assertEqual(t, 65, code, "Exit code with scanner errors")
+}
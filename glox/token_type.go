@@ -8,6 +8,8 @@ const (
     RIGHT_PAREN
     LEFT_BRACE
     RIGHT_BRACE
+    LEFT_BRACKET
+    RIGHT_BRACKET
     COMMA
     DOT
     MINUS
@@ -48,6 +50,14 @@ const (
     TRUE
     VAR
     WHILE
+
+    // 'is' is scanned as an identifier, since it can be used as a name, and only
+    // becomes an operator when the parser finds it between two operands
+    IS
+
+    // Comments are only produced when the scanner is asked to retain them,
+    // and never reach the parser
+    COMMENT
     
     EOF
 )
@@ -55,14 +65,15 @@ const (
 func (t TokenType) String() string {
     // Order must match constants above
     names := []string{
-        "LEFT_PAREN", "RIGHT_PAREN", "LEFT_BRACE", "RIGHT_BRACE",
+        "LEFT_PAREN", "RIGHT_PAREN", "LEFT_BRACE", "RIGHT_BRACE", "LEFT_BRACKET", "RIGHT_BRACKET",
         "COMMA", "DOT", "MINUS", "PLUS", "SEMICOLON", "SLASH", "STAR",
         "BANG", "BANG_EQUAL", "EQUAL", "EQUAL_EQUAL",
         "GREATER", "GREATER_EQUAL", "LESS", "LESS_EQUAL",
         "IDENTIFIER", "STRING", "NUMBER",
         "AND", "CLASS", "ELSE", "FALSE", "FUN", "FOR", "IF", "NIL",
         "OR", "PRINT", "RETURN", "SUPER", "THIS", "TRUE", "VAR", "WHILE",
-        "EOF",
+        "IS",
+        "COMMENT", "EOF",
     }
     if t < 0 || int(t) >= len(names) {
         return "UNKNOWN"
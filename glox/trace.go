@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tracer is an execution hook that logs each statement a program executes, calls to
+// and returns from functions, and assignments to variables and fields. Events are
+// indented by call depth.
+type Tracer struct {
+	interpreter *Interpreter
+	out         io.Writer
+	file        string   // name of the script, used in trace locations
+	source      []string // lines of the script
+	stmtLines   map[Stmt]int
+
+	// functions, if not empty, restricts the trace to calls to the named functions
+	// (including anything they call)
+	functions   map[string]bool
+	activeCalls int // number of calls to functions in the filter currently running
+	// If lastLine isn't 0, only events on lines in [firstLine, lastLine] are traced
+	firstLine int
+	lastLine  int
+}
+
+func NewTracer(interpreter *Interpreter, out io.Writer, file string, source string, stmtLines map[Stmt]int) *Tracer {
+	return &Tracer{
+		interpreter: interpreter,
+		out:         out,
+		file:        file,
+		source:      strings.Split(source, "\n"),
+		stmtLines:   stmtLines,
+		functions:   make(map[string]bool),
+	}
+}
+
+// setFunctionFilter restricts the trace to calls to the functions in a
+// comma-separated list. Methods can be given with or without their class name eg
+// "Counter.add" or "add".
+func (t *Tracer) setFunctionFilter(names string) {
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			t.functions[name] = true
+		}
+	}
+}
+
+// setLineFilter restricts the trace to a range of lines, given as "from-to" or as a
+// single line number
+func (t *Tracer) setLineFilter(lines string) error {
+	from, to, isRange := strings.Cut(lines, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || first < 1 {
+		return fmt.Errorf("invalid line range '%s'", lines)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || last < first {
+			return fmt.Errorf("invalid line range '%s'", lines)
+		}
+	}
+	t.firstLine, t.lastLine = first, last
+	return nil
+}
+
+func (t *Tracer) beforeStatement(stmt Stmt) error {
+	line, ok := t.stmtLines[stmt]
+	if _, isBlock := stmt.(*BlockStmt); !ok || isBlock {
+		return nil
+	}
+
+	t.currentFrame().line = line
+	text := ""
+	if line <= len(t.source) {
+		text = strings.TrimSpace(t.source[line-1])
+	}
+	t.emit(line, len(t.interpreter.frames)-1, "exec "+text)
+	return nil
+}
+
+func (t *Tracer) enteredCall(frame *callFrame, arguments []any) {
+	if t.isFiltered(frame) {
+		t.activeCalls++
+	}
+
+	args := make([]string, 0, len(arguments))
+	for _, arg := range arguments {
+		description, _ := describeValue(arg)
+		args = append(args, description)
+	}
+	caller := t.interpreter.frames[len(t.interpreter.frames)-2]
+	t.emit(caller.line, len(t.interpreter.frames)-2, "call "+frame.displayName()+"("+strings.Join(args, ", ")+")")
+}
+
+func (t *Tracer) returnedFromCall(frame *callFrame, result any, err error) {
+	event := "return " + frame.displayName()
+	if err != nil {
+		event += " with error: " + err.Error()
+	} else {
+		description, _ := describeValue(result)
+		event += " = " + description
+	}
+	t.emit(frame.line, len(t.interpreter.frames)-2, event)
+
+	if t.isFiltered(frame) {
+		t.activeCalls--
+	}
+}
+
+func (t *Tracer) assignedVariable(name Token, value any) {
+	description, _ := describeValue(value)
+	t.emit(name.line, len(t.interpreter.frames)-1, "assign "+name.lexeme+" = "+description)
+}
+
+func (t *Tracer) assignedField(instance *LoxInstance, name Token, value any) {
+	object, _ := describeValue(instance)
+	description, _ := describeValue(value)
+	t.emit(name.line, len(t.interpreter.frames)-1, "set ("+object+")."+name.lexeme+" = "+description)
+}
+
+func (t *Tracer) currentFrame() *callFrame {
+	return t.interpreter.frames[len(t.interpreter.frames)-1]
+}
+
+// isFiltered reports whether a call is to one of the functions in the filter
+func (t *Tracer) isFiltered(frame *callFrame) bool {
+	return frame.function != nil &&
+		(t.functions[frame.displayName()] || t.functions[frame.function.declaration.functionName.lexeme])
+}
+
+func (t *Tracer) emit(line int, depth int, event string) {
+	if len(t.functions) > 0 && t.activeCalls == 0 {
+		return
+	}
+	if t.lastLine != 0 && (line < t.firstLine || line > t.lastLine) {
+		return
+	}
+	fmt.Fprintf(t.out, "%s:%d: %s%s\n", t.file, line, strings.Repeat("  ", depth), event)
+}
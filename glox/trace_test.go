@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// TRACING TESTS
+// ============================================================================
+
+const traceTestProgram = `class Counter {
+    init(start) {
+        this.count = start;
+    }
+    add(n) {
+        this.count = this.count + n;
+        return this.count;
+    }
+}
+fun twice(x) {
+    return x * 2;
+}
+var c = Counter(1);
+var total = 0;
+total = c.add(twice(3));
+print total;
+`
+
+// runTraced runs a program with 'glox run' and the supplied tracing options,
+// returning the program's output and the trace
+func runTraced(t *testing.T, program string, options ...string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trace.lox")
+	traceFile := filepath.Join(dir, "trace.txt")
+	writeTestFile(t, path, program)
+
+	args := append([]string{"--trace-file=" + traceFile}, options...)
+	args = append(args, path)
+	stdout, code := captureCommandOutput(t, func() int { return runCommand(args) })
+	assertEqual(t, 0, code, "Exit code")
+
+	trace, err := os.ReadFile(traceFile)
+	assertNoError(t, err, "Read trace")
+	return stdout, string(trace)
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("Full trace", func(t *testing.T) {
+		stdout, trace := runTraced(t, traceTestProgram, "--trace")
+		assertEqual(t, "7\n", stdout, "Program output")
+
+		expected := `trace.lox:1: exec class Counter {
+trace.lox:10: exec fun twice(x) {
+trace.lox:13: exec var c = Counter(1);
+trace.lox:13: call Counter.init(1)
+trace.lox:3:   exec this.count = start;
+trace.lox:3:   set (Counter instance).count = 1
+trace.lox:3: return Counter.init = nil
+trace.lox:14: exec var total = 0;
+trace.lox:15: exec total = c.add(twice(3));
+trace.lox:15: call twice(3)
+trace.lox:11:   exec return x * 2;
+trace.lox:11: return twice = 6
+trace.lox:15: call Counter.add(6)
+trace.lox:6:   exec this.count = this.count + n;
+trace.lox:6:   set (Counter instance).count = 7
+trace.lox:7:   exec return this.count;
+trace.lox:7: return Counter.add = 7
+trace.lox:15: assign total = 7
+trace.lox:16: exec print total;
+`
+		assertEqual(t, expected, trace, "Trace")
+	})
+
+	t.Run("Function filter includes nested calls", func(t *testing.T) {
+		program := "fun inner(a) { return a + 1; }\nfun outer(a) { return inner(a) * 2; }\nprint outer(1);\nprint inner(5);\n"
+		_, trace := runTraced(t, program, "--trace-func=outer")
+		expected := `trace.lox:3: call outer(1)
+trace.lox:2:   exec fun outer(a) { return inner(a) * 2; }
+trace.lox:2:   call inner(1)
+trace.lox:1:     exec fun inner(a) { return a + 1; }
+trace.lox:1:   return inner = 2
+trace.lox:2: return outer = 4
+`
+		assertEqual(t, expected, trace, "Trace")
+	})
+
+	t.Run("Methods can be filtered by bare name", func(t *testing.T) {
+		_, trace := runTraced(t, traceTestProgram, "--trace-func=add")
+		assertEqual(t, 5, strings.Count(trace, "\n"), "Trace lines")
+		assertContains(t, trace, "call Counter.add(6)")
+	})
+
+	t.Run("Line filter", func(t *testing.T) {
+		_, trace := runTraced(t, traceTestProgram, "--trace-lines=6-7")
+		expected := `trace.lox:6:   exec this.count = this.count + n;
+trace.lox:6:   set (Counter instance).count = 7
+trace.lox:7:   exec return this.count;
+trace.lox:7: return Counter.add = 7
+`
+		assertEqual(t, expected, trace, "Trace")
+	})
+
+	t.Run("Loops and errors", func(t *testing.T) {
+		program := "var i = 0;\nwhile (i < 2) i = i + 1;\nfun bad() { return -\"x\"; }\nbad();\n"
+		dir := t.TempDir()
+		path := filepath.Join(dir, "trace.lox")
+		traceFile := filepath.Join(dir, "trace.txt")
+		writeTestFile(t, path, program)
+
+		_, code := captureCommandOutput(t, func() int { return runCommand([]string{"--trace-file=" + traceFile, path}) })
+		assertEqual(t, 70, code, "Exit code")
+		trace, _ := os.ReadFile(traceFile)
+		assertEqual(t, 2, strings.Count(string(trace), "assign i = "), "Loop assignments")
+		assertContains(t, string(trace), "trace.lox:3: return bad with error: ")
+	})
+
+	t.Run("Invalid line range", func(t *testing.T) {
+		tracer := NewTracer(nil, nil, "", "", nil)
+		assertError(t, tracer.setLineFilter("10-5"), "Reversed range")
+		assertError(t, tracer.setLineFilter("abc"), "Not a number")
+		assertNoError(t, tracer.setLineFilter("7"), "Single line")
+		assertEqual(t, 7, tracer.lastLine, "Last line of single-line range")
+	})
+}
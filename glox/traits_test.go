@@ -0,0 +1,221 @@
+package main
+
+import "testing"
+
+// ============================================================================
+// TRAIT TESTS
+// ============================================================================
+
+func TestTraits(t *testing.T) {
+	t.Run("Trait methods are copied into the class", func(t *testing.T) {
+		program := `
+trait Greets {
+    greet() {
+        return "Hello, " + this.name;
+    }
+}
+class Person with Greets {
+    init(name) {
+        this.name = name;
+    }
+}
+print Person("Ada").greet();
+`
+		expected := []string{"Hello, Ada"}
+		runProgramAndCheckOutput(t, program, expected, "Trait methods are copied into the class")
+	})
+
+	t.Run("Class uses several traits", func(t *testing.T) {
+		program := `
+trait Walks {
+    walk() {
+        return "walking";
+    }
+}
+trait Swims {
+    swim() {
+        return "swimming";
+    }
+}
+class Duck with Walks, Swims {}
+var duck = Duck();
+print duck.walk();
+print duck.swim();
+`
+		expected := []string{"walking", "swimming"}
+		runProgramAndCheckOutput(t, program, expected, "Class uses several traits")
+	})
+
+	t.Run("Getters and setters in traits", func(t *testing.T) {
+		program := `
+trait Sized {
+    size {
+        return this.width * this.height;
+    }
+    set side(value) {
+        this.width = value;
+        this.height = value;
+    }
+}
+class Square with Sized {}
+var s = Square();
+s.side = 3;
+print s.size;
+`
+		expected := []string{"9"}
+		runProgramAndCheckOutput(t, program, expected, "Getters and setters in traits")
+	})
+
+	t.Run("Class methods override trait methods", func(t *testing.T) {
+		program := `
+trait Named {
+    name() {
+        return "trait";
+    }
+}
+class Thing with Named {
+    name() {
+        return "class";
+    }
+}
+print Thing().name();
+`
+		expected := []string{"class"}
+		runProgramAndCheckOutput(t, program, expected, "Class methods override trait methods")
+	})
+
+	t.Run("Trait methods override superclass methods", func(t *testing.T) {
+		program := `
+class Base {
+    name() {
+        return "base";
+    }
+}
+trait Named {
+    name() {
+        return "trait";
+    }
+}
+class Derived < Base with Named {}
+print Derived().name();
+`
+		expected := []string{"trait"}
+		runProgramAndCheckOutput(t, program, expected, "Trait methods override superclass methods")
+	})
+
+	t.Run("Super in a trait method refers to the class's superclass", func(t *testing.T) {
+		program := `
+trait Loud {
+    speak() {
+        return super.speak() + "!";
+    }
+}
+class Dog {
+    speak() {
+        return "woof";
+    }
+}
+class Cat {
+    speak() {
+        return "meow";
+    }
+}
+class LoudDog < Dog with Loud {}
+class LoudCat < Cat with Loud {}
+print LoudDog().speak();
+print LoudCat().speak();
+`
+		expected := []string{"woof!", "meow!"}
+		runProgramAndCheckOutput(t, program, expected, "Super in a trait method refers to the class's superclass")
+	})
+
+	t.Run("Conflict resolved by the class", func(t *testing.T) {
+		program := `
+trait A {
+    hello() {
+        return "A";
+    }
+}
+trait B {
+    hello() {
+        return "B";
+    }
+}
+class C with A, B {
+    hello() {
+        return "C";
+    }
+}
+print C().hello();
+`
+		expected := []string{"C"}
+		runProgramAndCheckOutput(t, program, expected, "Conflict resolved by the class")
+	})
+
+	t.Run("Trait and with can be used as names", func(t *testing.T) {
+		program := `
+var trait = "t";
+var with = "w";
+print trait + with;
+`
+		expected := []string{"tw"}
+		runProgramAndCheckOutput(t, program, expected, "Trait and with can be used as names")
+	})
+}
+
+func TestTraitErrors(t *testing.T) {
+	t.Run("Conflicting trait methods", func(t *testing.T) {
+		program := `
+trait A {
+    hello() {}
+}
+trait B {
+    hello() {}
+}
+class C with A, B {}
+`
+		runProgramAndExpectError(t, program, "Method 'hello' is provided by traits A and B, so class C must declare it.", "Conflicting trait methods")
+	})
+
+	t.Run("Using a class as a trait", func(t *testing.T) {
+		program := `
+class A {}
+class B with A {}
+`
+		runProgramAndExpectError(t, program, "Not a trait.", "Using a class as a trait")
+	})
+
+	t.Run("Super in a class with no superclass", func(t *testing.T) {
+		program := `
+trait T {
+    m() {
+        return super.m();
+    }
+}
+class C with T {}
+C().m();
+`
+		runProgramAndExpectError(t, program, "Can't use 'super' in a class with no superclass", "Super in a class with no superclass")
+	})
+
+	t.Run("Duplicate trait methods", func(t *testing.T) {
+		program := `
+trait T {
+    m() {}
+    m() {}
+}
+`
+		runProgramAndExpectError(t, program, "method with this name already exists", "Duplicate trait methods")
+	})
+
+	t.Run("Private member in a trait", func(t *testing.T) {
+		program := `
+trait T {
+    m() {
+        return this.#secret;
+    }
+}
+`
+		runProgramAndExpectError(t, program, "Can't access private member '#secret' in a trait.", "Private member in a trait")
+	})
+}